@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/Sirupsen/logrus"
+	s "github.com/solefaucet/jackpot-server/services/storage"
+	w "github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// coin runs jackpot of a coin with its own wallet, storage rows and background jobs
+type coin struct {
+	config  coinConfiguration
+	wallet  w.Wallet
+	storage s.Storage
+
+	blockHeightChan chan int64
+
+	// nil if wallet cannot push block notifications, then blocks are only polled
+	blockNotifications <-chan w.BlockNotification
+
+	// jobs are woken up by new blocks rather than waiting for their next round
+	confirmationsWakeup chan struct{}
+	drawGamesWakeup     chan struct{}
+	refundsWakeup       chan struct{}
+	payoutsWakeup       chan struct{}
+
+	// hash of chain tip confirmations are derived from, owned by updateConfirmationsJob
+	confirmedTipHash string
+}
+
+var coins []*coin
+
+func newCoin(config coinConfiguration, storage s.Storage) *coin {
+	wallet := newWallet(config)
+	return &coin{
+		config:              config,
+		wallet:              wallet,
+		storage:             storage,
+		blockHeightChan:     make(chan int64, 2),
+		blockNotifications:  newBlockNotifications(config, wallet),
+		confirmationsWakeup: make(chan struct{}, 1),
+		drawGamesWakeup:     make(chan struct{}, 1),
+		refundsWakeup:       make(chan struct{}, 1),
+		payoutsWakeup:       make(chan struct{}, 1),
+	}
+}
+
+// withFields returns log entry of coin with fields
+func (c *coin) withFields(fields logrus.Fields) *logrus.Entry {
+	return logrus.WithField("coin", c.config.Name).WithFields(fields)
+}
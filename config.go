@@ -1,12 +1,19 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
 
 	"gopkg.in/go-playground/validator.v8"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/solefaucet/jackpot-server/models"
+	w "github.com/solefaucet/jackpot-server/services/wallet"
 	"github.com/solefaucet/jackpot-server/utils"
 	"github.com/spf13/viper"
 )
@@ -17,6 +24,59 @@ type graylog struct {
 	Facility string `mapstructure:"facility" validate:"required"`
 }
 
+// coinConfiguration is configuration of jackpot of a coin
+type coinConfiguration struct {
+	Name   string // names coin in api routes and storage rows, empty in single coin deployment
+	Wallet struct {
+		Driver                 string `validate:"required,eq=core|eq=simulated|eq=esplora"`
+		Host                   string // with esplora driver, the core wallet is used as signer if host is set
+		EsploraURL             string
+		Username               string
+		Password               string
+		MinConfirms            int64  `validate:"required,min=1"`
+		SenderPolicy           string `validate:"required,eq=largest_input|eq=single_address"`
+		SimulatedBlockInterval time.Duration
+		NotifyHost             string
+		NotifyCertificate      string
+		PollInterval           time.Duration `validate:"required"`
+		SyncWorkers            int           `validate:"required,min=1"`
+		CatchUpThreshold       int64         `validate:"required,min=1"`
+		StartHeight            int64         `validate:"min=-1"`
+		MempoolPollInterval    time.Duration `validate:"required"`
+		StartHash              string
+		FeeConfTarget          int64 `validate:"required,min=1"` // blocks the payout is expected to confirm within
+		PayoutBatching         bool  // payouts due at once are sent in one transaction
+		// reserve ratio is balance over liabilities, alert is warning below the first and error below the second
+		BalanceCheckInterval time.Duration `validate:"required"`
+		ReserveWarningRatio  float64       `validate:"min=0"`
+		ReserveCriticalRatio float64       `validate:"min=0,ltefield=ReserveWarningRatio"`
+		SweepInterval        time.Duration `validate:"required"` // how often unswept fees are checked against sweep threshold
+	} `validate:"required"`
+	Coin struct {
+		Type       string `validate:"required"`
+		Label      string `validate:"required"`
+		TxURL      string `validate:"required"`
+		AddressURL string `validate:"required"`
+	} `validate:"required"`
+	Jackpot struct {
+		DestAddress    string   `validate:"required"`
+		ExtraAddresses []string // addresses accepted besides dest address, e.g. previous dest addresses
+		// bet limits, zero means no limit
+		MinBet           models.Amount `validate:"min=0"`
+		MaxBet           models.Amount `validate:"min=0"`
+		MaxBetPerAddress models.Amount `validate:"min=0"`
+		TransactionFee   float64       `validate:"required,min=0,lt=1"`
+		Duration         time.Duration
+		RoundBlocks      int64 `validate:"min=0"` // games span fixed number of blocks instead of duration if positive
+		// who pays network fee of payout, winner pays at most NetworkFeeCap with capped policy
+		NetworkFeePolicy string        `validate:"required,eq=house|eq=winner|eq=capped"`
+		NetworkFeeCap    models.Amount `validate:"min=0"`
+		// house fees are swept to cold address once unswept ones reach threshold, no sweeping without cold address
+		ColdAddress    string
+		SweepThreshold models.Amount `validate:"min=0"`
+	} `validate:"required"`
+}
+
 type configuration struct {
 	HTTP struct {
 		Address string `validate:"required"`
@@ -26,33 +86,52 @@ type configuration struct {
 		Level   string  `mapstructure:"level" validate:"required,eq=debug|eq=info|eq=warn|eq=error|eq=fatal|eq=panic"`
 		Graylog graylog `mapstructure:"graylog" validate:"required,dive"`
 	} `validate:"required"`
-	Wallet struct {
-		Host            string `validate:"required"`
-		Username        string `validate:"required"`
-		Password        string `validate:"required"`
-		MinConfirms     int64  `validate:"required,min=1"`
-		SentFromAccount string `validate:"required"`
-	} `validate:"required"`
-	Coin struct {
-		Type       string `validate:"required"`
-		Label      string `validate:"required"`
-		TxURL      string `validate:"required"`
-		AddressURL string `validate:"required"`
-	} `validate:"required"`
 	DB struct {
 		DataSourceName string `validate:"required,dsn"`
 		MaxOpenConns   int    `validate:"required,min=1"`
 		MaxIdleConns   int    `validate:"required,min=1,ltefield=MaxOpenConns"`
 	} `validate:"required"`
-	Jackpot struct {
-		DestAddress    string  `validate:"required"`
-		TransactionFee float64 `validate:"required,min=0,lt=1"`
-		Duration       time.Duration
-	} `validate:"required"`
+	Admin struct {
+		Username string
+		Password string
+	}
+	// every coin runs its jackpot with own wallet and background jobs, they share http server and database
+	Coins []coinConfiguration `validate:"required,min=1,dive"`
 }
 
+// wallet drivers
+const (
+	walletDriverCore      = "core"
+	walletDriverSimulated = "simulated"
+	walletDriverEsplora   = "esplora"
+)
+
+// network fee policies
+const (
+	networkFeePolicyHouse  = "house"
+	networkFeePolicyWinner = "winner"
+	networkFeePolicyCapped = "capped"
+)
+
 var config configuration
 
+// coin names go into api routes, e.g. /v1/doge/games
+var coinNamePattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// isJackpotAddress tells whether deposits paid to address take part in game
+func (c coinConfiguration) isJackpotAddress(address string) bool {
+	if address == c.Jackpot.DestAddress {
+		return true
+	}
+
+	for _, v := range c.Jackpot.ExtraAddresses {
+		if address == v {
+			return true
+		}
+	}
+	return false
+}
+
 func initConfig() {
 	// env config
 	viper.SetEnvPrefix("jackpot") // will turn into uppercase, e.g. JACKPOT_PORT
@@ -68,33 +147,167 @@ func initConfig() {
 	config.Log.Graylog.Level = viper.GetString("graylog_level")
 	config.Log.Graylog.Facility = viper.GetString("graylog_facility")
 
-	config.Wallet.Host = viper.GetString("wallet_rpc_host")
-	config.Wallet.Username = viper.GetString("wallet_rpc_username")
-	config.Wallet.Password = viper.GetString("wallet_rpc_password")
-	config.Wallet.MinConfirms = int64(viper.GetInt("wallet_min_confirms"))
-	config.Wallet.SentFromAccount = viper.GetString("wallet_sent_from_account")
-
-	config.Coin.Type = viper.GetString("coin_type")
-	config.Coin.Label = viper.GetString("coin_label")
-	config.Coin.TxURL = viper.GetString("coin_tx_url")
-	config.Coin.AddressURL = viper.GetString("coin_address_url")
-
 	config.DB.DataSourceName = viper.GetString("db_dsn")
 	config.DB.MaxOpenConns = viper.GetInt("db_max_open_conns")
 	config.DB.MaxIdleConns = viper.GetInt("db_max_idle_conns")
 
-	config.Jackpot.DestAddress = viper.GetString("dest_address")
-	config.Jackpot.TransactionFee = viper.GetFloat64("transaction_fee")
-	config.Jackpot.Duration = utils.Must(time.ParseDuration(viper.GetString("duration"))).(time.Duration)
+	config.Admin.Username = viper.GetString("admin_username")
+	config.Admin.Password = viper.GetString("admin_password")
+
+	// e.g. JACKPOT_COINS=doge,ltc, single coin of empty name if not set
+	names := splitList(viper.GetString("coins"))
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	for _, name := range names {
+		config.Coins = append(config.Coins, initCoinConfig(name))
+	}
 
 	// validate config
 	utils.Must(nil, validateConfiguration(config))
 }
 
+// initCoinConfig reads configuration of coin, key prefixed with coin name takes precedence,
+// e.g. JACKPOT_DOGE_DEST_ADDRESS over JACKPOT_DEST_ADDRESS, so that settings shared by coins are set once
+func initCoinConfig(name string) coinConfiguration {
+	key := func(k string) string {
+		if name != "" && viper.IsSet(name+"_"+k) {
+			return name + "_" + k
+		}
+		return k
+	}
+
+	c := coinConfiguration{Name: name}
+	viper.SetDefault("wallet_driver", walletDriverCore)
+	viper.SetDefault("wallet_simulated_block_interval", "1m")
+	c.Wallet.Driver = viper.GetString(key("wallet_driver"))
+	c.Wallet.Host = viper.GetString(key("wallet_rpc_host"))
+	c.Wallet.Username = viper.GetString(key("wallet_rpc_username"))
+	c.Wallet.Password = viper.GetString(key("wallet_rpc_password"))
+	c.Wallet.EsploraURL = viper.GetString(key("wallet_esplora_url"))
+	c.Wallet.MinConfirms = int64(viper.GetInt(key("wallet_min_confirms")))
+	viper.SetDefault("wallet_sender_policy", w.SenderPolicyLargestInput)
+	c.Wallet.SenderPolicy = viper.GetString(key("wallet_sender_policy"))
+	c.Wallet.SimulatedBlockInterval = utils.Must(time.ParseDuration(viper.GetString(key("wallet_simulated_block_interval")))).(time.Duration)
+	viper.SetDefault("wallet_poll_interval", "5s")
+	c.Wallet.NotifyHost = viper.GetString(key("wallet_notify_host"))
+	c.Wallet.NotifyCertificate = viper.GetString(key("wallet_notify_certificate"))
+	viper.SetDefault("wallet_sync_workers", 8)
+	viper.SetDefault("wallet_catch_up_threshold", 10)
+	c.Wallet.SyncWorkers = viper.GetInt(key("wallet_sync_workers"))
+	c.Wallet.CatchUpThreshold = int64(viper.GetInt(key("wallet_catch_up_threshold")))
+	viper.SetDefault("wallet_start_height", -1)
+	c.Wallet.StartHeight = int64(viper.GetInt(key("wallet_start_height")))
+	c.Wallet.StartHash = viper.GetString(key("wallet_start_hash"))
+	viper.SetDefault("wallet_fee_conf_target", 6)
+	c.Wallet.FeeConfTarget = int64(viper.GetInt(key("wallet_fee_conf_target")))
+	c.Wallet.PayoutBatching = viper.GetBool(key("wallet_payout_batching"))
+	viper.SetDefault("wallet_balance_check_interval", "5m")
+	c.Wallet.BalanceCheckInterval = utils.Must(time.ParseDuration(viper.GetString(key("wallet_balance_check_interval")))).(time.Duration)
+	viper.SetDefault("wallet_reserve_warning_ratio", 1.5)
+	viper.SetDefault("wallet_reserve_critical_ratio", 1.0)
+	c.Wallet.ReserveWarningRatio = viper.GetFloat64(key("wallet_reserve_warning_ratio"))
+	c.Wallet.ReserveCriticalRatio = viper.GetFloat64(key("wallet_reserve_critical_ratio"))
+	viper.SetDefault("wallet_sweep_interval", "1h")
+	c.Wallet.SweepInterval = utils.Must(time.ParseDuration(viper.GetString(key("wallet_sweep_interval")))).(time.Duration)
+	viper.SetDefault("wallet_mempool_poll_interval", "10s")
+	c.Wallet.MempoolPollInterval = utils.Must(time.ParseDuration(viper.GetString(key("wallet_mempool_poll_interval")))).(time.Duration)
+	c.Wallet.PollInterval = utils.Must(time.ParseDuration(viper.GetString(key("wallet_poll_interval")))).(time.Duration)
+
+	c.Coin.Type = viper.GetString(key("coin_type"))
+	c.Coin.Label = viper.GetString(key("coin_label"))
+	c.Coin.TxURL = viper.GetString(key("coin_tx_url"))
+	c.Coin.AddressURL = viper.GetString(key("coin_address_url"))
+
+	c.Jackpot.DestAddress = viper.GetString(key("dest_address"))
+	c.Jackpot.ExtraAddresses = splitList(viper.GetString(key("extra_addresses")))
+	c.Jackpot.MinBet = utils.Must(parseAmountConfig(key("min_bet"))).(models.Amount)
+	c.Jackpot.MaxBet = utils.Must(parseAmountConfig(key("max_bet"))).(models.Amount)
+	c.Jackpot.MaxBetPerAddress = utils.Must(parseAmountConfig(key("max_bet_per_address"))).(models.Amount)
+	c.Jackpot.TransactionFee = viper.GetFloat64(key("transaction_fee"))
+	c.Jackpot.Duration = utils.Must(time.ParseDuration(viper.GetString(key("duration")))).(time.Duration)
+	c.Jackpot.RoundBlocks = int64(viper.GetInt(key("round_blocks")))
+	viper.SetDefault("network_fee_policy", networkFeePolicyHouse)
+	c.Jackpot.NetworkFeePolicy = viper.GetString(key("network_fee_policy"))
+	c.Jackpot.NetworkFeeCap = utils.Must(parseAmountConfig(key("network_fee_cap"))).(models.Amount)
+	c.Jackpot.ColdAddress = viper.GetString(key("cold_address"))
+	c.Jackpot.SweepThreshold = utils.Must(parseAmountConfig(key("sweep_threshold"))).(models.Amount)
+
+	return c
+}
+
 func validateConfiguration(c configuration) error {
 	validate := validator.New(&validator.Config{TagName: "validate"})
 	utils.Must(nil, validate.RegisterValidation("dsn", dsnValidator))
-	return validate.Struct(c)
+	if err := validate.Struct(c); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, coin := range c.Coins {
+		if (len(c.Coins) > 1 || coin.Name != "") && !coinNamePattern.MatchString(coin.Name) {
+			return fmt.Errorf("coin name %q must be lowercase letters and digits", coin.Name)
+		}
+
+		if names[coin.Name] {
+			return fmt.Errorf("coin %q is configured more than once", coin.Name)
+		}
+		names[coin.Name] = true
+
+		if err := validateCoinConfiguration(coin); err != nil {
+			return fmt.Errorf("coin %q: %v", coin.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func validateCoinConfiguration(c coinConfiguration) error {
+	if c.Wallet.Driver == walletDriverCore && (c.Wallet.Host == "" || c.Wallet.Username == "" || c.Wallet.Password == "") {
+		return errors.New("wallet rpc host, username and password are required by core wallet")
+	}
+
+	if c.Wallet.Driver == walletDriverEsplora && c.Wallet.EsploraURL == "" {
+		return errors.New("esplora url is required by esplora wallet")
+	}
+
+	if c.Jackpot.MaxBet > 0 && c.Jackpot.MaxBet < c.Jackpot.MinBet {
+		return errors.New("max bet must not be less than min bet")
+	}
+
+	if c.Jackpot.MaxBetPerAddress > 0 && c.Jackpot.MaxBetPerAddress < c.Jackpot.MinBet {
+		return errors.New("max bet per address must not be less than min bet")
+	}
+
+	if c.Jackpot.NetworkFeePolicy == networkFeePolicyCapped && c.Jackpot.NetworkFeeCap == 0 {
+		return errors.New("network fee cap is required by capped network fee policy")
+	}
+
+	if c.Jackpot.ColdAddress != "" && c.Jackpot.SweepThreshold == 0 {
+		return errors.New("sweep threshold is required by sweeping to cold address")
+	}
+
+	// fees swept to jackpot address would be deposits
+	if c.Jackpot.ColdAddress != "" && c.isJackpotAddress(c.Jackpot.ColdAddress) {
+		return errors.New("cold address must not be a jackpot address")
+	}
+
+	return nil
+}
+
+// splitList splits list separated by commas or spaces, e.g. addresses
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// parseAmountConfig parses coins amount, e.g. 0.5, empty value is zero
+func parseAmountConfig(key string) (models.Amount, error) {
+	if v := viper.GetString(key); v != "" {
+		return models.ParseAmount(v)
+	}
+	return 0, nil
 }
 
 func dsnValidator(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
@@ -0,0 +1,10 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `blocks` ADD COLUMN `orphaned` TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'whether block is no longer on the main chain';
+ALTER TABLE `blocks` DROP INDEX `height`, ADD INDEX (`height`, `orphaned`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `blocks` DROP INDEX `height`, ADD UNIQUE INDEX (`height`);
+ALTER TABLE `blocks` DROP COLUMN `orphaned`;
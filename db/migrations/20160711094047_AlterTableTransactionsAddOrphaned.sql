@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `transactions` ADD COLUMN `orphaned` TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'whether transaction belongs to an orphaned block';
+ALTER TABLE `transactions` ADD INDEX (`orphaned`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `transactions` DROP COLUMN `orphaned`;
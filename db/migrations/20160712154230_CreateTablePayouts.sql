@@ -0,0 +1,26 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE `payouts` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `game_of` DATETIME NOT NULL COMMENT 'game of time',
+  `idempotency_key` VARCHAR(255) NOT NULL COMMENT 'key attached to the payout as wallet comment',
+  `address` VARCHAR(255) NOT NULL COMMENT 'winner address',
+  `amount` DECIMAL(19, 8) NOT NULL COMMENT 'payout amount',
+  `tx_id` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'payout transaction id',
+  `status` VARCHAR(255) NOT NULL DEFAULT 'pending' COMMENT 'payout status',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE `payouts`
+ADD UNIQUE INDEX (`game_of`),
+ADD UNIQUE INDEX (`idempotency_key`),
+ADD INDEX (`status`),
+ADD INDEX (`tx_id`),
+ADD INDEX (`created_at`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `payouts`;
@@ -0,0 +1,27 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE `excluded_transactions` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `address` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'sender address, empty if unattributed',
+  `amount` DECIMAL(19, 8) NOT NULL COMMENT 'transaction amount',
+  `tx_id` VARCHAR(255) NOT NULL COMMENT 'transaction id',
+  `hash` VARCHAR(255) NOT NULL COMMENT 'block hash',
+  `reason` VARCHAR(255) NOT NULL COMMENT 'why transaction is excluded from games',
+  `detail` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'detail of reason',
+  `block_created_at` DATETIME NOT NULL COMMENT 'block created at',
+  `orphaned` TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'whether transaction belongs to an orphaned block',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE `excluded_transactions`
+ADD INDEX (`tx_id`),
+ADD INDEX (`hash`),
+ADD INDEX (`reason`),
+ADD INDEX (`orphaned`),
+ADD INDEX (`created_at`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `excluded_transactions`;
@@ -0,0 +1,19 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE `mempool_transactions` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `address` VARCHAR(255) NOT NULL COMMENT 'sender address',
+  `amount` DECIMAL(19, 8) NOT NULL COMMENT 'transaction amount',
+  `tx_id` VARCHAR(255) NOT NULL COMMENT 'transaction id',
+  `received_at` DATETIME NOT NULL COMMENT 'time wallet received transaction',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE `mempool_transactions`
+ADD UNIQUE (`tx_id`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `mempool_transactions`;
@@ -0,0 +1,20 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `transactions` ADD COLUMN `vout` INT(11) UNSIGNED NOT NULL DEFAULT 0 COMMENT 'index of output paying to wallet' AFTER `tx_id`;
+ALTER TABLE `transactions` ADD COLUMN `output_address` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'wallet address the output pays to' AFTER `vout`;
+ALTER TABLE `transactions` ADD COLUMN `legacy_vout` TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'vout is synthetic, row is matched by address and amount until its output is seen again' AFTER `output_address`;
+-- rows stored before outputs were tracked are numbered down from the largest vout within their transaction
+-- to satisfy the unique index without taking vouts of real outputs, they get real vouts when saved again
+SET @vout := 0, @tx_id := '';
+UPDATE `transactions` SET `vout` = (@vout := IF(@tx_id = `tx_id`, @vout - 1, 4294967295)), `tx_id` = (@tx_id := `tx_id`), `legacy_vout` = 1 ORDER BY `tx_id`, `id`;
+ALTER TABLE `transactions` DROP INDEX `tx_id`;
+ALTER TABLE `transactions` ADD UNIQUE INDEX `tx_id` (`tx_id`, `vout`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `transactions` DROP INDEX `tx_id`;
+ALTER TABLE `transactions` ADD INDEX (`tx_id`);
+ALTER TABLE `transactions` DROP COLUMN `legacy_vout`;
+ALTER TABLE `transactions` DROP COLUMN `output_address`;
+ALTER TABLE `transactions` DROP COLUMN `vout`;
@@ -0,0 +1,17 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `excluded_transactions` ADD COLUMN `vout` INT(11) UNSIGNED NOT NULL DEFAULT 0 COMMENT 'index of output paying to wallet' AFTER `tx_id`;
+ALTER TABLE `excluded_transactions` ADD COLUMN `output_address` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'wallet address the output pays to' AFTER `vout`;
+-- rows stored before outputs were tracked are numbered within their transaction to satisfy the unique index
+SET @vout := 0, @tx_id := '';
+UPDATE `excluded_transactions` SET `vout` = (@vout := IF(@tx_id = `tx_id`, @vout + 1, 0)), `tx_id` = (@tx_id := `tx_id`) ORDER BY `tx_id`, `id`;
+ALTER TABLE `excluded_transactions` DROP INDEX `tx_id`;
+ALTER TABLE `excluded_transactions` ADD UNIQUE INDEX `tx_id` (`tx_id`, `vout`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `excluded_transactions` DROP INDEX `tx_id`;
+ALTER TABLE `excluded_transactions` ADD INDEX (`tx_id`);
+ALTER TABLE `excluded_transactions` DROP COLUMN `output_address`;
+ALTER TABLE `excluded_transactions` DROP COLUMN `vout`;
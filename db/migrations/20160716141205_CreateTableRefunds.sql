@@ -0,0 +1,35 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE `refunds` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `game_of` DATETIME NOT NULL COMMENT 'game of time the deposit falls in',
+  `idempotency_key` VARCHAR(255) NOT NULL COMMENT 'key attached to the refund as wallet comment',
+  `address` VARCHAR(255) NOT NULL COMMENT 'sender address of deposit',
+  `amount` DECIMAL(19, 8) NOT NULL COMMENT 'refund amount',
+  `reason` VARCHAR(255) NOT NULL COMMENT 'why deposit is refunded',
+  `deposit_tx_id` VARCHAR(255) NOT NULL COMMENT 'deposit transaction id',
+  `deposit_vout` INT(11) UNSIGNED NOT NULL COMMENT 'deposit output index',
+  `hash` VARCHAR(255) NOT NULL COMMENT 'block hash of deposit',
+  `height` BIGINT(20) NOT NULL COMMENT 'block height of deposit',
+  `tx_id` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'refund transaction id',
+  `status` VARCHAR(255) NOT NULL DEFAULT 'pending' COMMENT 'refund status',
+  `orphaned` TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'whether deposit belongs to an orphaned block',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE `refunds`
+ADD UNIQUE INDEX (`deposit_tx_id`, `deposit_vout`),
+ADD UNIQUE INDEX (`idempotency_key`),
+ADD INDEX (`game_of`),
+ADD INDEX (`hash`),
+ADD INDEX (`height`),
+ADD INDEX (`status`),
+ADD INDEX (`orphaned`),
+ADD INDEX (`created_at`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `refunds`;
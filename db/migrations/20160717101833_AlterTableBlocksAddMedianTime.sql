@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `blocks` ADD COLUMN `median_time` DATETIME NOT NULL DEFAULT '1970-01-01 00:00:01' COMMENT 'median time past, used to assign block to game' AFTER `block_created_at`;
+UPDATE `blocks` SET `median_time` = `block_created_at`;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `blocks` DROP COLUMN `median_time`;
@@ -0,0 +1,11 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `games`
+ADD COLUMN `start_height` INT(11) NOT NULL DEFAULT 0 COMMENT 'height of the first block in game' AFTER `height`,
+ADD COLUMN `end_height` INT(11) NOT NULL DEFAULT 0 COMMENT 'height of the last block in game' AFTER `start_height`,
+ADD INDEX (`start_height`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `games` DROP COLUMN `start_height`, DROP COLUMN `end_height`;
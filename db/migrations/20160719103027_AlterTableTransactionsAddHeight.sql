@@ -0,0 +1,11 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `transactions`
+ADD COLUMN `height` INT(11) NOT NULL DEFAULT 0 COMMENT 'height of block the output is mined in' AFTER `hash`,
+ADD INDEX (`height`);
+UPDATE `transactions` JOIN `blocks` ON `transactions`.`hash` = `blocks`.`hash` SET `transactions`.`height` = `blocks`.`height`;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `transactions` DROP COLUMN `height`;
@@ -0,0 +1,71 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- rows stored before coins were namespaced keep empty coin, which is the coin of single coin deployment
+ALTER TABLE `blocks`
+ADD COLUMN `coin` VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'coin the row belongs to' AFTER `id`,
+DROP INDEX `hash`, ADD UNIQUE INDEX `hash` (`coin`, `hash`),
+DROP INDEX `height`, ADD INDEX `height` (`coin`, `height`, `orphaned`);
+
+ALTER TABLE `transactions`
+ADD COLUMN `coin` VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'coin the row belongs to' AFTER `id`,
+DROP INDEX `tx_id`, ADD UNIQUE INDEX `tx_id` (`coin`, `tx_id`, `vout`),
+ADD INDEX (`coin`, `game_of`);
+
+ALTER TABLE `games`
+ADD COLUMN `coin` VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'coin the row belongs to' AFTER `id`,
+DROP INDEX `game_of`, ADD UNIQUE INDEX `game_of` (`coin`, `game_of`),
+DROP INDEX `height`, ADD UNIQUE INDEX `height` (`coin`, `height`, `hash`, `game_of`);
+
+ALTER TABLE `payouts`
+ADD COLUMN `coin` VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'coin the row belongs to' AFTER `id`,
+DROP INDEX `game_of`, ADD UNIQUE INDEX `game_of` (`coin`, `game_of`),
+DROP INDEX `idempotency_key`, ADD UNIQUE INDEX `idempotency_key` (`coin`, `idempotency_key`);
+
+ALTER TABLE `excluded_transactions`
+ADD COLUMN `coin` VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'coin the row belongs to' AFTER `id`,
+DROP INDEX `tx_id`, ADD UNIQUE INDEX `tx_id` (`coin`, `tx_id`, `vout`);
+
+ALTER TABLE `mempool_transactions`
+ADD COLUMN `coin` VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'coin the row belongs to' AFTER `id`,
+DROP INDEX `tx_id`, ADD UNIQUE INDEX `tx_id` (`coin`, `tx_id`);
+
+ALTER TABLE `refunds`
+ADD COLUMN `coin` VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'coin the row belongs to' AFTER `id`,
+DROP INDEX `deposit_tx_id`, ADD UNIQUE INDEX `deposit_tx_id` (`coin`, `deposit_tx_id`, `deposit_vout`),
+DROP INDEX `idempotency_key`, ADD UNIQUE INDEX `idempotency_key` (`coin`, `idempotency_key`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `refunds`
+DROP INDEX `deposit_tx_id`, ADD UNIQUE INDEX `deposit_tx_id` (`deposit_tx_id`, `deposit_vout`),
+DROP INDEX `idempotency_key`, ADD UNIQUE INDEX `idempotency_key` (`idempotency_key`),
+DROP COLUMN `coin`;
+
+ALTER TABLE `mempool_transactions`
+DROP INDEX `tx_id`, ADD UNIQUE INDEX `tx_id` (`tx_id`),
+DROP COLUMN `coin`;
+
+ALTER TABLE `excluded_transactions`
+DROP INDEX `tx_id`, ADD UNIQUE INDEX `tx_id` (`tx_id`, `vout`),
+DROP COLUMN `coin`;
+
+ALTER TABLE `payouts`
+DROP INDEX `game_of`, ADD UNIQUE INDEX `game_of` (`game_of`),
+DROP INDEX `idempotency_key`, ADD UNIQUE INDEX `idempotency_key` (`idempotency_key`),
+DROP COLUMN `coin`;
+
+ALTER TABLE `games`
+DROP INDEX `game_of`, ADD UNIQUE INDEX `game_of` (`game_of`),
+DROP INDEX `height`, ADD UNIQUE INDEX `height` (`height`, `hash`, `game_of`),
+DROP COLUMN `coin`;
+
+ALTER TABLE `transactions`
+DROP INDEX `tx_id`, ADD UNIQUE INDEX `tx_id` (`tx_id`, `vout`),
+DROP INDEX `coin`,
+DROP COLUMN `coin`;
+
+ALTER TABLE `blocks`
+DROP INDEX `hash`, ADD UNIQUE INDEX `hash` (`hash`),
+DROP INDEX `height`, ADD INDEX `height` (`height`, `orphaned`),
+DROP COLUMN `coin`;
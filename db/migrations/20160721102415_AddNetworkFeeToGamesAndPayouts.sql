@@ -0,0 +1,16 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- games ended before network fee was recorded keep zero network fee and net amount
+ALTER TABLE `games`
+ADD COLUMN `net_amount` DECIMAL(19, 8) NOT NULL DEFAULT 0 COMMENT 'amount winner receives after network fee' AFTER `fee`,
+ADD COLUMN `network_fee` DECIMAL(19, 8) NOT NULL DEFAULT 0 COMMENT 'network fee paid by payout transaction' AFTER `net_amount`;
+
+ALTER TABLE `payouts`
+ADD COLUMN `network_fee` DECIMAL(19, 8) NOT NULL DEFAULT 0 COMMENT 'network fee paid by payout transaction' AFTER `amount`;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `payouts` DROP COLUMN `network_fee`;
+
+ALTER TABLE `games` DROP COLUMN `network_fee`, DROP COLUMN `net_amount`;
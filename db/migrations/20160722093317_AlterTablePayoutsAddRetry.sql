@@ -0,0 +1,23 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- pending payouts may have been sent before a crash, they are looked up in wallet before sending anyway
+ALTER TABLE `payouts`
+MODIFY COLUMN `status` VARCHAR(255) NOT NULL DEFAULT 'queued' COMMENT 'payout status',
+ADD COLUMN `attempts` INT(11) NOT NULL DEFAULT 0 COMMENT 'number of attempts to send payout' AFTER `status`,
+ADD COLUMN `last_error` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'error of last failed attempt' AFTER `attempts`,
+ADD COLUMN `next_attempt_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'failed payout is not retried before' AFTER `last_error`,
+ADD INDEX (`next_attempt_at`);
+UPDATE `payouts` SET `status` = 'queued' WHERE `status` = 'pending';
+UPDATE `payouts` SET `status` = 'broadcast' WHERE `status` = 'sent';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+UPDATE `payouts` SET `status` = 'sent' WHERE `status` IN ('broadcast', 'confirmed');
+UPDATE `payouts` SET `status` = 'pending' WHERE `status` != 'sent';
+ALTER TABLE `payouts`
+MODIFY COLUMN `status` VARCHAR(255) NOT NULL DEFAULT 'pending' COMMENT 'payout status',
+DROP INDEX `next_attempt_at`,
+DROP COLUMN `next_attempt_at`,
+DROP COLUMN `last_error`,
+DROP COLUMN `attempts`;
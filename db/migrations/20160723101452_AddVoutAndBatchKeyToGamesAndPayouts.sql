@@ -0,0 +1,15 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `games`
+ADD COLUMN `vout` INT(11) UNSIGNED NOT NULL DEFAULT 0 COMMENT 'output index of payout in payout transaction' AFTER `tx_id`;
+
+ALTER TABLE `payouts`
+ADD COLUMN `batch_key` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'key attached to batch the payout is sent in as wallet comment' AFTER `idempotency_key`,
+ADD COLUMN `vout` INT(11) UNSIGNED NOT NULL DEFAULT 0 COMMENT 'output index of payout in payout transaction' AFTER `tx_id`;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `payouts` DROP COLUMN `vout`, DROP COLUMN `batch_key`;
+
+ALTER TABLE `games` DROP COLUMN `vout`;
@@ -0,0 +1,26 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE `sweeps` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `coin` VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'coin the row belongs to',
+  `idempotency_key` VARCHAR(255) NOT NULL COMMENT 'key attached to the sweep as wallet comment',
+  `address` VARCHAR(255) NOT NULL COMMENT 'cold address',
+  `amount` DECIMAL(19, 8) NOT NULL COMMENT 'house fees swept',
+  `network_fee` DECIMAL(19, 8) NOT NULL DEFAULT 0 COMMENT 'network fee paid by sweep transaction',
+  `tx_id` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'sweep transaction id',
+  `status` VARCHAR(255) NOT NULL DEFAULT 'pending' COMMENT 'sweep status',
+  `last_error` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'error of the failed sweep',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE `sweeps`
+ADD UNIQUE INDEX `idempotency_key` (`coin`, `idempotency_key`),
+ADD INDEX (`coin`, `status`),
+ADD INDEX (`created_at`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `sweeps`;
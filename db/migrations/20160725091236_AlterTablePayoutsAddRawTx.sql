@@ -0,0 +1,11 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- payout left broadcasting before transactions were recorded may have been sent, so it is failed for operators to check
+ALTER TABLE `payouts`
+ADD COLUMN `raw_tx` MEDIUMTEXT NOT NULL COMMENT 'signed payout transaction recorded before it is broadcast' AFTER `vout`;
+UPDATE `payouts` SET `status` = 'failed_permanent', `last_error` = 'left broadcasting before payout transactions were recorded, check wallet before requeueing' WHERE `status` = 'broadcasting';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `payouts` DROP COLUMN `raw_tx`;
@@ -0,0 +1,11 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- pending sweep may have been sent before transactions were recorded, so it is failed, sweeping surplus only covers the rest
+ALTER TABLE `sweeps`
+ADD COLUMN `raw_tx` MEDIUMTEXT NOT NULL COMMENT 'signed sweep transaction recorded before it is broadcast' AFTER `tx_id`;
+UPDATE `sweeps` SET `status` = 'failed', `last_error` = 'pending before sweep transactions were recorded, check wallet' WHERE `status` = 'pending';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `sweeps` DROP COLUMN `raw_tx`;
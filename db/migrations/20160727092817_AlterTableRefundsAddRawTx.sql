@@ -0,0 +1,10 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- pending refunds are funded and recorded from now on, check wallet for ones sent by a crashed round before upgrading
+ALTER TABLE `refunds`
+ADD COLUMN `raw_tx` MEDIUMTEXT NOT NULL COMMENT 'signed refund transaction recorded before it is broadcast' AFTER `tx_id`;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE `refunds` DROP COLUMN `raw_tx`;
@@ -0,0 +1,21 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ByCoin handler dispatches request to handler of coin in path parameter coin,
+// coin is empty if route has no such parameter, unknown coins are not found
+func ByCoin(handlers map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler, ok := handlers[c.Param("coin")]
+		if !ok {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		handler(c)
+	}
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestByCoin(t *testing.T) {
+	Convey("Given by coin handler with handlers of doge and ltc", t, func() {
+		handler := ByCoin(map[string]gin.HandlerFunc{
+			"doge": func(c *gin.Context) { c.String(http.StatusOK, "doge") },
+			"ltc":  func(c *gin.Context) { c.String(http.StatusOK, "ltc") },
+		})
+
+		Convey("When request games of ltc", func() {
+			_, resp, r := gin.CreateTestContext()
+			r.GET("/:coin/games", handler)
+			req, _ := http.NewRequest("GET", "/ltc/games", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Handler of ltc should respond", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldEqual, "ltc")
+			})
+		})
+
+		Convey("When request games of unknown coin", func() {
+			_, resp, r := gin.CreateTestContext()
+			r.GET("/:coin/games", handler)
+			req, _ := http.NewRequest("GET", "/btc/games", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 404", func() {
+				So(resp.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+
+	Convey("Given by coin handler with handler of single coin deployment", t, func() {
+		handler := ByCoin(map[string]gin.HandlerFunc{
+			"": func(c *gin.Context) { c.String(http.StatusOK, "single") },
+		})
+
+		Convey("When request games without coin in path", func() {
+			_, resp, r := gin.CreateTestContext()
+			r.GET("/games", handler)
+			req, _ := http.NewRequest("GET", "/games", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Handler of the coin should respond", func() {
+				So(resp.Body.String(), ShouldEqual, "single")
+			})
+		})
+	})
+}
@@ -9,4 +9,12 @@ import (
 type (
 	dependencyGetGames                 func(limit, offset int64) ([]models.Game, error)
 	dependencyGetTransactionsByGameOfs func(gameOfs ...time.Time) ([]models.Transaction, error)
+	dependencyGetExcludedTransactions  func(limit, offset int64) ([]models.ExcludedTransaction, error)
+	dependencyGetMempoolTransactions   func() ([]models.MempoolTransaction, error)
+	dependencyGetRefundsByGameOfs      func(gameOfs ...time.Time) ([]models.Refund, error)
+	dependencyGetCurrentGameOf         func() (time.Time, error)
+	dependencyGetUnsentPayouts         func() ([]models.Payout, error)
+	dependencyRequeuePayout            func(id int64) error
+	dependencyGetReserve               func() (models.Reserve, error)
+	dependencyGetSweeps                func(limit, offset int64) ([]models.Sweep, error)
 )
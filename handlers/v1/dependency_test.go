@@ -12,8 +12,56 @@ func mockDependencyGetGames(games []models.Game, err error) dependencyGetGames {
 	}
 }
 
+func mockDependencyGetExcludedTransactions(transactions []models.ExcludedTransaction, err error) dependencyGetExcludedTransactions {
+	return func(_, _ int64) ([]models.ExcludedTransaction, error) {
+		return transactions, err
+	}
+}
+
+func mockDependencyGetMempoolTransactions(transactions []models.MempoolTransaction, err error) dependencyGetMempoolTransactions {
+	return func() ([]models.MempoolTransaction, error) {
+		return transactions, err
+	}
+}
+
+func mockDependencyGetRefundsByGameOfs(refunds []models.Refund, err error) dependencyGetRefundsByGameOfs {
+	return func(...time.Time) ([]models.Refund, error) {
+		return refunds, err
+	}
+}
+
+func mockDependencyGetCurrentGameOf(gameOf time.Time, err error) dependencyGetCurrentGameOf {
+	return func() (time.Time, error) {
+		return gameOf, err
+	}
+}
+
 func mockDependencyGetTransactionsByGameOfs(transactions []models.Transaction, err error) dependencyGetTransactionsByGameOfs {
 	return func(...time.Time) ([]models.Transaction, error) {
 		return transactions, err
 	}
 }
+
+func mockDependencyGetUnsentPayouts(payouts []models.Payout, err error) dependencyGetUnsentPayouts {
+	return func() ([]models.Payout, error) {
+		return payouts, err
+	}
+}
+
+func mockDependencyGetReserve(reserve models.Reserve, err error) dependencyGetReserve {
+	return func() (models.Reserve, error) {
+		return reserve, err
+	}
+}
+
+func mockDependencyGetSweeps(sweeps []models.Sweep, err error) dependencyGetSweeps {
+	return func(int64, int64) ([]models.Sweep, error) {
+		return sweeps, err
+	}
+}
+
+func mockDependencyRequeuePayout(err error) dependencyRequeuePayout {
+	return func(int64) error {
+		return err
+	}
+}
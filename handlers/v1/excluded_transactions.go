@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+type excludedTransactionResponse struct {
+	Address        string        `json:"address"`
+	Amount         models.Amount `json:"amount"`
+	TransactionID  string        `json:"tx_id"`
+	TransactionURL string        `json:"tx_url"`
+	Hash           string        `json:"hash"`
+	Reason         string        `json:"reason"`
+	Detail         string        `json:"detail"`
+	ReceivedAt     time.Time     `json:"received_at"`
+}
+
+type adminListPayload struct {
+	Limit  int64 `form:"limit" binding:"required,min=1,max=100"`
+	Offset int64 `form:"offset" binding:"omitempty,min=0"`
+}
+
+// ExcludedTransactions handler lists received transactions that take part in no game,
+// operators review and refund them
+func ExcludedTransactions(getExcludedTransactions dependencyGetExcludedTransactions, blockchainTxURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := adminListPayload{}
+		if err := c.BindWith(&p, binding.Form); err != nil {
+			return
+		}
+
+		transactions, err := getExcludedTransactions(p.Limit, p.Offset)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, constructExcludedTransactionsResponse(transactions, blockchainTxURL))
+	}
+}
+
+func constructExcludedTransactionsResponse(transactions []models.ExcludedTransaction, blockchainTxURL string) []excludedTransactionResponse {
+	response := make([]excludedTransactionResponse, len(transactions))
+	for i, v := range transactions {
+		response[i] = excludedTransactionResponse{
+			Address:        v.Address,
+			Amount:         v.Amount,
+			TransactionID:  v.TransactionID,
+			TransactionURL: paymentProofWithTxID(blockchainTxURL, v.TransactionID),
+			Hash:           v.Hash,
+			Reason:         v.Reason,
+			Detail:         v.Detail,
+			ReceivedAt:     v.BlockCreatedAt,
+		}
+	}
+	return response
+}
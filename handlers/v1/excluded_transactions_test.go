@@ -0,0 +1,73 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestExcludedTransactions(t *testing.T) {
+	Convey("Given excluded transactions handler with errored get excluded transactions within", t, func() {
+		handler := ExcludedTransactions(mockDependencyGetExcludedTransactions(nil, fmt.Errorf("")), "")
+
+		Convey("When request excluded transactions handler", func() {
+			route := "/excluded_transactions"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/excluded_transactions?limit=10", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 500", func() {
+				So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+
+	Convey("Given excluded transactions handler with everything correct", t, func() {
+		handler := ExcludedTransactions(mockDependencyGetExcludedTransactions([]models.ExcludedTransaction{{}}, nil), "")
+
+		Convey("When request excluded transactions handler without limit", func() {
+			route := "/excluded_transactions"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/excluded_transactions", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 400", func() {
+				So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When request excluded transactions handler", func() {
+			route := "/excluded_transactions"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/excluded_transactions?limit=10", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 200", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
+
+func TestConstructExcludedTransactionsResponse(t *testing.T) {
+	transactions := []models.ExcludedTransaction{
+		{Amount: 10, TransactionID: "tx_id_1", Reason: models.ExcludedReasonUnattributed, Detail: "coinbase input"},
+	}
+
+	actual := constructExcludedTransactionsResponse(transactions, "url/")
+	expected := []excludedTransactionResponse{
+		{Amount: 10, TransactionID: "tx_id_1", TransactionURL: "url/tx_id_1", Reason: models.ExcludedReasonUnattributed, Detail: "coinbase input"},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("construct excluded transactions response expected \n%#v but get \n%#v", expected, actual)
+	}
+}
@@ -7,33 +7,53 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/solefaucet/jackpot-server/jerrors"
 	"github.com/solefaucet/jackpot-server/models"
 )
 
 type gamesResponse struct {
 	DestAddress    string         `json:"dest_address"`
 	DestAddressURL string         `json:"dest_address_url"`
-	Duration       int64          `json:"duration"`
+	Duration       int64          `json:"duration,omitempty"`
+	RoundBlocks    int64          `json:"round_blocks,omitempty"`
 	QRCode         string         `json:"qrcode"`
-	JackpotAmount  float64        `json:"jackpot_amout"`
-	NextGameTime   time.Time      `json:"next_game_time"`
+	JackpotAmount  models.Amount  `json:"jackpot_amout"`
+	NextGameTime   *time.Time     `json:"next_game_time,omitempty"`
+	NextGameHeight *int64         `json:"next_game_height,omitempty"`
 	Games          []gameResponse `json:"games"`
 }
 
 type gameResponse struct {
 	GameOf          time.Time          `json:"game_of"`
 	PaymentProofURL string             `json:"payment_proof_url"`
+	PaymentVout     uint32             `json:"payment_vout"` // output paying winner, batched payouts share transaction
 	WinnerAddress   string             `json:"winner_address"`
 	Hash            string             `json:"hash"`
-	JackpotAmount   float64            `json:"jackpot_amount"`
+	StartHeight     int64              `json:"start_height"`
+	EndHeight       int64              `json:"end_height"`
+	JackpotAmount   models.Amount      `json:"jackpot_amount"`
+	NetAmount       models.Amount      `json:"net_amount"`  // amount winner receives after network fee
+	NetworkFee      models.Amount      `json:"network_fee"` // network fee of payout transaction
 	Records         map[string]*record `json:"records"`
+	Refunds         []refundResponse   `json:"refunds"`
+}
+
+type refundResponse struct {
+	Address              string        `json:"address"`
+	Amount               models.Amount `json:"amount"`
+	Reason               string        `json:"reason"`
+	DepositTransactionID string        `json:"deposit_tx_id"`
+	TransactionID        string        `json:"refund_tx_id"`
+	TransactionURL       string        `json:"refund_tx_url"`
 }
 
 type record struct {
-	Confirmations  int64     `json:"confirmations"`
-	Amount         float64   `json:"amount"`
-	WinProbability float64   `json:"win_probability"`
-	ReceivedAt     time.Time `json:"received_at"`
+	Confirmations  int64         `json:"confirmations"`
+	Amount         models.Amount `json:"amount"`
+	PendingAmount  models.Amount `json:"pending_amount"`
+	Pending        bool          `json:"pending"`
+	WinProbability float64       `json:"win_probability"`
+	ReceivedAt     time.Time     `json:"received_at"`
 }
 
 type gamePayload struct {
@@ -41,12 +61,16 @@ type gamePayload struct {
 	Offset int64 `form:"offset" binding:"omitempty,min=0"`
 }
 
-// Games handler
+// Games handler, games span duration or roundBlocks blocks if it is positive
 func Games(
 	getGames dependencyGetGames,
 	getTransactionsByGameOfs dependencyGetTransactionsByGameOfs,
+	getMempoolTransactions dependencyGetMempoolTransactions,
+	getRefundsByGameOfs dependencyGetRefundsByGameOfs,
+	getCurrentGameOf dependencyGetCurrentGameOf,
 	destAddress string,
 	duration time.Duration,
+	roundBlocks int64,
 	fee float64,
 	blockchainTxURL, blockchainAddressURL, coinType, label string,
 ) gin.HandlerFunc {
@@ -57,7 +81,6 @@ func Games(
 		}
 
 		// get current jackpot amount
-		now := time.Now()
 		jackpotAmount := getCurrentJackpotAmount(getGames, fee)
 
 		// get games and transactions
@@ -73,20 +96,53 @@ func Games(
 			return
 		}
 
+		refunds, err := getRefundsByGameOfs(gameOfs(games)...)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
 		// parse result
 		transactionMap := constructTransactionMap(transactions)
-		gs := constructGamesResponse(games, transactionMap, fee, blockchainTxURL)
+		refundMap := constructRefundMap(refunds, blockchainTxURL)
+		gs := constructGamesResponse(games, transactionMap, refundMap, fee, blockchainTxURL)
 
 		response := gamesResponse{
 			Games:          gs,
-			Duration:       duration.Nanoseconds() / 1e9,
 			DestAddress:    destAddress,
 			DestAddressURL: blockchainAddressURL + destAddress,
 			JackpotAmount:  jackpotAmount,
-			NextGameTime:   now.Truncate(duration).Add(duration),
 			QRCode:         fmt.Sprintf("%s:%s?label=%s", coinType, destAddress, label),
 		}
 
+		// next game is known by height if games span fixed number of blocks,
+		// otherwise by median time past of the latest block as blocks are assigned to games
+		currentGameOf := time.Time{}
+		if roundBlocks > 0 {
+			response.RoundBlocks = roundBlocks
+			if game, ok := getLatestGame(getGames); ok {
+				currentGameOf = game.GameOf
+				nextGameHeight := game.EndHeight + 1
+				response.NextGameHeight = &nextGameHeight
+			}
+		} else {
+			currentGameOf, err = getCurrentGameOf()
+			if err != nil && err != jerrors.ErrNotFound {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+
+			response.Duration = duration.Nanoseconds() / 1e9
+			if !currentGameOf.IsZero() {
+				nextGameTime := currentGameOf.Add(duration)
+				response.NextGameTime = &nextGameTime
+			}
+		}
+		// current game is the newest one, it is only on the first page
+		if p.Offset == 0 {
+			response.Games = addPendingRecords(gs, currentGameOf, getMempoolTransactionsOrNone(getMempoolTransactions))
+		}
+
 		// response
 		c.JSON(http.StatusOK, response)
 	}
@@ -100,17 +156,35 @@ func gameOfs(games []models.Game) []time.Time {
 	return gameOfs
 }
 
-func getCurrentJackpotAmount(getGames dependencyGetGames, fee float64) float64 {
+func getCurrentJackpotAmount(getGames dependencyGetGames, fee float64) models.Amount {
+	if game, ok := getLatestGame(getGames); ok {
+		return jackpotAmountAfterFee(game.TotalAmount, fee)
+	}
+
+	return 0
+}
+
+// getLatestGame returns the latest game, false if there is none or it fails to get games
+func getLatestGame(getGames dependencyGetGames) (models.Game, bool) {
 	games, err := getGames(1, 0)
-	if err != nil {
-		return 0.0
+	if err != nil || len(games) == 0 {
+		return models.Game{}, false
 	}
 
-	if len(games) > 0 {
-		return games[0].TotalAmount * (1 - fee)
+	return games[0], true
+}
+
+// mempool transactions are provisional, failing to get them should not fail the whole response
+func getMempoolTransactionsOrNone(getMempoolTransactions dependencyGetMempoolTransactions) []models.MempoolTransaction {
+	transactions, err := getMempoolTransactions()
+	if err != nil {
+		return nil
 	}
+	return transactions
+}
 
-	return 0.0
+func jackpotAmountAfterFee(totalAmount models.Amount, fee float64) models.Amount {
+	return totalAmount - totalAmount.MulRate(fee)
 }
 
 func constructTransactionMap(transactions []models.Transaction) map[time.Time]map[string]*record {
@@ -131,13 +205,65 @@ func constructTransactionMap(transactions []models.Transaction) map[time.Time]ma
 	return transactionMap
 }
 
-func calculateWinProbability(recordMap map[string]*record, totalAmount float64) map[string]*record {
+func constructRefundMap(refunds []models.Refund, blockchainTxURL string) map[time.Time][]refundResponse {
+	refundMap := make(map[time.Time][]refundResponse)
+	for _, v := range refunds {
+		refundMap[v.GameOf] = append(refundMap[v.GameOf], refundResponse{
+			Address:              v.Address,
+			Amount:               v.Amount,
+			Reason:               v.Reason,
+			DepositTransactionID: v.DepositTransactionID,
+			TransactionID:        v.TransactionID,
+			TransactionURL:       paymentProofWithTxID(blockchainTxURL, v.TransactionID),
+		})
+	}
+	return refundMap
+}
+
+func calculateWinProbability(recordMap map[string]*record, totalAmount models.Amount) map[string]*record {
 	for _, r := range recordMap {
-		r.WinProbability = r.Amount / totalAmount * 100
+		r.WinProbability = float64(r.Amount) / float64(totalAmount) * 100
 	}
 	return recordMap
 }
 
+// addPendingRecords adds unconfirmed deposits to records of current game,
+// they do not count in win probability until they are mined,
+// current game is added in front if no deposit of it has been mined yet
+func addPendingRecords(games []gameResponse, currentGameOf time.Time, transactions []models.MempoolTransaction) []gameResponse {
+	i := indexOfGame(games, currentGameOf)
+	if i < 0 {
+		if len(transactions) == 0 || currentGameOf.IsZero() || (len(games) > 0 && !currentGameOf.After(games[0].GameOf)) {
+			return games
+		}
+
+		games = append([]gameResponse{{GameOf: currentGameOf, Refunds: []refundResponse{}}}, games...)
+		i = 0
+	}
+
+	if games[i].Records == nil {
+		games[i].Records = make(map[string]*record)
+	}
+
+	for _, v := range transactions {
+		if _, ok := games[i].Records[v.Address]; !ok {
+			games[i].Records[v.Address] = &record{ReceivedAt: v.ReceivedAt}
+		}
+		games[i].Records[v.Address].Pending = true
+		games[i].Records[v.Address].PendingAmount += v.Amount
+	}
+	return games
+}
+
+func indexOfGame(games []gameResponse, gameOf time.Time) int {
+	for i := range games {
+		if games[i].GameOf.Equal(gameOf) {
+			return i
+		}
+	}
+	return -1
+}
+
 func paymentProofWithTxID(url, txid string) string {
 	paymentProofURL := ""
 	if txid != "" {
@@ -146,16 +272,27 @@ func paymentProofWithTxID(url, txid string) string {
 	return paymentProofURL
 }
 
-func constructGamesResponse(games []models.Game, transactionMap map[time.Time]map[string]*record, fee float64, blockchainTxURL string) []gameResponse {
+func constructGamesResponse(games []models.Game, transactionMap map[time.Time]map[string]*record, refundMap map[time.Time][]refundResponse, fee float64, blockchainTxURL string) []gameResponse {
 	response := make([]gameResponse, len(games))
 	for i, v := range games {
+		refunds := refundMap[v.GameOf]
+		if refunds == nil {
+			refunds = []refundResponse{}
+		}
+
 		response[i] = gameResponse{
 			GameOf:          v.GameOf,
 			PaymentProofURL: paymentProofWithTxID(blockchainTxURL, v.TransactionID),
+			PaymentVout:     v.Vout,
 			WinnerAddress:   v.Address,
 			Hash:            v.Hash,
-			JackpotAmount:   v.TotalAmount * (1 - fee),
+			StartHeight:     v.StartHeight,
+			EndHeight:       v.EndHeight,
+			JackpotAmount:   jackpotAmountAfterFee(v.TotalAmount, fee),
+			NetAmount:       v.NetAmount,
+			NetworkFee:      v.NetworkFee,
 			Records:         calculateWinProbability(transactionMap[v.GameOf], v.TotalAmount),
+			Refunds:         refunds,
 		}
 	}
 	return response
@@ -9,12 +9,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/jerrors"
 	"github.com/solefaucet/jackpot-server/models"
 )
 
 func TestGames(t *testing.T) {
 	Convey("Given games handler", t, func() {
-		handler := Games(nil, nil, "", time.Minute, 0, "", "", "", "")
+		handler := Games(nil, nil, nil, nil, nil, "", time.Minute, 0, 0, "", "", "", "")
 
 		Convey("When request games handler with incorrect parameter", func() {
 			route := "/games"
@@ -31,7 +32,7 @@ func TestGames(t *testing.T) {
 
 	Convey("Given games handler with errored get games within", t, func() {
 		getGames := mockDependencyGetGames(nil, fmt.Errorf(""))
-		handler := Games(getGames, nil, "", time.Minute, 0, "", "", "", "")
+		handler := Games(getGames, nil, nil, nil, nil, "", time.Minute, 0, 0, "", "", "", "")
 
 		Convey("When request games handler", func() {
 			route := "/games"
@@ -49,7 +50,28 @@ func TestGames(t *testing.T) {
 	Convey("Given games handler with errored get transactions within", t, func() {
 		getGames := mockDependencyGetGames(nil, nil)
 		getTransactionsWithin := mockDependencyGetTransactionsByGameOfs(nil, fmt.Errorf(""))
-		handler := Games(getGames, getTransactionsWithin, "", time.Minute, 0, "", "", "", "")
+		getMempoolTransactions := mockDependencyGetMempoolTransactions(nil, nil)
+		getRefunds := mockDependencyGetRefundsByGameOfs(nil, nil)
+		handler := Games(getGames, getTransactionsWithin, getMempoolTransactions, getRefunds, nil, "", time.Minute, 0, 0, "", "", "", "")
+
+		Convey("When request games handler", func() {
+			route := "/games"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/games?offset=1&limit=1", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 500", func() {
+				So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+
+	Convey("Given games handler with errored get refunds within", t, func() {
+		getGames := mockDependencyGetGames(nil, nil)
+		getTransactionsWithin := mockDependencyGetTransactionsByGameOfs(nil, nil)
+		getRefunds := mockDependencyGetRefundsByGameOfs(nil, fmt.Errorf(""))
+		handler := Games(getGames, getTransactionsWithin, nil, getRefunds, nil, "", time.Minute, 0, 0, "", "", "", "")
 
 		Convey("When request games handler", func() {
 			route := "/games"
@@ -69,7 +91,10 @@ func TestGames(t *testing.T) {
 			{},
 		}, nil)
 		getTransactionsWithin := mockDependencyGetTransactionsByGameOfs(nil, nil)
-		handler := Games(getGames, getTransactionsWithin, "", time.Minute, 0, "", "", "", "")
+		getMempoolTransactions := mockDependencyGetMempoolTransactions(nil, fmt.Errorf(""))
+		getRefunds := mockDependencyGetRefundsByGameOfs(nil, nil)
+		getCurrentGameOf := mockDependencyGetCurrentGameOf(time.Time{}, jerrors.ErrNotFound)
+		handler := Games(getGames, getTransactionsWithin, getMempoolTransactions, getRefunds, getCurrentGameOf, "", time.Minute, 0, 0, "", "", "", "")
 
 		Convey("When request games handler", func() {
 			route := "/games"
@@ -83,6 +108,78 @@ func TestGames(t *testing.T) {
 			})
 		})
 	})
+
+	Convey("Given games handler with errored get current game of", t, func() {
+		getGames := mockDependencyGetGames(nil, nil)
+		getTransactionsWithin := mockDependencyGetTransactionsByGameOfs(nil, nil)
+		getRefunds := mockDependencyGetRefundsByGameOfs(nil, nil)
+		getCurrentGameOf := mockDependencyGetCurrentGameOf(time.Time{}, fmt.Errorf(""))
+		handler := Games(getGames, getTransactionsWithin, nil, getRefunds, getCurrentGameOf, "", time.Minute, 0, 0, "", "", "", "")
+
+		Convey("When request games handler", func() {
+			route := "/games"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/games?limit=1", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 500", func() {
+				So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+
+	Convey("Given games handler with current game of latest block", t, func() {
+		gameOf := time.Date(2016, 7, 28, 10, 0, 0, 0, time.UTC)
+		getGames := mockDependencyGetGames([]models.Game{
+			{GameOf: gameOf.Add(-time.Hour)},
+		}, nil)
+		getTransactionsWithin := mockDependencyGetTransactionsByGameOfs(nil, nil)
+		getMempoolTransactions := mockDependencyGetMempoolTransactions([]models.MempoolTransaction{
+			{Address: "a1", Amount: 1, ReceivedAt: gameOf.Add(time.Minute)},
+		}, nil)
+		getRefunds := mockDependencyGetRefundsByGameOfs(nil, nil)
+		getCurrentGameOf := mockDependencyGetCurrentGameOf(gameOf, nil)
+		handler := Games(getGames, getTransactionsWithin, getMempoolTransactions, getRefunds, getCurrentGameOf, "", time.Hour, 0, 0, "", "", "", "")
+
+		Convey("When request games handler", func() {
+			route := "/games"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/games?limit=1", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Pending deposits should be added to current game and next game time should follow it", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldContainSubstring, `"next_game_time":"2016-07-28T11:00:00Z"`)
+				So(resp.Body.String(), ShouldContainSubstring, `"game_of":"2016-07-28T10:00:00Z"`)
+			})
+		})
+	})
+
+	Convey("Given games handler with games spanning fixed number of blocks", t, func() {
+		getGames := mockDependencyGetGames([]models.Game{
+			{StartHeight: 144, EndHeight: 287},
+		}, nil)
+		getTransactionsWithin := mockDependencyGetTransactionsByGameOfs(nil, nil)
+		getMempoolTransactions := mockDependencyGetMempoolTransactions(nil, nil)
+		getRefunds := mockDependencyGetRefundsByGameOfs(nil, nil)
+		handler := Games(getGames, getTransactionsWithin, getMempoolTransactions, getRefunds, nil, "", time.Minute, 144, 0, "", "", "", "")
+
+		Convey("When request games handler", func() {
+			route := "/games"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/games?limit=1", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Next game height should be exposed instead of next game time", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldContainSubstring, `"next_game_height":288`)
+				So(resp.Body.String(), ShouldNotContainSubstring, "next_game_time")
+			})
+		})
+	})
 }
 
 func TestGetCurrentJackpotAmount(t *testing.T) {
@@ -94,7 +191,7 @@ func TestGetCurrentJackpotAmount(t *testing.T) {
 			amount := getCurrentJackpotAmount(getGames, 0)
 
 			Convey("Amount should equal 0", func() {
-				So(amount, ShouldEqual, 0)
+				So(amount, ShouldEqual, models.Amount(0))
 			})
 		})
 	})
@@ -108,7 +205,7 @@ func TestGetCurrentJackpotAmount(t *testing.T) {
 			amount := getCurrentJackpotAmount(getGames, 0.5)
 
 			Convey("Amount should be 50", func() {
-				So(amount, ShouldEqual, 50)
+				So(amount, ShouldEqual, models.Amount(50))
 			})
 		})
 	})
@@ -120,7 +217,7 @@ func TestGetCurrentJackpotAmount(t *testing.T) {
 			amount := getCurrentJackpotAmount(getGames, 0.5)
 
 			Convey("Amount should be 0", func() {
-				So(amount, ShouldEqual, 0)
+				So(amount, ShouldEqual, models.Amount(0))
 			})
 		})
 	})
@@ -155,11 +252,31 @@ func TestConstructTransactionMap(t *testing.T) {
 	}
 }
 
+func TestConstructRefundMap(t *testing.T) {
+	now := time.Now()
+	refunds := []models.Refund{
+		{GameOf: now, Address: "a1", Amount: 1, Reason: models.RefundReasonBelowMinBet, DepositTransactionID: "d1"},
+		{GameOf: now, Address: "a2", Amount: 2, Reason: models.RefundReasonAboveMaxBet, DepositTransactionID: "d2", TransactionID: "r2"},
+	}
+
+	actual := constructRefundMap(refunds, "url/")
+	expected := map[time.Time][]refundResponse{
+		now: []refundResponse{
+			{Address: "a1", Amount: 1, Reason: models.RefundReasonBelowMinBet, DepositTransactionID: "d1"},
+			{Address: "a2", Amount: 2, Reason: models.RefundReasonAboveMaxBet, DepositTransactionID: "d2", TransactionID: "r2", TransactionURL: "url/r2"},
+		},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("construct refund map expected \n%#v but get \n%#v", expected, actual)
+	}
+}
+
 func TestCalculateWinProbability(t *testing.T) {
 	recordMap := map[string]*record{
-		"key": &record{Amount: 9.99},
+		"key": &record{Amount: 999},
 	}
-	calculateWinProbability(recordMap, 100)
+	calculateWinProbability(recordMap, 10000)
 
 	expected := 9.99
 	if actual := recordMap["key"].WinProbability; actual != expected {
@@ -167,6 +284,68 @@ func TestCalculateWinProbability(t *testing.T) {
 	}
 }
 
+func TestAddPendingRecords(t *testing.T) {
+	duration := time.Hour
+	now := time.Now().Truncate(duration)
+	durationAgo := now.Add(-duration)
+	games := []gameResponse{
+		{GameOf: now, Records: map[string]*record{
+			"a1": &record{Amount: 2, ReceivedAt: now.Add(time.Minute)},
+		}},
+		{GameOf: durationAgo},
+	}
+	transactions := []models.MempoolTransaction{
+		{Address: "a1", Amount: 1, ReceivedAt: now.Add(2 * time.Minute)},
+		{Address: "a2", Amount: 1, ReceivedAt: now.Add(3 * time.Minute)},
+		{Address: "a2", Amount: 2, ReceivedAt: now.Add(4 * time.Minute)},
+	}
+
+	games = addPendingRecords(games, now, transactions)
+
+	expected := []gameResponse{
+		{GameOf: now, Records: map[string]*record{
+			"a1": &record{Amount: 2, PendingAmount: 1, Pending: true, ReceivedAt: now.Add(time.Minute)},
+			"a2": &record{PendingAmount: 3, Pending: true, ReceivedAt: now.Add(3 * time.Minute)},
+		}},
+		{GameOf: durationAgo},
+	}
+
+	if !reflect.DeepEqual(games, expected) {
+		t.Errorf("add pending records expected \n%#v but get \n%#v", expected, games)
+	}
+}
+
+func TestAddPendingRecordsToMissingCurrentGame(t *testing.T) {
+	duration := time.Hour
+	now := time.Now().Truncate(duration)
+	durationAgo := now.Add(-duration)
+	transactions := []models.MempoolTransaction{
+		{Address: "a1", Amount: 1, ReceivedAt: now.Add(time.Minute)},
+	}
+
+	games := addPendingRecords([]gameResponse{{GameOf: durationAgo}}, now, transactions)
+
+	expected := []gameResponse{
+		{GameOf: now, Refunds: []refundResponse{}, Records: map[string]*record{
+			"a1": &record{PendingAmount: 1, Pending: true, ReceivedAt: now.Add(time.Minute)},
+		}},
+		{GameOf: durationAgo},
+	}
+
+	if !reflect.DeepEqual(games, expected) {
+		t.Errorf("add pending records expected \n%#v but get \n%#v", expected, games)
+	}
+
+	// no game is made up without pending deposits, or for a page of older games
+	if games := addPendingRecords([]gameResponse{{GameOf: durationAgo}}, now, nil); len(games) != 1 {
+		t.Errorf("add pending records without transactions expected 1 game but get %v", len(games))
+	}
+
+	if games := addPendingRecords([]gameResponse{{GameOf: now}}, durationAgo, transactions); len(games) != 1 || games[0].Records != nil {
+		t.Errorf("add pending records to older current game expected games untouched but get %#v", games)
+	}
+}
+
 func TestPaymentProofWithTxID(t *testing.T) {
 	if actual, expected := paymentProofWithTxID("url", ""), ""; actual != expected {
 		t.Errorf("payment proof with txid expected %v but get %v", expected, actual)
@@ -182,7 +361,7 @@ func TestConstructGamesResponse(t *testing.T) {
 	now := time.Now().Truncate(duration)
 	durationAgo := now.Add(-duration)
 	games := []models.Game{
-		{TransactionID: "tx_id_1", TotalAmount: 100, GameOf: now},
+		{TransactionID: "tx_id_1", Vout: 2, TotalAmount: 100, NetAmount: 97, NetworkFee: 3, GameOf: now},
 		{TransactionID: "", TotalAmount: 100, GameOf: durationAgo},
 	}
 	transactionMap := map[time.Time]map[string]*record{
@@ -196,15 +375,19 @@ func TestConstructGamesResponse(t *testing.T) {
 		},
 	}
 
-	actual := constructGamesResponse(games, transactionMap, 0, "url/")
+	refundMap := map[time.Time][]refundResponse{
+		now: []refundResponse{{Address: "a3", Amount: 1, Reason: models.RefundReasonBelowMinBet}},
+	}
+
+	actual := constructGamesResponse(games, transactionMap, refundMap, 0, "url/")
 
 	transactionMap[now]["a1"].WinProbability = 0.02
 	transactionMap[now]["a2"].WinProbability = 0.01
 	transactionMap[durationAgo]["b1"].WinProbability = 0.01
 	transactionMap[durationAgo]["b2"].WinProbability = 0.01
 	expected := []gameResponse{
-		{GameOf: now, JackpotAmount: 100, PaymentProofURL: "url/tx_id_1", Records: transactionMap[now]},
-		{GameOf: durationAgo, JackpotAmount: 100, PaymentProofURL: "", Records: transactionMap[durationAgo]},
+		{GameOf: now, JackpotAmount: 100, NetAmount: 97, NetworkFee: 3, PaymentProofURL: "url/tx_id_1", PaymentVout: 2, Records: transactionMap[now], Refunds: refundMap[now]},
+		{GameOf: durationAgo, JackpotAmount: 100, PaymentProofURL: "", Records: transactionMap[durationAgo], Refunds: []refundResponse{}},
 	}
 
 	if !reflect.DeepEqual(actual, expected) {
@@ -0,0 +1,73 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+type payoutResponse struct {
+	ID            int64         `json:"id"`
+	GameOf        time.Time     `json:"game_of"`
+	Address       string        `json:"address"`
+	Amount        models.Amount `json:"amount"`
+	Status        string        `json:"status"`
+	Attempts      int64         `json:"attempts"`
+	LastError     string        `json:"last_error"`
+	NextAttemptAt time.Time     `json:"next_attempt_at"`
+}
+
+// Payouts handler lists the payout queue, i.e. payouts queued, being sent or failed,
+// operators requeue permanently failed ones once the cause is fixed
+func Payouts(getUnsentPayouts dependencyGetUnsentPayouts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payouts, err := getUnsentPayouts()
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, constructPayoutsResponse(payouts))
+	}
+}
+
+// RequeuePayout handler queues failed payout of path parameter id to be sent right away
+func RequeuePayout(requeuePayout dependencyRequeuePayout) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		switch err := requeuePayout(id); err {
+		case nil:
+			c.Status(http.StatusNoContent)
+		case jerrors.ErrNotFound:
+			c.AbortWithError(http.StatusNotFound, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+	}
+}
+
+func constructPayoutsResponse(payouts []models.Payout) []payoutResponse {
+	response := make([]payoutResponse, len(payouts))
+	for i, v := range payouts {
+		response[i] = payoutResponse{
+			ID:            v.ID,
+			GameOf:        v.GameOf,
+			Address:       v.Address,
+			Amount:        v.Amount,
+			Status:        v.Status,
+			Attempts:      v.Attempts,
+			LastError:     v.LastError,
+			NextAttemptAt: v.NextAttemptAt,
+		}
+	}
+	return response
+}
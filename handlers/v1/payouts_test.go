@@ -0,0 +1,96 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestPayouts(t *testing.T) {
+	Convey("Given payouts handler with errored get unsent payouts within", t, func() {
+		handler := Payouts(mockDependencyGetUnsentPayouts(nil, fmt.Errorf("")))
+
+		Convey("When request payouts handler", func() {
+			route := "/payouts"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/payouts", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 500", func() {
+				So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+
+	Convey("Given payouts handler with everything correct", t, func() {
+		handler := Payouts(mockDependencyGetUnsentPayouts([]models.Payout{{}}, nil))
+
+		Convey("When request payouts handler", func() {
+			route := "/payouts"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/payouts", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 200", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
+
+func TestRequeuePayout(t *testing.T) {
+	requests := []struct {
+		description string
+		err         error
+		path        string
+		code        int
+	}{
+		{"invalid id", nil, "/payouts/abc/requeue", http.StatusBadRequest},
+		{"payout not failed", jerrors.ErrNotFound, "/payouts/1/requeue", http.StatusNotFound},
+		{"errored requeue payout within", fmt.Errorf(""), "/payouts/1/requeue", http.StatusInternalServerError},
+		{"everything correct", nil, "/payouts/1/requeue", http.StatusNoContent},
+	}
+
+	for _, request := range requests {
+		Convey("Given requeue payout handler with "+request.description, t, func() {
+			handler := RequeuePayout(mockDependencyRequeuePayout(request.err))
+
+			Convey("When request requeue payout handler", func() {
+				route := "/payouts/:id/requeue"
+				_, resp, r := gin.CreateTestContext()
+				r.POST(route, handler)
+				req, _ := http.NewRequest("POST", request.path, nil)
+				r.ServeHTTP(resp, req)
+
+				Convey(fmt.Sprintf("Response code should be %v", request.code), func() {
+					So(resp.Code, ShouldEqual, request.code)
+				})
+			})
+		})
+	}
+}
+
+func TestConstructPayoutsResponse(t *testing.T) {
+	now := time.Now()
+	payouts := []models.Payout{
+		{ID: 1, GameOf: now, Address: "addr", Amount: 10, Status: models.PayoutStatusFailedPermanent, Attempts: 2, LastError: "invalid address", NextAttemptAt: now},
+	}
+
+	actual := constructPayoutsResponse(payouts)
+	expected := []payoutResponse{
+		{ID: 1, GameOf: now, Address: "addr", Amount: 10, Status: models.PayoutStatusFailedPermanent, Attempts: 2, LastError: "invalid address", NextAttemptAt: now},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("construct payouts response expected \n%#v but get \n%#v", expected, actual)
+	}
+}
@@ -0,0 +1,41 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+type reserveResponse struct {
+	Balance         models.Amount `json:"balance"`
+	OutstandingPots models.Amount `json:"outstanding_pots"`
+	UnsentPayouts   models.Amount `json:"unsent_payouts"`
+	PendingRefunds  models.Amount `json:"pending_refunds"`
+	Liabilities     models.Amount `json:"liabilities"`
+	ReserveRatio    *float64      `json:"reserve_ratio"` // null if nothing is owed
+}
+
+// Reserve handler shows hot wallet balance against what is owed to winners and refunded senders
+func Reserve(getReserve dependencyGetReserve) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reserve, err := getReserve()
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		response := reserveResponse{
+			Balance:         reserve.Balance,
+			OutstandingPots: reserve.OutstandingPots,
+			UnsentPayouts:   reserve.UnsentPayouts,
+			PendingRefunds:  reserve.PendingRefunds,
+			Liabilities:     reserve.Total(),
+		}
+		if ratio, ok := reserve.Ratio(); ok {
+			response.ReserveRatio = &ratio
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
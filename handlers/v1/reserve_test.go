@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestReserve(t *testing.T) {
+	Convey("Given reserve handler with errored get reserve within", t, func() {
+		handler := Reserve(mockDependencyGetReserve(models.Reserve{}, fmt.Errorf("")))
+
+		Convey("When request reserve handler", func() {
+			_, resp, r := gin.CreateTestContext()
+			r.GET("/reserve", handler)
+			req, _ := http.NewRequest("GET", "/reserve", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 500", func() {
+				So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+
+	Convey("Given reserve handler with everything correct", t, func() {
+		reserve := models.Reserve{Balance: 300, Liabilities: models.Liabilities{OutstandingPots: 100, UnsentPayouts: 50, PendingRefunds: 50}}
+		handler := Reserve(mockDependencyGetReserve(reserve, nil))
+
+		Convey("When request reserve handler", func() {
+			_, resp, r := gin.CreateTestContext()
+			r.GET("/reserve", handler)
+			req, _ := http.NewRequest("GET", "/reserve", nil)
+			r.ServeHTTP(resp, req)
+			response := reserveResponse{}
+			json.Unmarshal(resp.Body.Bytes(), &response)
+
+			Convey("Response should have reserve ratio", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(response.Liabilities, ShouldEqual, 200)
+				So(*response.ReserveRatio, ShouldEqual, 1.5)
+			})
+		})
+	})
+
+	Convey("Given reserve handler with nothing owed", t, func() {
+		handler := Reserve(mockDependencyGetReserve(models.Reserve{Balance: 300}, nil))
+
+		Convey("When request reserve handler", func() {
+			_, resp, r := gin.CreateTestContext()
+			r.GET("/reserve", handler)
+			req, _ := http.NewRequest("GET", "/reserve", nil)
+			r.ServeHTTP(resp, req)
+			response := reserveResponse{}
+			json.Unmarshal(resp.Body.Bytes(), &response)
+
+			Convey("Reserve ratio should be null", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(response.ReserveRatio, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+type sweepResponse struct {
+	ID             int64         `json:"id"`
+	Address        string        `json:"address"`
+	Amount         models.Amount `json:"amount"`
+	NetworkFee     models.Amount `json:"network_fee"`
+	TransactionID  string        `json:"tx_id"`
+	TransactionURL string        `json:"tx_url"`
+	Status         string        `json:"status"`
+	LastError      string        `json:"last_error"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// Sweeps handler lists house fees swept to cold address, operators audit them against fees of ended games
+func Sweeps(getSweeps dependencyGetSweeps, blockchainTxURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := adminListPayload{}
+		if err := c.BindWith(&p, binding.Form); err != nil {
+			return
+		}
+
+		sweeps, err := getSweeps(p.Limit, p.Offset)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, constructSweepsResponse(sweeps, blockchainTxURL))
+	}
+}
+
+func constructSweepsResponse(sweeps []models.Sweep, blockchainTxURL string) []sweepResponse {
+	response := make([]sweepResponse, len(sweeps))
+	for i, v := range sweeps {
+		response[i] = sweepResponse{
+			ID:             v.ID,
+			Address:        v.Address,
+			Amount:         v.Amount,
+			NetworkFee:     v.NetworkFee,
+			TransactionID:  v.TransactionID,
+			TransactionURL: paymentProofWithTxID(blockchainTxURL, v.TransactionID),
+			Status:         v.Status,
+			LastError:      v.LastError,
+			CreatedAt:      v.CreatedAt,
+		}
+	}
+	return response
+}
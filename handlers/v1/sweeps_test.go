@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestSweeps(t *testing.T) {
+	Convey("Given sweeps handler with errored get sweeps within", t, func() {
+		handler := Sweeps(mockDependencyGetSweeps(nil, fmt.Errorf("")), "")
+
+		Convey("When request sweeps handler", func() {
+			route := "/sweeps"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/sweeps?limit=10", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 500", func() {
+				So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+
+	Convey("Given sweeps handler with everything correct", t, func() {
+		handler := Sweeps(mockDependencyGetSweeps([]models.Sweep{{}}, nil), "")
+
+		Convey("When request sweeps handler without limit", func() {
+			route := "/sweeps"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/sweeps", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 400", func() {
+				So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When request sweeps handler", func() {
+			route := "/sweeps"
+			_, resp, r := gin.CreateTestContext()
+			r.GET(route, handler)
+			req, _ := http.NewRequest("GET", "/sweeps?limit=10", nil)
+			r.ServeHTTP(resp, req)
+
+			Convey("Response code should be 200", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
+
+func TestConstructSweepsResponse(t *testing.T) {
+	sweeps := []models.Sweep{
+		{ID: 1, Address: "cold", Amount: 10, NetworkFee: 1, TransactionID: "tx_id_1", Status: models.SweepStatusSent},
+		{ID: 2, Address: "cold", Amount: 10, Status: models.SweepStatusPending},
+	}
+
+	actual := constructSweepsResponse(sweeps, "url/")
+	expected := []sweepResponse{
+		{ID: 1, Address: "cold", Amount: 10, NetworkFee: 1, TransactionID: "tx_id_1", TransactionURL: "url/tx_id_1", Status: models.SweepStatusSent},
+		{ID: 2, Address: "cold", Amount: 10, Status: models.SweepStatusPending},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("construct sweeps response expected \n%#v but get \n%#v", expected, actual)
+	}
+}
@@ -4,6 +4,7 @@ package jerrors
 var (
 	ErrNotFound   = JError("not found")
 	ErrNoNewBlock = JError("there is no new block ahead")
+	ErrGameClosed = JError("game is closed")
 )
 
 // JError is jackpot custom error
@@ -2,29 +2,28 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
 	"runtime"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gin-gonic/gin"
 	"github.com/solefaucet/jackpot-server/handlers/v1"
 	"github.com/solefaucet/jackpot-server/middlewares"
 	"github.com/solefaucet/jackpot-server/models"
-	s "github.com/solefaucet/jackpot-server/services/storage"
 	"github.com/solefaucet/jackpot-server/services/storage/mysql"
 	w "github.com/solefaucet/jackpot-server/services/wallet"
 	"github.com/solefaucet/jackpot-server/services/wallet/core"
+	"github.com/solefaucet/jackpot-server/services/wallet/esplora"
+	"github.com/solefaucet/jackpot-server/services/wallet/simulated"
 	"github.com/solefaucet/jackpot-server/utils"
 	grayloghook "github.com/yumimobi/logrus-graylog2-hook"
 )
 
-var (
-	logger  = log.New(os.Stderr, "", log.Ldate|log.Ltime|log.Llongfile)
-	wallet  w.Wallet
-	storage s.Storage
-)
+var logger = log.New(os.Stderr, "", log.Ldate|log.Ltime|log.Llongfile)
 
 func initService() {
 	// configuration
@@ -55,19 +54,83 @@ func initService() {
 	store := mysql.New(config.DB.DataSourceName)
 	store.SetMaxOpenConns(config.DB.MaxOpenConns)
 	store.SetMaxIdleConns(config.DB.MaxIdleConns)
-	storage = store
 
-	// wallet
-	wallet = utils.Must(
+	// every coin has its own wallet and rows in storage
+	for _, coinConfig := range config.Coins {
+		coins = append(coins, newCoin(coinConfig, store.WithCoin(coinConfig.Name)))
+	}
+
+	// MOST IMPORTANT FUNCTION HERE!!!
+	initWork()
+}
+
+func newWallet(config coinConfiguration) w.Wallet {
+	if config.Wallet.Driver == walletDriverSimulated {
+		sim := simulated.New(config.Jackpot.DestAddress, time.Now())
+		go sim.Mine(config.Wallet.SimulatedBlockInterval, nil)
+		return sim
+	}
+
+	if config.Wallet.Driver == walletDriverEsplora {
+		return esplora.New(
+			config.Wallet.EsploraURL,
+			config.Jackpot.DestAddress,
+			config.Jackpot.ExtraAddresses,
+			config.Wallet.SenderPolicy,
+			newSigner(config),
+		)
+	}
+
+	coreWallet := newCoreWallet(config)
+	// senders of deposits are resolved from previous outputs, which only node with -txindex finds
+	utils.Must(nil, coreWallet.CheckTxIndex())
+	return coreWallet
+}
+
+func newCoreWallet(config coinConfiguration) core.Wallet {
+	return utils.Must(
 		core.New(
 			config.Wallet.Host,
 			config.Wallet.Username,
 			config.Wallet.Password,
+			config.Wallet.SenderPolicy,
 		),
-	).(w.Wallet)
+	).(core.Wallet)
+}
 
-	// MOST IMPORTANT FUNCTION HERE!!!
-	initWork()
+// newSigner returns core wallet to send payouts for esplora wallet, nil if it is not configured
+func newSigner(config coinConfiguration) esplora.Signer {
+	if config.Wallet.Host == "" {
+		return nil
+	}
+	return newCoreWallet(config)
+}
+
+// newBlockNotifications returns channel of block notifications from wallet or node websocket,
+// nil if neither is available
+func newBlockNotifications(config coinConfiguration, wallet w.Wallet) <-chan w.BlockNotification {
+	if notifier, ok := wallet.(w.Notifier); ok {
+		return notifier.BlockNotifications()
+	}
+
+	if config.Wallet.Driver != walletDriverCore || config.Wallet.NotifyHost == "" {
+		return nil
+	}
+
+	var certificates []byte
+	if config.Wallet.NotifyCertificate != "" {
+		certificates = utils.Must(ioutil.ReadFile(config.Wallet.NotifyCertificate)).([]byte)
+	}
+
+	notifier := utils.Must(
+		core.NewNotifier(
+			config.Wallet.NotifyHost,
+			config.Wallet.Username,
+			config.Wallet.Password,
+			certificates,
+		),
+	).(*core.Notifier)
+	return notifier.BlockNotifications()
 }
 
 func main() {
@@ -84,22 +147,57 @@ func main() {
 		gin.ErrorLogger(),
 	)
 
-	// version 1 api endpoints
+	// handlers of every coin
+	games := make(map[string]gin.HandlerFunc)
+	excludedTransactions := make(map[string]gin.HandlerFunc)
+	payouts := make(map[string]gin.HandlerFunc)
+	requeuePayout := make(map[string]gin.HandlerFunc)
+	reserve := make(map[string]gin.HandlerFunc)
+	sweeps := make(map[string]gin.HandlerFunc)
+	for _, c := range coins {
+		games[c.config.Name] = v1.Games(
+			c.storage.GetGames,
+			c.storage.GetTransactionsByGameOfs,
+			c.storage.GetMempoolTransactions,
+			c.storage.GetRefundsByGameOfs,
+			c.currentGameOf,
+			c.config.Jackpot.DestAddress,
+			c.config.Jackpot.Duration,
+			c.config.Jackpot.RoundBlocks,
+			c.config.Jackpot.TransactionFee,
+			c.config.Coin.TxURL,
+			c.config.Coin.AddressURL,
+			c.config.Coin.Type,
+			c.config.Coin.Label,
+		)
+		excludedTransactions[c.config.Name] = v1.ExcludedTransactions(
+			c.storage.GetExcludedTransactions,
+			c.config.Coin.TxURL,
+		)
+		payouts[c.config.Name] = v1.Payouts(c.storage.GetUnsentPayouts)
+		requeuePayout[c.config.Name] = v1.RequeuePayout(c.requeuePayout)
+		reserve[c.config.Name] = v1.Reserve(c.reserve)
+		sweeps[c.config.Name] = v1.Sweeps(c.storage.GetSweeps, c.config.Coin.TxURL)
+	}
+
+	// version 1 api endpoints, coin goes first in path, e.g. /v1/doge/games,
+	// unless single coin deployment keeps /v1/games
 	v1Endpoints := router.Group("/v1")
-	v1Endpoints.GET(
-		"/games",
-		v1.Games(
-			storage.GetGames,
-			storage.GetTransactionsByGameOfs,
-			config.Jackpot.DestAddress,
-			config.Jackpot.Duration,
-			config.Jackpot.TransactionFee,
-			config.Coin.TxURL,
-			config.Coin.AddressURL,
-			config.Coin.Type,
-			config.Coin.Label,
-		),
-	)
+	coinEndpoints := v1Endpoints
+	if len(coins) > 1 || coins[0].config.Name != "" {
+		coinEndpoints = v1Endpoints.Group("/:coin")
+	}
+	coinEndpoints.GET("/games", v1.ByCoin(games))
+
+	// operator endpoints are enabled only with credentials configured
+	if config.Admin.Username != "" && config.Admin.Password != "" {
+		adminEndpoints := coinEndpoints.Group("/admin", gin.BasicAuth(gin.Accounts{config.Admin.Username: config.Admin.Password}))
+		adminEndpoints.GET("/excluded_transactions", v1.ByCoin(excludedTransactions))
+		adminEndpoints.GET("/payouts", v1.ByCoin(payouts))
+		adminEndpoints.POST("/payouts/:id/requeue", v1.ByCoin(requeuePayout))
+		adminEndpoints.GET("/reserve", v1.ByCoin(reserve))
+		adminEndpoints.GET("/sweeps", v1.ByCoin(sweeps))
+	}
 
 	// on service stop, log and maybe do some cleanup jobs
 	onServiceStop := func() {
@@ -120,7 +218,7 @@ func main() {
 }
 
 func catch(then func()) {
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, signals...)
 	<-c
 	if then != nil {
@@ -147,6 +245,6 @@ func safeFuncWrapper(f func()) func() {
 	}
 }
 
-func getDestAddress() string {
-	return utils.Must(wallet.GetDestAddress()).(string)
+func (c *coin) getDestAddress() string {
+	return utils.Must(c.wallet.GetDestAddress()).(string)
 }
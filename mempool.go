@@ -0,0 +1,64 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/solefaucet/jackpot-server/models"
+	w "github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+func (c *coin) watchMempoolJob() {
+	for {
+		c.watchMempool()
+		time.Sleep(c.config.Wallet.MempoolPollInterval)
+	}
+}
+
+// watchMempool records unconfirmed deposits as provisional entries of current game,
+// deposits mined or replaced since last run are dropped
+func (c *coin) watchMempool() {
+	entry := c.withFields(logrus.Fields{
+		"event": models.LogEventWatchMempool,
+	})
+
+	receivedTransactions, err := c.wallet.GetMempoolReceived()
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to get mempool transactions from blockchain")
+		return
+	}
+
+	transactions := c.walletTxsToMempoolTxs(receivedTransactions)
+	if err := c.storage.ReplaceMempoolTransactions(transactions); err != nil {
+		entry.WithField("error", err.Error()).Error("fail to replace mempool transactions")
+		return
+	}
+
+	entry.WithField("mempool_transactions", len(transactions)).Debug("replace mempool transactions successfully")
+}
+
+// walletTxsToMempoolTxs merges outputs of the same transaction,
+// off-address and unattributed deposits are left out as they never take part in game
+func (c *coin) walletTxsToMempoolTxs(txs []w.Transaction) []models.MempoolTransaction {
+	transactions := []models.MempoolTransaction{}
+	indexes := make(map[string]int)
+	for _, v := range txs {
+		if !c.config.isJackpotAddress(v.OutputAddress) || v.UnattributedReason != "" {
+			continue
+		}
+
+		if i, ok := indexes[v.TransactionID]; ok {
+			transactions[i].Amount += v.Amount
+			continue
+		}
+
+		indexes[v.TransactionID] = len(transactions)
+		transactions = append(transactions, models.MempoolTransaction{
+			Address:       v.Address,
+			Amount:        v.Amount,
+			TransactionID: v.TransactionID,
+			ReceivedAt:    v.BlockCreatedAt,
+		})
+	}
+	return transactions
+}
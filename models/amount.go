@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SatoshiPerCoin is the number of the smallest units in one coin
+const SatoshiPerCoin = 1e8
+
+// Amount is a fixed-point coin amount in satoshi,
+// it is stored as DECIMAL(19, 8) and encoded to JSON as a decimal number of coins
+type Amount int64
+
+// AmountFromFloat64 converts coins in float64, e.g. from wallet RPC, to Amount,
+// rounding to the nearest satoshi instead of truncating
+func AmountFromFloat64(f float64) Amount {
+	return round(f * SatoshiPerCoin)
+}
+
+func round(satoshi float64) Amount {
+	if satoshi < 0 {
+		return -round(-satoshi)
+	}
+
+	return Amount(math.Floor(satoshi + 0.5))
+}
+
+// ParseAmount parses decimal coins string, e.g. 0.29000000, to Amount without precision loss
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	parts := strings.SplitN(s, ".", 2)
+	fraction := ""
+	if len(parts) == 2 {
+		fraction = parts[1]
+	}
+	if len(fraction) > 8 {
+		return 0, fmt.Errorf("amount %v has more than 8 decimal places", s)
+	}
+
+	coins, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse amount %v error: %v", s, err)
+	}
+
+	satoshi := int64(0)
+	if fraction != "" {
+		satoshi, err = strconv.ParseInt(fraction+strings.Repeat("0", 8-len(fraction)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse amount %v error: %v", s, err)
+		}
+	}
+
+	amount := Amount(coins*SatoshiPerCoin + satoshi)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// MulRate returns amount multiplied by rate, rounded to the nearest satoshi
+func (a Amount) MulRate(rate float64) Amount {
+	return round(float64(a) * rate)
+}
+
+// Float64 returns amount in coins, it is only meant for display and RPC that requires float
+func (a Amount) Float64() float64 {
+	return float64(a) / SatoshiPerCoin
+}
+
+// String formats amount as decimal coins with 8 decimal places
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign = "-"
+		a = -a
+	}
+
+	return fmt.Sprintf("%s%d.%08d", sign, int64(a)/SatoshiPerCoin, int64(a)%SatoshiPerCoin)
+}
+
+// MarshalJSON encodes amount as decimal coins
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes decimal coins to amount
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	amount, err := ParseAmount(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// Scan implements sql.Scanner for DECIMAL columns
+func (a *Amount) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case []byte:
+		*a, err = ParseAmount(string(v))
+	case string:
+		*a, err = ParseAmount(v)
+	case int64:
+		*a = Amount(v * SatoshiPerCoin)
+	case float64:
+		*a = AmountFromFloat64(v)
+	case nil:
+		*a = 0
+	default:
+		err = fmt.Errorf("cannot scan %T into amount", src)
+	}
+	return err
+}
+
+// Value implements driver.Valuer for DECIMAL columns
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAmountFromFloat64(t *testing.T) {
+	for f, expected := range map[float64]Amount{
+		0.29:       29000000,
+		0.1 + 0.2:  30000000,
+		1.00000001: 100000001,
+		-0.29:      -29000000,
+	} {
+		if actual := AmountFromFloat64(f); actual != expected {
+			t.Errorf("amount from float64 %v expected %v but get %v", f, expected, actual)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	for s, expected := range map[string]Amount{
+		"0.29000000": 29000000,
+		"0.29":       29000000,
+		"12":         1200000000,
+		"-1.5":       -150000000,
+		"0.00000001": 1,
+	} {
+		actual, err := ParseAmount(s)
+		if err != nil || actual != expected {
+			t.Errorf("parse amount %v expected %v but get %v, error: %v", s, expected, actual, err)
+		}
+	}
+
+	for _, s := range []string{"", "abc", "0.000000001", "1.2.3"} {
+		if _, err := ParseAmount(s); err == nil {
+			t.Errorf("parse amount %v expected error but get nil", s)
+		}
+	}
+}
+
+func TestAmountMulRate(t *testing.T) {
+	total := Amount(29000000)
+	fee := total.MulRate(0.01)
+	if expected := Amount(290000); fee != expected {
+		t.Errorf("fee expected %v but get %v", expected, fee)
+	}
+
+	if win := total - fee; win+fee != total {
+		t.Errorf("win amount %v and fee %v do not reconcile to total %v", win, fee, total)
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	for amount, expected := range map[Amount]string{
+		29000000:   "0.29000000",
+		1200000001: "12.00000001",
+		-150000000: "-1.50000000",
+		0:          "0.00000000",
+	} {
+		if actual := amount.String(); actual != expected {
+			t.Errorf("amount string expected %v but get %v", expected, actual)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	data, _ := json.Marshal(struct {
+		Amount Amount `json:"amount"`
+	}{29000000})
+	if expected := `{"amount":0.29000000}`; string(data) != expected {
+		t.Errorf("marshal amount expected %v but get %v", expected, string(data))
+	}
+
+	var amount Amount
+	if err := json.Unmarshal([]byte("0.29"), &amount); err != nil || amount != 29000000 {
+		t.Errorf("unmarshal amount expected %v but get %v, error: %v", 29000000, amount, err)
+	}
+}
+
+func TestAmountScan(t *testing.T) {
+	var amount Amount
+	if err := amount.Scan([]byte("0.29000000")); err != nil || amount != 29000000 {
+		t.Errorf("scan amount expected %v but get %v, error: %v", 29000000, amount, err)
+	}
+
+	if err := amount.Scan(true); err == nil {
+		t.Errorf("scan bool into amount expected error but get nil")
+	}
+}
@@ -5,8 +5,11 @@ import "time"
 // Block model
 type Block struct {
 	ID             int64     `db:"id"`
+	Coin           string    `db:"coin"`
 	Hash           string    `db:"hash"`
 	Height         int64     `db:"height"`
 	BlockCreatedAt time.Time `db:"block_created_at"`
+	MedianTime     time.Time `db:"median_time"`
+	Orphaned       bool      `db:"orphaned"`
 	CreatedAt      time.Time `db:"created_at"`
 }
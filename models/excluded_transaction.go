@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// reasons why transaction is excluded from games
+const (
+	ExcludedReasonUnattributed = "unattributed"
+	ExcludedReasonOffAddress   = "off_address"
+)
+
+// ExcludedTransaction model, received transaction that does not take part in any game
+// and is left for operators to review and refund
+type ExcludedTransaction struct {
+	ID             int64     `db:"id"`
+	Coin           string    `db:"coin"`
+	Address        string    `db:"address"`
+	Amount         Amount    `db:"amount"`
+	TransactionID  string    `db:"tx_id"`
+	Vout           uint32    `db:"vout"`
+	OutputAddress  string    `db:"output_address"`
+	Hash           string    `db:"hash"`
+	Reason         string    `db:"reason"`
+	Detail         string    `db:"detail"`
+	BlockCreatedAt time.Time `db:"block_created_at"`
+	Orphaned       bool      `db:"orphaned"`
+	CreatedAt      time.Time `db:"created_at"`
+}
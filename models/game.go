@@ -6,19 +6,26 @@ import "time"
 const (
 	GameStatusPending       = "pending"
 	GameStatusDrawingNeeded = "drawing needed"
+	GameStatusPaying        = "paying"
 	GameStatusEnded         = "ended"
 )
 
 // Game model
 type Game struct {
 	ID            int64     `db:"id"`
+	Coin          string    `db:"coin"`
 	Hash          string    `db:"hash"`
 	Height        int64     `db:"height"`
+	StartHeight   int64     `db:"start_height"`
+	EndHeight     int64     `db:"end_height"`
 	Address       string    `db:"address"`
-	WinAmount     float64   `db:"win_amount"`
-	TotalAmount   float64   `db:"total_amount"`
-	Fee           float64   `db:"fee"`
+	WinAmount     Amount    `db:"win_amount"`
+	TotalAmount   Amount    `db:"total_amount"`
+	Fee           Amount    `db:"fee"`
+	NetAmount     Amount    `db:"net_amount"`  // win amount less network fee paid by winner
+	NetworkFee    Amount    `db:"network_fee"` // network fee of payout transaction, an even share of it if batched
 	TransactionID string    `db:"tx_id"`
+	Vout          uint32    `db:"vout"` // output paying winner in payout transaction, which batched payouts share
 	GameOf        time.Time `db:"game_of"`
 	Status        string    `db:"status"`
 	CreatedAt     time.Time `db:"created_at"`
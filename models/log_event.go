@@ -9,4 +9,13 @@ const (
 	LogEventUpdateConfirmations      = "update confirmations"
 	LogEventGetSenderAddress         = "get sender address"
 	LogEventGetRawTransaction        = "get raw transaction"
+	LogEventChainReorganization      = "chain reorganization"
+	LogEventSendPayouts              = "send payouts"
+	LogEventConfirmPayouts           = "confirm payouts"
+	LogEventRequeuePayout            = "requeue payout"
+	LogEventCatchUp                  = "catch up"
+	LogEventWatchMempool             = "watch mempool"
+	LogEventSendRefunds              = "send refunds"
+	LogEventMonitorBalance           = "monitor balance"
+	LogEventSweepFees                = "sweep fees"
 )
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MempoolTransaction model, unconfirmed deposit shown provisionally until it is mined or replaced
+type MempoolTransaction struct {
+	ID            int64     `db:"id"`
+	Coin          string    `db:"coin"`
+	Address       string    `db:"address"`
+	Amount        Amount    `db:"amount"`
+	TransactionID string    `db:"tx_id"`
+	ReceivedAt    time.Time `db:"received_at"`
+	CreatedAt     time.Time `db:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// payout status, a payout is queued when game is drawn, broadcasting while it is being sent,
+// broadcast once wallet has its transaction and confirmed with enough confirmations,
+// failed payout is retried with backoff unless retrying can never fix it
+const (
+	PayoutStatusQueued          = "queued"
+	PayoutStatusBroadcasting    = "broadcasting"
+	PayoutStatusBroadcast       = "broadcast"
+	PayoutStatusConfirmed       = "confirmed"
+	PayoutStatusFailedRetryable = "failed_retryable"
+	PayoutStatusFailedPermanent = "failed_permanent"
+)
+
+// Payout model
+type Payout struct {
+	ID             int64     `db:"id"`
+	Coin           string    `db:"coin"`
+	GameOf         time.Time `db:"game_of"`
+	IdempotencyKey string    `db:"idempotency_key"`
+	BatchKey       string    `db:"batch_key"` // idempotency key of batch the payout was last sent in, empty if never batched
+	Address        string    `db:"address"`
+	Amount         Amount    `db:"amount"`
+	NetworkFee     Amount    `db:"network_fee"`
+	TransactionID  string    `db:"tx_id"`
+	Vout           uint32    `db:"vout"`
+	RawTransaction string    `db:"raw_tx"` // signed transaction funded for payout, empty until it is funded
+	Status         string    `db:"status"`
+	Attempts       int64     `db:"attempts"`
+	LastError      string    `db:"last_error"`
+	NextAttemptAt  time.Time `db:"next_attempt_at"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
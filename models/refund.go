@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// refund status
+const (
+	RefundStatusPending = "pending"
+	RefundStatusSent    = "sent"
+)
+
+// reasons why deposit is refunded
+const (
+	RefundReasonBelowMinBet           = "below_min_bet"
+	RefundReasonAboveMaxBet           = "above_max_bet"
+	RefundReasonAboveMaxBetPerAddress = "above_max_bet_per_address"
+)
+
+// Refund model, deposit or the excess portion of it sent back to sender for breaking bet limits
+type Refund struct {
+	ID                   int64     `db:"id"`
+	Coin                 string    `db:"coin"`
+	GameOf               time.Time `db:"game_of"`
+	IdempotencyKey       string    `db:"idempotency_key"`
+	Address              string    `db:"address"`
+	Amount               Amount    `db:"amount"`
+	Reason               string    `db:"reason"`
+	DepositTransactionID string    `db:"deposit_tx_id"`
+	DepositVout          uint32    `db:"deposit_vout"`
+	Hash                 string    `db:"hash"`
+	Height               int64     `db:"height"`
+	TransactionID        string    `db:"tx_id"`
+	RawTransaction       string    `db:"raw_tx"` // signed transaction funded for refund, empty until it is funded
+	Status               string    `db:"status"`
+	Orphaned             bool      `db:"orphaned"`
+	CreatedAt            time.Time `db:"created_at"`
+	UpdatedAt            time.Time `db:"updated_at"`
+}
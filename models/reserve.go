@@ -0,0 +1,28 @@
+package models
+
+// Liabilities are amounts jackpot owes out of hot wallet
+type Liabilities struct {
+	OutstandingPots Amount `db:"outstanding_pots"` // deposits of games not drawn yet
+	UnsentPayouts   Amount `db:"unsent_payouts"`
+	PendingRefunds  Amount `db:"pending_refunds"`
+}
+
+// Total returns sum of liabilities
+func (l Liabilities) Total() Amount {
+	return l.OutstandingPots + l.UnsentPayouts + l.PendingRefunds
+}
+
+// Reserve is spendable balance of hot wallet against liabilities
+type Reserve struct {
+	Balance Amount
+	Liabilities
+}
+
+// Ratio returns balance divided by total liabilities, false if nothing is owed
+func (r Reserve) Ratio() (float64, bool) {
+	total := r.Total()
+	if total <= 0 {
+		return 0, false
+	}
+	return float64(r.Balance) / float64(total), true
+}
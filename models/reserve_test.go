@@ -0,0 +1,14 @@
+package models
+
+import "testing"
+
+func TestReserveRatio(t *testing.T) {
+	r := Reserve{Balance: 300, Liabilities: Liabilities{OutstandingPots: 100, UnsentPayouts: 50, PendingRefunds: 50}}
+	if ratio, ok := r.Ratio(); !ok || ratio != 1.5 {
+		t.Errorf("ratio expected 1.5 but get %v, %v", ratio, ok)
+	}
+
+	if _, ok := (Reserve{Balance: 300}).Ratio(); ok {
+		t.Errorf("ratio of reserve owing nothing expected not ok")
+	}
+}
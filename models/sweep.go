@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// sweep status, a sweep is pending from being saved until wallet has its transaction,
+// failed sweep is never sent and its amount stays unswept
+const (
+	SweepStatusPending = "pending"
+	SweepStatusSent    = "sent"
+	SweepStatusFailed  = "failed"
+)
+
+// Sweep model, house fees sent from hot wallet to cold address
+type Sweep struct {
+	ID             int64     `db:"id"`
+	Coin           string    `db:"coin"`
+	IdempotencyKey string    `db:"idempotency_key"`
+	Address        string    `db:"address"`
+	Amount         Amount    `db:"amount"`      // house fees to sweep until it is funded, then amount sent to cold address
+	NetworkFee     Amount    `db:"network_fee"` // paid out of house fees on top of amount
+	TransactionID  string    `db:"tx_id"`
+	RawTransaction string    `db:"raw_tx"` // signed transaction funded for sweep, empty until it is funded
+	Status         string    `db:"status"`
+	LastError      string    `db:"last_error"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
@@ -5,12 +5,17 @@ import "time"
 // Transaction model
 type Transaction struct {
 	ID             int64     `db:"id"`
+	Coin           string    `db:"coin"`
 	Address        string    `db:"address"`
-	Amount         float64   `db:"amount"`
+	Amount         Amount    `db:"amount"`
 	TransactionID  string    `db:"tx_id"`
+	Vout           uint32    `db:"vout"`
+	OutputAddress  string    `db:"output_address"`
 	Confirmations  int64     `db:"confirmations"`
 	Hash           string    `db:"hash"`
+	Height         int64     `db:"height"`
 	GameOf         time.Time `db:"game_of"`
 	BlockCreatedAt time.Time `db:"block_created_at"`
+	Orphaned       bool      `db:"orphaned"`
 	CreatedAt      time.Time `db:"created_at"`
 }
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+	w "github.com/solefaucet/jackpot-server/services/wallet"
+	"github.com/solefaucet/jackpot-server/utils"
+)
+
+// drawGame finds out the winner of game and pays out
+func (c *coin) drawGame(game models.Game, transactions []models.Transaction) error {
+	// no transactions, no winner
+	if len(transactions) == 0 {
+		return c.storage.UpdateGameToEndedStatus(models.Game{GameOf: game.GameOf})
+	}
+
+	totalAmount := totalAmountOfTransactions(transactions)
+	fee := totalAmount.MulRate(c.config.Jackpot.TransactionFee)
+	winAmount := totalAmount - fee
+	winnerAddress := utils.FindWinner(transactions, game.Hash)
+	netAmount, networkFee, err := c.netAmountOfPayout(winnerAddress, winAmount)
+	if err != nil {
+		return err
+	}
+
+	// game stays drawing needed until wallet is topped up, a payout queued without funds would only fail,
+	// network fee leaves wallet too whoever pays it
+	if err := c.checkReserveForPayout(netAmount + networkFee); err != nil {
+		return err
+	}
+
+	g := models.Game{
+		Address:   winnerAddress,
+		WinAmount: winAmount,
+		Fee:       fee,
+		NetAmount: netAmount,
+		GameOf:    game.GameOf,
+	}
+	payout := models.Payout{
+		GameOf:         game.GameOf,
+		IdempotencyKey: payoutIdempotencyKey(game.GameOf),
+		Address:        winnerAddress,
+		Amount:         winAmount,
+	}
+
+	// payout is queued and sent by sendPayouts, so that the game is never paid twice
+	// and a payout failing to send never holds back drawing of other games
+	return c.storage.SavePayoutAndUpdateGameToPayingStatus(g, payout)
+}
+
+// netAmountOfPayout estimates network fee of payout and amount sent to winner after the share of it winner pays by fee policy,
+// game records the estimate until its payout is funded and ends with amount actually sent
+func (c *coin) netAmountOfPayout(address string, winAmount models.Amount) (netAmount, networkFee models.Amount, err error) {
+	networkFee, err = c.wallet.EstimateFee(address, winAmount, c.config.Wallet.FeeConfTarget)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	winnerFee := networkFee
+	switch {
+	case c.config.Jackpot.NetworkFeePolicy == networkFeePolicyHouse:
+		winnerFee = 0
+	case c.config.Jackpot.NetworkFeePolicy == networkFeePolicyCapped && networkFee > c.config.Jackpot.NetworkFeeCap:
+		winnerFee = c.config.Jackpot.NetworkFeeCap
+	}
+
+	if winnerFee >= winAmount {
+		return 0, 0, fmt.Errorf("network fee %v is not less than win amount %v", winnerFee, winAmount)
+	}
+
+	return winAmount - winnerFee, networkFee, nil
+}
+
+// failed payouts are retried with backoff doubling on every attempt up to maxPayoutRetryBackoff
+const (
+	payoutRetryBackoff    = time.Minute
+	maxPayoutRetryBackoff = time.Hour
+)
+
+// maxPayoutBatchSize caps payouts sent in one transaction, it keeps batch key listing their ids short
+const maxPayoutBatchSize = 20
+
+func (c *coin) sendPayoutsJob() {
+	for {
+		c.sendPayouts()
+		c.confirmPayouts()
+		sleepUntilWokenUp(c.payoutsWakeup, time.Minute)
+	}
+}
+
+// sendPayouts sends due payouts, in one transaction if payouts are batched, a failed payout is retried
+// with backoff, or left for operators to requeue if retrying can never fix it
+func (c *coin) sendPayouts() {
+	entry := c.withFields(logrus.Fields{
+		"event": models.LogEventSendPayouts,
+	})
+
+	payouts, err := c.storage.GetDuePayouts(time.Now())
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to get due payouts")
+		return
+	}
+
+	if c.config.Wallet.PayoutBatching {
+		payouts = c.sendPayoutBatch(entry, payouts)
+	}
+
+	for _, payout := range payouts {
+		c.sendPayout(entry, payout)
+	}
+}
+
+// sendPayoutBatch sends payouts that can share a transaction in one, it returns payouts to send one by one,
+// which include every payout of batch if the batch fails to be sent
+func (c *coin) sendPayoutBatch(entry *logrus.Entry, payouts []models.Payout) []models.Payout {
+	batch, rest := splitPayoutBatch(payouts)
+	if len(batch) < 2 {
+		return payouts
+	}
+
+	ids := make([]int64, len(batch))
+	for i, payout := range batch {
+		ids[i] = payout.ID
+	}
+
+	batchKey := payoutBatchIdempotencyKey(ids)
+	e := entry.WithFields(logrus.Fields{
+		"batch_key": batchKey,
+		"payouts":   len(batch),
+	})
+
+	if err := c.storage.UpdatePayoutsToBroadcastingStatus(ids, batchKey); err != nil {
+		e.WithField("error", err.Error()).Error("fail to update payouts status to broadcasting")
+		return payouts
+	}
+
+	// nothing is sent until the batch transaction is recorded, so payouts of batch are sent one by one on failure
+	funded, err := c.fundPayouts(batch)
+	if err == nil {
+		if err = c.storage.UpdatePayoutsTransaction(funded); err != nil {
+			c.wallet.ReleaseTransaction(funded[0].RawTransaction)
+		}
+	}
+	if err != nil {
+		e.WithField("error", err.Error()).Warn("fail to fund payout batch, fall back to sending payouts one by one")
+		return payouts
+	}
+
+	e = e.WithField("tx_id", funded[0].TransactionID)
+	if err := c.wallet.BroadcastTransaction(funded[0].RawTransaction); err != nil {
+		if !w.IsPermanent(err) {
+			// payouts of batch record its transaction, which is broadcast again by each of them
+			e.WithField("error", err.Error()).Warn("fail to broadcast payout batch, broadcast it again for every payout")
+			return append(funded, rest...)
+		}
+
+		return append(c.dropPayoutBatch(e, batch, funded, err), rest...)
+	}
+
+	for _, payout := range funded {
+		c.endGameOfPayout(payoutLogEntry(e, payout), payout, sentOutputOf(payout), payout.NetworkFee)
+	}
+
+	return rest
+}
+
+// dropPayoutBatch handles batch transaction rejected for good, it returns payouts to send one by one.
+// Transaction is dropped and its inputs released only if the wallet does not know it, so that payouts of batch
+// are funded again one by one, otherwise batch is sent already and games of payouts are ended
+func (c *coin) dropPayoutBatch(entry *logrus.Entry, batch, funded []models.Payout, broadcastErr error) []models.Payout {
+	e := entry.WithField("error", broadcastErr.Error())
+
+	// conflicted transaction has negative confirmations, it can never be mined so it is dropped as well
+	confirmations, err := c.wallet.GetTransactionConfirmations(funded[0].TransactionID)
+	if err == nil && confirmations >= 0 {
+		e.WithField("confirmations", confirmations).Warn("wallet knows rejected payout batch, mark payouts broadcast")
+		for _, payout := range funded {
+			c.endGameOfPayout(payoutLogEntry(e, payout), payout, sentOutputOf(payout), payout.NetworkFee)
+		}
+		return nil
+	}
+
+	// payouts of batch are failed with its transaction recorded, which operators requeue after checking it
+	if err != nil && err != jerrors.ErrNotFound {
+		e.WithField("check_error", err.Error()).Error("fail to check rejected payout batch in wallet")
+		for _, payout := range funded {
+			c.failPayout(entry, payout, broadcastErr)
+		}
+		return nil
+	}
+
+	if err := c.storage.UpdatePayoutsTransaction(batch); err != nil {
+		e.WithField("storage_error", err.Error()).Error("fail to drop transaction of rejected payout batch")
+		return funded
+	}
+
+	if err := c.wallet.ReleaseTransaction(funded[0].RawTransaction); err != nil {
+		e.WithField("release_error", err.Error()).Warn("fail to release inputs of rejected payout batch")
+	}
+
+	e.Warn("payout batch rejected, send payouts one by one")
+	return batch
+}
+
+// splitPayoutBatch splits payouts into ones sent in a batch and the rest, batch pays every address once,
+// payouts left broadcasting may have been sent already and funded ones are broadcast as recorded, so they are never batched
+func splitPayoutBatch(payouts []models.Payout) (batch, rest []models.Payout) {
+	addresses := make(map[string]bool)
+	for _, payout := range payouts {
+		if payout.Status == models.PayoutStatusBroadcasting || payout.TransactionID != "" || addresses[payout.Address] || len(batch) >= maxPayoutBatchSize {
+			rest = append(rest, payout)
+			continue
+		}
+
+		addresses[payout.Address] = true
+		batch = append(batch, payout)
+	}
+
+	return batch, rest
+}
+
+func (c *coin) sendPayout(entry *logrus.Entry, payout models.Payout) {
+	e := payoutLogEntry(entry, payout)
+	if err := c.storage.UpdatePayoutToBroadcastingStatus(payout.ID); err != nil {
+		e.WithField("error", err.Error()).Error("fail to update payout status to broadcasting")
+		return
+	}
+
+	output, networkFee, err := c.broadcastPayout(payout)
+	if err != nil {
+		c.failPayout(e, payout, err)
+		return
+	}
+
+	c.endGameOfPayout(e, payout, output, networkFee)
+}
+
+// endGameOfPayout ends game of payout sent in output, payout stays broadcasting on failure and its recorded transaction is broadcast again next time
+func (c *coin) endGameOfPayout(entry *logrus.Entry, payout models.Payout, output w.SentOutput, networkFee models.Amount) {
+	e := entry.WithFields(logrus.Fields{
+		"tx_id": output.TransactionID,
+		"vout":  output.Vout,
+	})
+
+	if err := c.storage.UpdateGameToEndedStatus(models.Game{TransactionID: output.TransactionID, Vout: output.Vout, NetAmount: output.Amount, NetworkFee: networkFee, GameOf: payout.GameOf}); err != nil {
+		e.WithField("error", err.Error()).Error("fail to update game status to ended")
+		return
+	}
+
+	e.Info("payout broadcast")
+}
+
+// broadcastPayout broadcasts transaction recorded for payout, funding and recording one first if there is none,
+// so that a payout retried is broadcast as the same transaction and never paid twice,
+// payout left broadcasting without transaction was never funded as transaction is recorded before it is broadcast
+func (c *coin) broadcastPayout(payout models.Payout) (w.SentOutput, models.Amount, error) {
+	if payout.RawTransaction == "" {
+		funded, err := c.fundPayouts([]models.Payout{payout})
+		if err != nil {
+			return w.SentOutput{}, 0, err
+		}
+
+		// transaction is recorded before it is broadcast, so that it is the one broadcast again if anything fails after
+		if err := c.storage.UpdatePayoutsTransaction(funded); err != nil {
+			c.wallet.ReleaseTransaction(funded[0].RawTransaction)
+			return w.SentOutput{}, 0, err
+		}
+		payout = funded[0]
+	}
+
+	if err := c.wallet.BroadcastTransaction(payout.RawTransaction); err != nil {
+		return w.SentOutput{}, 0, err
+	}
+
+	return sentOutputOf(payout), payout.NetworkFee, nil
+}
+
+// sentOutputOf returns output paying payout in transaction recorded for it
+func sentOutputOf(payout models.Payout) w.SentOutput {
+	return w.SentOutput{
+		TransactionID: payout.TransactionID,
+		Vout:          payout.Vout,
+		Address:       payout.Address,
+		Amount:        payout.Amount,
+	}
+}
+
+// fundPayouts funds a transaction paying payouts at estimated fee rate and records it in payouts,
+// network fee is taken off outputs as much as winners pay by fee policy and house pays the rest on top,
+// payouts share network fee evenly
+func (c *coin) fundPayouts(payouts []models.Payout) ([]models.Payout, error) {
+	policy, networkFeeCap := c.config.Jackpot.NetworkFeePolicy, c.config.Jackpot.NetworkFeeCap
+	amounts := make(map[string]models.Amount, len(payouts))
+	subtractFeeFrom := []string{}
+	for _, payout := range payouts {
+		amounts[payout.Address] = payout.Amount
+		if policy != networkFeePolicyHouse {
+			subtractFeeFrom = append(subtractFeeFrom, payout.Address)
+		}
+	}
+
+	funded, err := c.wallet.FundTransaction(amounts, subtractFeeFrom, c.config.Wallet.FeeConfTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	// winners pay no more than cap, so transaction is funded again with cap taken off and house paying network fee
+	n := models.Amount(len(payouts))
+	if policy == networkFeePolicyCapped && funded.Fee/n > networkFeeCap {
+		for _, payout := range payouts {
+			amounts[payout.Address] = payout.Amount - networkFeeCap
+		}
+
+		// inputs locked for the transaction funded first are spent by the one funded again
+		c.wallet.ReleaseTransaction(funded.RawTransaction)
+		if funded, err = c.wallet.FundTransaction(amounts, nil, c.config.Wallet.FeeConfTarget); err != nil {
+			return nil, err
+		}
+	}
+
+	// the first payout pays the remainder, so that network fees recorded sum to the fee paid
+	fundedPayouts := make([]models.Payout, len(payouts))
+	for i, payout := range payouts {
+		output, ok := funded.OutputTo(payout.Address)
+		if !ok {
+			c.wallet.ReleaseTransaction(funded.RawTransaction)
+			return nil, fmt.Errorf("funded transaction %v has no output to %v", funded.TransactionID, payout.Address)
+		}
+
+		payout.TransactionID = funded.TransactionID
+		payout.Vout = output.Vout
+		payout.Amount = output.Amount
+		payout.NetworkFee = funded.Fee / n
+		if i == 0 {
+			payout.NetworkFee += funded.Fee % n
+		}
+		payout.RawTransaction = funded.RawTransaction
+		fundedPayouts[i] = payout
+	}
+
+	return fundedPayouts, nil
+}
+
+func payoutLogEntry(entry *logrus.Entry, payout models.Payout) *logrus.Entry {
+	return entry.WithFields(logrus.Fields{
+		"game_of":         payout.GameOf,
+		"idempotency_key": payout.IdempotencyKey,
+		"address":         payout.Address,
+		"amount":          payout.Amount,
+		"status":          payout.Status,
+	})
+}
+
+// failPayout records failed attempt of payout, it is failed permanently if wallet tells retrying can never fix it
+func (c *coin) failPayout(entry *logrus.Entry, payout models.Payout, err error) {
+	attempts := payout.Attempts + 1
+	status, nextAttemptAt := models.PayoutStatusFailedRetryable, time.Now().Add(payoutBackoff(attempts))
+	if w.IsPermanent(err) {
+		status = models.PayoutStatusFailedPermanent
+	}
+
+	e := entry.WithFields(logrus.Fields{
+		"error":           err.Error(),
+		"attempts":        attempts,
+		"failed_status":   status,
+		"next_attempt_at": nextAttemptAt,
+	})
+
+	if err := c.storage.UpdatePayoutToFailedStatus(payout.ID, status, err.Error(), nextAttemptAt); err != nil {
+		e.WithField("storage_error", err.Error()).Error("fail to update payout status to failed")
+		return
+	}
+
+	e.Error("fail to send payout")
+}
+
+// payoutBackoff returns how long to wait before attempting payout again after attempts failed ones
+func payoutBackoff(attempts int64) time.Duration {
+	backoff := payoutRetryBackoff
+	for i := int64(1); i < attempts && backoff < maxPayoutRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxPayoutRetryBackoff {
+		return maxPayoutRetryBackoff
+	}
+	return backoff
+}
+
+// requeuePayout queues failed payout to be sent right away, recorded transaction of permanently failed payout is dropped
+// and funded again only if the wallet does not know it, e.g. rebroadcast is rejected for missing inputs
+// as the transaction is mined already, payout whose transaction the wallet knows is marked broadcast instead
+func (c *coin) requeuePayout(id int64) error {
+	payouts, err := c.storage.GetUnsentPayouts()
+	if err != nil {
+		return err
+	}
+
+	rawTransaction := ""
+	for _, payout := range payouts {
+		if payout.ID != id || payout.Status != models.PayoutStatusFailedPermanent || payout.TransactionID == "" {
+			continue
+		}
+
+		// conflicted transaction has negative confirmations, it can never be mined so it is dropped as well
+		confirmations, err := c.wallet.GetTransactionConfirmations(payout.TransactionID)
+		if err != nil && err != jerrors.ErrNotFound {
+			return err
+		}
+
+		if err == nil && confirmations >= 0 {
+			output := sentOutputOf(payout)
+			if err := c.storage.UpdateGameToEndedStatus(models.Game{TransactionID: output.TransactionID, Vout: output.Vout, NetAmount: output.Amount, NetworkFee: payout.NetworkFee, GameOf: payout.GameOf}); err != nil {
+				return err
+			}
+
+			payoutLogEntry(c.withFields(logrus.Fields{"event": models.LogEventRequeuePayout}), payout).WithFields(logrus.Fields{
+				"tx_id":         payout.TransactionID,
+				"confirmations": confirmations,
+			}).Warn("wallet knows transaction of failed payout, mark it broadcast instead of requeueing")
+			return nil
+		}
+		rawTransaction = payout.RawTransaction
+	}
+
+	if err := c.storage.RequeuePayout(id); err != nil {
+		return err
+	}
+
+	// inputs locked for dropped transaction are spent by the one funded again
+	if rawTransaction != "" {
+		if err := c.wallet.ReleaseTransaction(rawTransaction); err != nil {
+			c.withFields(logrus.Fields{"event": models.LogEventRequeuePayout, "payout_id": id}).WithField("error", err.Error()).Warn("fail to release inputs of dropped payout transaction")
+		}
+	}
+
+	return nil
+}
+
+// confirmPayouts marks broadcast payouts with enough confirmations as confirmed
+func (c *coin) confirmPayouts() {
+	entry := c.withFields(logrus.Fields{
+		"event": models.LogEventConfirmPayouts,
+	})
+
+	payouts, err := c.storage.GetBroadcastPayouts()
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to get broadcast payouts")
+		return
+	}
+
+	for _, payout := range payouts {
+		e := entry.WithFields(logrus.Fields{
+			"game_of": payout.GameOf,
+			"tx_id":   payout.TransactionID,
+		})
+
+		confirmations, err := c.wallet.GetTransactionConfirmations(payout.TransactionID)
+		if err != nil {
+			e.WithField("error", err.Error()).Error("fail to get confirmations of payout")
+			continue
+		}
+
+		if confirmations < c.config.Wallet.MinConfirms {
+			continue
+		}
+
+		if err := c.storage.UpdatePayoutToConfirmedStatus(payout.ID); err != nil {
+			e.WithField("error", err.Error()).Error("fail to update payout status to confirmed")
+			continue
+		}
+
+		e.WithField("confirmations", confirmations).Info("payout confirmed")
+	}
+}
+
+func payoutIdempotencyKey(gameOf time.Time) string {
+	return "jackpot payout of " + gameOf.UTC().Format(time.RFC3339)
+}
+
+// payoutBatchIdempotencyKey is the same for the same payouts, it records batch payout was last sent in
+func payoutBatchIdempotencyKey(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	return "jackpot payout batch of " + strings.Join(s, ",")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+	w "github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// applyBetLimits caps deposits of block by bet limits, it returns deposits with the amounts counted in game
+// and refunds of deposits below min bet or of portions above max bet per deposit or per address in game
+func (c *coin) applyBetLimits(gameOf time.Time, block *w.Block, transactions []models.Transaction) ([]models.Transaction, []models.Refund, error) {
+	limits := c.config.Jackpot
+	if limits.MinBet == 0 && limits.MaxBet == 0 && limits.MaxBetPerAddress == 0 {
+		return transactions, nil, nil
+	}
+
+	storedTransactions, err := c.storage.GetTransactionsByGameOfs(gameOf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	betOfAddress := make(map[string]models.Amount)
+	storedOutputs := make(map[string]bool)
+	for _, v := range storedTransactions {
+		betOfAddress[v.Address] += v.Amount
+		storedOutputs[outputOf(v.TransactionID, v.Vout)] = true
+	}
+
+	accepted := []models.Transaction{}
+	refunds := []models.Refund{}
+	for _, v := range transactions {
+		// output on main chain has been limited when it was saved
+		if storedOutputs[outputOf(v.TransactionID, v.Vout)] {
+			accepted = append(accepted, v)
+			continue
+		}
+
+		counted, reason := v.Amount, ""
+		switch {
+		case v.Amount < limits.MinBet:
+			counted, reason = 0, models.RefundReasonBelowMinBet
+		case limits.MaxBet > 0 && v.Amount > limits.MaxBet:
+			counted, reason = limits.MaxBet, models.RefundReasonAboveMaxBet
+		}
+
+		if room := limits.MaxBetPerAddress - betOfAddress[v.Address]; limits.MaxBetPerAddress > 0 && counted > 0 && counted > room {
+			if room < 0 {
+				room = 0
+			}
+			counted, reason = room, models.RefundReasonAboveMaxBetPerAddress
+		}
+
+		if counted < v.Amount {
+			refunds = append(refunds, models.Refund{
+				GameOf:               gameOf,
+				IdempotencyKey:       refundIdempotencyKey(v.TransactionID, v.Vout),
+				Address:              v.Address,
+				Amount:               v.Amount - counted,
+				Reason:               reason,
+				DepositTransactionID: v.TransactionID,
+				DepositVout:          v.Vout,
+				Hash:                 block.Hash,
+				Height:               block.Height,
+			})
+		}
+
+		if counted > 0 {
+			v.Amount = counted
+			betOfAddress[v.Address] += counted
+			accepted = append(accepted, v)
+		}
+	}
+
+	return accepted, refunds, nil
+}
+
+func (c *coin) sendRefundsJob() {
+	for {
+		c.sendRefunds()
+		sleepUntilWokenUp(c.refundsWakeup, time.Minute)
+	}
+}
+
+// sendRefunds sends refunds of deposits with enough confirmations, house pays network fee on top of refund,
+// transaction of refund is recorded before it is broadcast so that a refund retried is never sent twice
+func (c *coin) sendRefunds() {
+	entry := c.withFields(logrus.Fields{
+		"event": models.LogEventSendRefunds,
+	})
+
+	block, err := c.storage.GetLatestBlock()
+	if err == jerrors.ErrNotFound {
+		return
+	}
+
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to get latest block")
+		return
+	}
+
+	refunds, err := c.storage.GetPendingRefunds(block.Height - c.config.Wallet.MinConfirms + 1)
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to get pending refunds")
+		return
+	}
+
+	for _, refund := range refunds {
+		e := entry.WithFields(logrus.Fields{
+			"idempotency_key": refund.IdempotencyKey,
+			"address":         refund.Address,
+			"amount":          refund.Amount,
+			"reason":          refund.Reason,
+		})
+
+		refund, err := c.broadcastRefund(refund)
+		rejected := err != nil && w.IsPermanent(err) && refund.TransactionID != ""
+		if rejected && !c.knowsTransaction(e, refund.TransactionID) {
+			c.dropRefundTransaction(e, refund, err)
+			continue
+		}
+
+		if err != nil && !rejected {
+			e.WithField("error", err.Error()).Error("fail to send refund")
+			continue
+		}
+
+		if rejected {
+			e.WithField("error", err.Error()).Warn("wallet knows transaction of rejected refund, mark it sent")
+		}
+
+		if err := c.storage.UpdateRefundToSentStatus(refund.ID, refund.TransactionID); err != nil {
+			e.WithFields(logrus.Fields{
+				"error": err.Error(),
+				"tx_id": refund.TransactionID,
+			}).Error("fail to update refund status to sent")
+			continue
+		}
+
+		e.WithField("tx_id", refund.TransactionID).Info("refund sent")
+	}
+}
+
+// broadcastRefund broadcasts transaction recorded for refund, funding and recording one first if there is none
+func (c *coin) broadcastRefund(refund models.Refund) (models.Refund, error) {
+	if refund.RawTransaction == "" {
+		amounts := map[string]models.Amount{refund.Address: refund.Amount}
+		funded, err := c.wallet.FundTransaction(amounts, nil, c.config.Wallet.FeeConfTarget)
+		if err != nil {
+			return refund, err
+		}
+
+		recorded := refund
+		recorded.TransactionID, recorded.RawTransaction = funded.TransactionID, funded.RawTransaction
+		if err := c.storage.UpdateRefundTransaction(recorded); err != nil {
+			c.wallet.ReleaseTransaction(funded.RawTransaction)
+			return refund, err
+		}
+		refund = recorded
+	}
+
+	return refund, c.wallet.BroadcastTransaction(refund.RawTransaction)
+}
+
+// dropRefundTransaction drops transaction of refund rejected for good and releases its inputs,
+// so that refund is funded again next round
+func (c *coin) dropRefundTransaction(entry *logrus.Entry, refund models.Refund, broadcastErr error) {
+	e := entry.WithFields(logrus.Fields{
+		"error": broadcastErr.Error(),
+		"tx_id": refund.TransactionID,
+	})
+
+	dropped := refund
+	dropped.TransactionID, dropped.RawTransaction = "", ""
+	if err := c.storage.UpdateRefundTransaction(dropped); err != nil {
+		e.WithField("storage_error", err.Error()).Error("fail to drop transaction of rejected refund")
+		return
+	}
+
+	if err := c.wallet.ReleaseTransaction(refund.RawTransaction); err != nil {
+		e.WithField("release_error", err.Error()).Warn("fail to release inputs of rejected refund")
+	}
+
+	e.Error("refund rejected, fund it again next round")
+}
+
+func outputOf(transactionID string, vout uint32) string {
+	return fmt.Sprintf("%v:%v", transactionID, vout)
+}
+
+func refundIdempotencyKey(transactionID string, vout uint32) string {
+	return "jackpot refund of " + outputOf(transactionID, vout)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func (c *coin) monitorBalanceJob() {
+	for {
+		c.monitorBalance()
+		time.Sleep(c.config.Wallet.BalanceCheckInterval)
+	}
+}
+
+// monitorBalance alerts operators when hot wallet balance falls short of reserve ratio thresholds,
+// so that wallet is topped up before games cannot be drawn
+func (c *coin) monitorBalance() {
+	entry := c.withFields(logrus.Fields{
+		"event": models.LogEventMonitorBalance,
+	})
+
+	reserve, err := c.reserve()
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to get reserve")
+		return
+	}
+
+	ratio, ok := reserve.Ratio()
+	entry = entry.WithFields(logrus.Fields{
+		"balance":          reserve.Balance,
+		"outstanding_pots": reserve.OutstandingPots,
+		"unsent_payouts":   reserve.UnsentPayouts,
+		"pending_refunds":  reserve.PendingRefunds,
+		"reserve_ratio":    ratio,
+	})
+
+	switch {
+	case !ok:
+		entry.Debug("nothing is owed")
+	case ratio < c.config.Wallet.ReserveCriticalRatio:
+		entry.Error("reserve ratio is below critical threshold")
+	case ratio < c.config.Wallet.ReserveWarningRatio:
+		entry.Warn("reserve ratio is below warning threshold")
+	default:
+		entry.Debug("reserve ratio is healthy")
+	}
+}
+
+// reserve returns spendable balance against what is owed, pots are owed to winners less house fee,
+// liabilities are read before balance so that a payout sent in between is never counted as still in wallet
+func (c *coin) reserve() (models.Reserve, error) {
+	liabilities, err := c.storage.GetLiabilities()
+	if err != nil {
+		return models.Reserve{}, err
+	}
+	liabilities.OutstandingPots -= liabilities.OutstandingPots.MulRate(c.config.Jackpot.TransactionFee)
+
+	balance, err := c.wallet.GetBalance()
+	if err != nil {
+		return models.Reserve{}, err
+	}
+
+	return models.Reserve{Balance: balance, Liabilities: liabilities}, nil
+}
+
+// checkReserveForPayout refuses payout of amount that balance cannot cover on top of payouts and refunds already owed,
+// pots of other games are not counted as they may never be owed in full, e.g. refunded deposits
+func (c *coin) checkReserveForPayout(amount models.Amount) error {
+	reserve, err := c.reserve()
+	if err != nil {
+		return err
+	}
+
+	if owed := reserve.UnsentPayouts + reserve.PendingRefunds + amount; reserve.Balance < owed {
+		return fmt.Errorf("balance %v cannot pay out %v besides unsent payouts %v and pending refunds %v", reserve.Balance, amount, reserve.UnsentPayouts, reserve.PendingRefunds)
+	}
+
+	return nil
+}
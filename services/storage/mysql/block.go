@@ -3,6 +3,7 @@ package mysql
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/solefaucet/jackpot-server/jerrors"
@@ -12,7 +13,7 @@ import (
 // GetLatestBlock gets latest models.Block
 func (s Storage) GetLatestBlock() (models.Block, error) {
 	block := models.Block{}
-	err := s.db.Get(&block, "SELECT * FROM `blocks` ORDER BY `height` DESC LIMIT 1")
+	err := s.db.Get(&block, "SELECT * FROM `blocks` WHERE `coin` = ? AND `orphaned` = 0 ORDER BY `height` DESC LIMIT 1", s.coin)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -25,11 +26,73 @@ func (s Storage) GetLatestBlock() (models.Block, error) {
 	return block, nil
 }
 
-func saveBlock(tx *sqlx.Tx, block models.Block) error {
-	_, err := tx.NamedExec("INSERT INTO `blocks` (`hash`, `height`, `block_created_at`) VALUES (:hash, :height, :block_created_at)", block)
+// GetBlockByHeight gets models.Block on the main chain by height
+func (s Storage) GetBlockByHeight(height int64) (models.Block, error) {
+	block := models.Block{}
+	err := s.db.Get(&block, "SELECT * FROM `blocks` WHERE `coin` = ? AND `height` = ? AND `orphaned` = 0", s.coin, height)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return block, jerrors.ErrNotFound
+		}
+
+		return block, fmt.Errorf("get block by height error: %#v", err)
+	}
+
+	return block, nil
+}
+
+// OrphanBlocksAfter marks blocks above height as orphaned,
+// together with their transactions and contributions to games
+func (s Storage) OrphanBlocksAfter(height int64) error {
+	return s.withTx(func(tx *sqlx.Tx) error {
+		hashes := []string{}
+		if err := tx.Select(&hashes, "SELECT `hash` FROM `blocks` WHERE `coin` = ? AND `height` > ? AND `orphaned` = 0", s.coin, height); err != nil {
+			return fmt.Errorf("get blocks to orphan error: %#v", err)
+		}
+
+		if len(hashes) == 0 {
+			return nil
+		}
+
+		if err := s.orphanTransactions(tx, hashes); err != nil {
+			return err
+		}
+
+		if err := s.orphanExcludedTransactions(tx, hashes); err != nil {
+			return err
+		}
+
+		if err := s.orphanRefunds(tx, hashes); err != nil {
+			return err
+		}
+
+		if err := s.resetGamesDrawnOnOrphanedBlocks(tx, hashes); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE `blocks` SET `orphaned` = 1 WHERE `coin` = ? AND `height` > ? AND `orphaned` = 0", s.coin, height); err != nil {
+			return fmt.Errorf("orphan blocks error: %#v", err)
+		}
+
+		return nil
+	})
+}
+
+func (s Storage) saveBlock(tx *sqlx.Tx, block models.Block) error {
+	block.Coin = s.coin
+	// a block that was orphaned can come back to the main chain after another reorganization
+	_, err := tx.NamedExec("INSERT INTO `blocks` (`coin`, `hash`, `height`, `block_created_at`, `median_time`) VALUES (:coin, :hash, :height, :block_created_at, :median_time) ON DUPLICATE KEY UPDATE `height` = :height, `block_created_at` = :block_created_at, `median_time` = :median_time, `orphaned` = 0", block)
 	if err != nil {
 		return fmt.Errorf("save block error: %#v", err)
 	}
 
 	return nil
 }
+
+// GetBlockCreatedAtsBefore gets block_created_at of n blocks on main chain right below height
+func (s Storage) GetBlockCreatedAtsBefore(height, n int64) ([]time.Time, error) {
+	createdAts := []time.Time{}
+	err := s.db.Select(&createdAts, "SELECT `block_created_at` FROM `blocks` WHERE `coin` = ? AND `height` < ? AND `height` >= ? AND `orphaned` = 0", s.coin, height, height-n)
+	return createdAts, err
+}
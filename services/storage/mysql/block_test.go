@@ -16,7 +16,7 @@ func TestSaveBlock(t *testing.T) {
 
 		Convey("When save block", func() {
 			err := s.withTx(func(tx *sqlx.Tx) error {
-				return saveBlock(tx, models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()})
+				return s.saveBlock(tx, models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()})
 			})
 
 			Convey("Error should be nil", func() {
@@ -31,7 +31,7 @@ func TestSaveBlock(t *testing.T) {
 		Convey("When save block with commited connection", func() {
 			err := s.withTx(func(tx *sqlx.Tx) error {
 				tx.Commit()
-				return saveBlock(tx, models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()})
+				return s.saveBlock(tx, models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()})
 			})
 
 			Convey("Error should not be nil", func() {
@@ -57,10 +57,10 @@ func TestGetLatestBlock(t *testing.T) {
 	Convey("Given mysql storage with block data", t, func() {
 		s := prepareDatabaseForTesting()
 		s.withTx(func(tx *sqlx.Tx) error {
-			return saveBlock(tx, models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()})
+			return s.saveBlock(tx, models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()})
 		})
 		s.withTx(func(tx *sqlx.Tx) error {
-			return saveBlock(tx, models.Block{Hash: "hash2", Height: 2, BlockCreatedAt: time.Now()})
+			return s.saveBlock(tx, models.Block{Hash: "hash2", Height: 2, BlockCreatedAt: time.Now()})
 		})
 
 		Convey("When get latest block", func() {
@@ -77,3 +77,144 @@ func TestGetLatestBlock(t *testing.T) {
 		return err
 	})
 }
+
+func TestGetBlockByHeight(t *testing.T) {
+	Convey("Given mysql storage with block data", t, func() {
+		s := prepareDatabaseForTesting()
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.saveBlock(tx, models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()})
+		})
+
+		Convey("When get block by existing height", func() {
+			block, err := s.GetBlockByHeight(1)
+
+			Convey("Hash should be hash", func() {
+				So(err, ShouldBeNil)
+				So(block.Hash, ShouldEqual, "hash")
+			})
+		})
+
+		Convey("When get block by missing height", func() {
+			_, err := s.GetBlockByHeight(2)
+
+			Convey("Error should be not found", func() {
+				So(err, ShouldEqual, jerrors.ErrNotFound)
+			})
+		})
+	})
+
+	withClosedConn(t, "When get block by height", func(s Storage) error {
+		_, err := s.GetBlockByHeight(1)
+		return err
+	})
+}
+
+func TestGetBlockCreatedAtsBefore(t *testing.T) {
+	Convey("Given mysql storage with blocks and orphaned block", t, func() {
+		s := prepareDatabaseForTesting()
+		now := time.Now().UTC().Truncate(time.Second)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.saveBlock(tx, models.Block{Hash: "hash1", Height: 1, BlockCreatedAt: now.Add(-2 * time.Minute)})
+			s.saveBlock(tx, models.Block{Hash: "hash2", Height: 2, BlockCreatedAt: now.Add(-time.Minute)})
+			return s.saveBlock(tx, models.Block{Hash: "hash3", Height: 3, BlockCreatedAt: now})
+		})
+		s.OrphanBlocksAfter(2)
+
+		Convey("When get block created ats of 2 blocks before height 4", func() {
+			createdAts, err := s.GetBlockCreatedAtsBefore(4, 2)
+
+			Convey("Only block on main chain should be included", func() {
+				So(err, ShouldBeNil)
+				So(len(createdAts), ShouldEqual, 1)
+				So(createdAts[0].Equal(now.Add(-time.Minute)), ShouldBeTrue)
+			})
+		})
+	})
+
+	withClosedConn(t, "When get block created ats before", func(s Storage) error {
+		_, err := s.GetBlockCreatedAtsBefore(1, 10)
+		return err
+	})
+}
+
+func TestOrphanBlocksAfter(t *testing.T) {
+	Convey("Given mysql storage with blocks, transactions and games", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.SaveBlockAndTransactions(models.Game{GameOf: gameOf}, models.Block{Hash: "hash1", Height: 1, BlockCreatedAt: time.Now()}, nil, nil, nil)
+		s.SaveBlockAndTransactions(models.Game{GameOf: gameOf}, models.Block{Hash: "hash2", Height: 2, BlockCreatedAt: time.Now()}, []models.Transaction{
+			{Address: "addr", Amount: 10, TransactionID: "id", Hash: "hash2", GameOf: gameOf, BlockCreatedAt: time.Now()},
+		}, nil, nil)
+
+		Convey("When orphan blocks after height 1", func() {
+			err := s.OrphanBlocksAfter(1)
+			block, _ := s.GetLatestBlock()
+			transactions, _ := s.GetTransactionsByGameOfs(gameOf)
+			games, _ := s.GetGames(1, 0)
+
+			Convey("Orphaned block and transactions should be excluded", func() {
+				So(err, ShouldBeNil)
+				So(block.Hash, ShouldEqual, "hash1")
+				So(transactions, ShouldBeEmpty)
+				So(games[0].TotalAmount, ShouldEqual, models.Amount(0))
+			})
+		})
+	})
+
+	Convey("Given mysql storage with a game drawn on block 2 and its queued payout", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.SaveBlockAndTransactions(models.Game{GameOf: gameOf}, models.Block{Hash: "hash1", Height: 1, BlockCreatedAt: time.Now()}, nil, nil, nil)
+		s.SaveBlockAndTransactions(models.Game{GameOf: gameOf.Add(time.Hour)}, models.Block{Hash: "hash2", Height: 2, BlockCreatedAt: time.Now()}, nil, nil, nil)
+		game := models.Game{Address: "addr", WinAmount: 90000000, Fee: 10000000, NetAmount: 89990000, GameOf: gameOf}
+		s.SavePayoutAndUpdateGameToPayingStatus(game, models.Payout{GameOf: gameOf, IdempotencyKey: "key", Address: "addr", Amount: 90000000})
+
+		Convey("When orphan blocks after height 1", func() {
+			err := s.OrphanBlocksAfter(1)
+			payouts, _ := s.GetUnsentPayouts()
+			drawingNeeded, _ := s.GetDrawingNeededGames()
+			games, _ := s.GetGames(2, 0)
+
+			Convey("Payout should be cancelled and game should wait for the new block at height 2", func() {
+				So(err, ShouldBeNil)
+				So(payouts, ShouldBeEmpty)
+				So(drawingNeeded, ShouldBeEmpty)
+				So(games[1].Status, ShouldEqual, models.GameStatusDrawingNeeded)
+				So(games[1].Hash, ShouldEqual, "hash2")
+			})
+
+			Convey("When save the new block at height 2", func() {
+				s.SaveBlockAndTransactions(models.Game{GameOf: gameOf.Add(time.Hour)}, models.Block{Hash: "hash2a", Height: 2, BlockCreatedAt: time.Now()}, nil, nil, nil)
+				games, _ := s.GetDrawingNeededGames()
+
+				Convey("Game should be drawn on the new block", func() {
+					So(len(games), ShouldEqual, 1)
+					So(games[0].GameOf, ShouldResemble, gameOf)
+					So(games[0].Hash, ShouldEqual, "hash2a")
+				})
+			})
+		})
+
+		Convey("When orphan blocks after height 1 with payout transaction funded", func() {
+			payouts, _ := s.GetDuePayouts(time.Now())
+			payout := payouts[0]
+			s.UpdatePayoutToBroadcastingStatus(payout.ID)
+			payout.TransactionID, payout.RawTransaction = "tx_id", "raw_tx"
+			s.UpdatePayoutsTransaction([]models.Payout{payout})
+			s.UpdatePayoutToFailedStatus(payout.ID, models.PayoutStatusFailedRetryable, "error", time.Now())
+			err := s.OrphanBlocksAfter(1)
+			payouts, _ = s.GetUnsentPayouts()
+			games, _ := s.GetGames(2, 0)
+
+			Convey("Payout should be held and game should stay paying", func() {
+				So(err, ShouldBeNil)
+				So(payouts[0].Status, ShouldEqual, models.PayoutStatusFailedPermanent)
+				So(games[1].Status, ShouldEqual, models.GameStatusPaying)
+			})
+		})
+	})
+
+	withClosedConn(t, "When orphan blocks after", func(s Storage) error {
+		return s.OrphanBlocksAfter(1)
+	})
+}
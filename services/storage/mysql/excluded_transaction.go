@@ -0,0 +1,47 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func (s Storage) saveExcludedTransactions(tx *sqlx.Tx, transactions []models.ExcludedTransaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	// orphaned output mined again is reactivated
+	stmt, _ := tx.PrepareNamed("INSERT INTO `excluded_transactions` (`coin`, `address`, `amount`, `tx_id`, `vout`, `output_address`, `hash`, `reason`, `detail`, `block_created_at`) VALUES (:coin, :address, :amount, :tx_id, :vout, :output_address, :hash, :reason, :detail, :block_created_at) ON DUPLICATE KEY UPDATE `address` = VALUES(`address`), `amount` = VALUES(`amount`), `output_address` = VALUES(`output_address`), `hash` = VALUES(`hash`), `reason` = VALUES(`reason`), `detail` = VALUES(`detail`), `block_created_at` = VALUES(`block_created_at`), `orphaned` = 0")
+	defer stmt.Close()
+
+	for _, v := range transactions {
+		v.Coin = s.coin
+		if _, err := stmt.Exec(v); err != nil {
+			return fmt.Errorf("save excluded transactions error: %#v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s Storage) orphanExcludedTransactions(tx *sqlx.Tx, hashes []string) error {
+	sql, args, err := sqlx.In("UPDATE `excluded_transactions` SET `orphaned` = 1 WHERE `coin` = ? AND `hash` IN (?)", s.coin, hashes)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("orphan excluded transactions error: %#v", err)
+	}
+
+	return nil
+}
+
+// GetExcludedTransactions gets excluded transactions on the main chain order by id desc, limit n, offset n
+func (s Storage) GetExcludedTransactions(limit, offset int64) ([]models.ExcludedTransaction, error) {
+	transactions := []models.ExcludedTransaction{}
+	err := s.db.Select(&transactions, "SELECT * FROM `excluded_transactions` WHERE `coin` = ? AND `orphaned` = 0 ORDER BY `id` DESC LIMIT ? OFFSET ?", s.coin, limit, offset)
+	return transactions, err
+}
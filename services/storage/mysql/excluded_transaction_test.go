@@ -0,0 +1,40 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestGetExcludedTransactions(t *testing.T) {
+	Convey("Given mysql storage with excluded transactions", t, func() {
+		s := prepareDatabaseForTesting()
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.saveExcludedTransactions(tx, []models.ExcludedTransaction{
+				{Amount: 10, TransactionID: "id1", Hash: "hash1", Reason: models.ExcludedReasonUnattributed, BlockCreatedAt: time.Now()},
+				{Amount: 10, TransactionID: "id2", Hash: "hash2", Reason: models.ExcludedReasonUnattributed, BlockCreatedAt: time.Now()},
+			})
+		})
+
+		Convey("When get excluded transactions after orphaning one", func() {
+			s.withTx(func(tx *sqlx.Tx) error {
+				return s.orphanExcludedTransactions(tx, []string{"hash2"})
+			})
+			transactions, err := s.GetExcludedTransactions(10, 0)
+
+			Convey("Only transaction on main chain should be returned", func() {
+				So(err, ShouldBeNil)
+				So(len(transactions), ShouldEqual, 1)
+				So(transactions[0].TransactionID, ShouldEqual, "id1")
+			})
+		})
+	})
+
+	withClosedConn(t, "When get excluded transactions", func(s Storage) error {
+		_, err := s.GetExcludedTransactions(10, 0)
+		return err
+	})
+}
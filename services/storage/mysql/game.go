@@ -1,20 +1,36 @@
 package mysql
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/solefaucet/jackpot-server/jerrors"
 	"github.com/solefaucet/jackpot-server/models"
 )
 
-func upsertGame(tx *sqlx.Tx, gameOf time.Time, hash string, height int64, totalAmount float64) error {
-	sql := "INSERT INTO `games` (`hash`, `height`, `total_amount`, `game_of`) VALUES (:hash, :height, :total_amount, :game_of) ON DUPLICATE KEY UPDATE `hash` = :hash, `height` = :height, `total_amount` = `total_amount` + :total_amount"
-	_, err := tx.NamedExec(sql, map[string]interface{}{
-		"hash":         hash,
+// upsertGame adds totalAmount of block to pending game, it returns jerrors.ErrGameClosed if the game is closed
+func (s Storage) upsertGame(tx *sqlx.Tx, game models.Game, block models.Block, totalAmount models.Amount) error {
+	var status string
+	err := tx.Get(&status, "SELECT `status` FROM `games` WHERE `coin` = ? AND `game_of` = ? FOR UPDATE", s.coin, game.GameOf)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("get game status error: %#v", err)
+	}
+
+	if err == nil && status != models.GameStatusPending {
+		return jerrors.ErrGameClosed
+	}
+
+	query := "INSERT INTO `games` (`coin`, `hash`, `height`, `start_height`, `end_height`, `total_amount`, `game_of`) VALUES (:coin, :hash, :height, :start_height, :end_height, :total_amount, :game_of) ON DUPLICATE KEY UPDATE `hash` = :hash, `height` = :height, `end_height` = :end_height, `total_amount` = `total_amount` + :total_amount"
+	_, err = tx.NamedExec(query, map[string]interface{}{
+		"coin":         s.coin,
+		"hash":         block.Hash,
 		"total_amount": totalAmount,
-		"height":       height,
-		"game_of":      gameOf,
+		"height":       block.Height,
+		"start_height": game.StartHeight,
+		"end_height":   game.EndHeight,
+		"game_of":      game.GameOf,
 	})
 	if err != nil {
 		return fmt.Errorf("upsert game error: %#v", err)
@@ -23,15 +39,84 @@ func upsertGame(tx *sqlx.Tx, gameOf time.Time, hash string, height int64, totalA
 	return nil
 }
 
-func updateGameToDrawingNeededStatus(tx *sqlx.Tx, game *models.Game) error {
-	if game == nil {
-		return nil
+// closeGamesBefore moves every pending game before gameOf to drawing needed status,
+// the block is the first one after their windows and is used to draw all of them
+func (s Storage) closeGamesBefore(tx *sqlx.Tx, gameOf time.Time, block models.Block) error {
+	sql := "UPDATE `games` SET `hash` = ?, `height` = ?, `status` = ? WHERE `coin` = ? AND `game_of` < ? AND `status` = ?"
+	_, err := tx.Exec(sql, block.Hash, block.Height, models.GameStatusDrawingNeeded, s.coin, gameOf, models.GameStatusPending)
+	if err != nil {
+		return fmt.Errorf("close games error: %#v", err)
+	}
+
+	return nil
+}
+
+// resetGamesDrawnOnOrphanedBlocks moves games drawn on orphaned blocks back to drawing needed status,
+// they keep the orphaned hash until the block at their height on the new main chain is saved.
+// Payouts of these games not yet funded are cancelled so that the games are drawn again,
+// funded ones may have reached the network and are held as permanently failed for operators to check
+func (s Storage) resetGamesDrawnOnOrphanedBlocks(tx *sqlx.Tx, hashes []string) error {
+	sql, args, err := sqlx.In(
+		"DELETE p FROM `payouts` p JOIN `games` g ON g.`coin` = p.`coin` AND g.`game_of` = p.`game_of` "+
+			"WHERE p.`coin` = ? AND g.`hash` IN (?) AND g.`status` = ? AND p.`status` IN (?) AND p.`raw_tx` = ''",
+		s.coin,
+		hashes,
+		models.GameStatusPaying,
+		[]string{models.PayoutStatusQueued, models.PayoutStatusFailedRetryable},
+	)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("cancel payouts of games drawn on orphaned blocks error: %#v", err)
+	}
+
+	sql, args, err = sqlx.In(
+		"UPDATE `payouts` p JOIN `games` g ON g.`coin` = p.`coin` AND g.`game_of` = p.`game_of` "+
+			"SET p.`status` = ?, p.`last_error` = ? "+
+			"WHERE p.`coin` = ? AND g.`hash` IN (?) AND g.`status` = ? AND p.`status` IN (?) AND p.`raw_tx` != ''",
+		models.PayoutStatusFailedPermanent,
+		"game drawn on orphaned block, check transaction in wallet before requeueing",
+		s.coin,
+		hashes,
+		models.GameStatusPaying,
+		[]string{models.PayoutStatusQueued, models.PayoutStatusFailedRetryable},
+	)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("hold payouts of games drawn on orphaned blocks error: %#v", err)
 	}
 
-	sql := "UPDATE `games` SET `hash` = ?, `height` = ?, `status` = ? WHERE `game_of` = ? AND `status` = ?"
-	_, err := tx.Exec(sql, game.Hash, game.Height, models.GameStatusDrawingNeeded, game.GameOf, models.GameStatusPending)
+	sql, args, err = sqlx.In(
+		"UPDATE `games` g LEFT JOIN `payouts` p ON p.`coin` = g.`coin` AND p.`game_of` = g.`game_of` "+
+			"SET g.`status` = ? WHERE g.`coin` = ? AND g.`hash` IN (?) AND g.`status` IN (?) AND p.`id` IS NULL",
+		models.GameStatusDrawingNeeded,
+		s.coin,
+		hashes,
+		[]string{models.GameStatusDrawingNeeded, models.GameStatusPaying},
+	)
 	if err != nil {
-		return fmt.Errorf("update game to drawing needed status error: %#v", err)
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("reset games drawn on orphaned blocks error: %#v", err)
+	}
+
+	return nil
+}
+
+// redrawGamesOnBlock moves games waiting on orphaned block at height of block onto block,
+// which replaces the orphaned one on the main chain
+func (s Storage) redrawGamesOnBlock(tx *sqlx.Tx, block models.Block) error {
+	sql := "UPDATE `games` g JOIN `blocks` b ON b.`coin` = g.`coin` AND b.`hash` = g.`hash` " +
+		"SET g.`hash` = ? WHERE g.`coin` = ? AND g.`height` = ? AND g.`status` = ? AND b.`orphaned` = 1"
+	if _, err := tx.Exec(sql, block.Hash, s.coin, block.Height, models.GameStatusDrawingNeeded); err != nil {
+		return fmt.Errorf("redraw games on block error: %#v", err)
 	}
 
 	return nil
@@ -40,28 +125,74 @@ func updateGameToDrawingNeededStatus(tx *sqlx.Tx, game *models.Game) error {
 // GetGames gets games order by game_of desc, limit n, offset n
 func (s Storage) GetGames(limit, offset int64) ([]models.Game, error) {
 	games := []models.Game{}
-	err := s.db.Select(&games, "SELECT * FROM `games` ORDER BY `game_of` DESC LIMIT ? OFFSET ?", limit, offset)
+	err := s.db.Select(&games, "SELECT * FROM `games` WHERE `coin` = ? ORDER BY `game_of` DESC LIMIT ? OFFSET ?", s.coin, limit, offset)
 	return games, err
 }
 
-// GetDrawingNeededGames gets all drawing games
+// GetDrawingNeededGames gets all drawing games except those waiting for the block replacing their orphaned one
 func (s Storage) GetDrawingNeededGames() ([]models.Game, error) {
 	games := []models.Game{}
-	err := s.db.Select(&games, "SELECT * FROM `games` WHERE `status` = ? ORDER BY `game_of` ASC", models.GameStatusDrawingNeeded)
+	sql := "SELECT * FROM `games` g WHERE g.`coin` = ? AND g.`status` = ? " +
+		"AND NOT EXISTS (SELECT 1 FROM `blocks` b WHERE b.`coin` = g.`coin` AND b.`hash` = g.`hash` AND b.`orphaned` = 1) " +
+		"ORDER BY g.`game_of` ASC"
+	err := s.db.Select(&games, sql, s.coin, models.GameStatusDrawingNeeded)
 	return games, err
 }
 
-// UpdateGameToEndedStatus updates game status to ended
+// UpdateGameToEndedStatus updates game status to ended with payout transaction id and network fee,
+// marks its payout as broadcast if there is one
 func (s Storage) UpdateGameToEndedStatus(game models.Game) error {
-	sql := "UPDATE `games` SET `address` = ?, `win_amount` = ?, `fee` = ?, `tx_id` = ?, `status` = ? WHERE `game_of` = ? AND `status` = ?"
-	result, err := s.db.Exec(sql, game.Address, game.WinAmount, game.Fee, game.TransactionID, models.GameStatusEnded, game.GameOf, models.GameStatusDrawingNeeded)
-	if err != nil {
-		return fmt.Errorf("update game to ended status error: %#v", err)
+	return s.withTx(func(tx *sqlx.Tx) error {
+		if err := s.updatePayoutToBroadcastStatus(tx, game); err != nil {
+			return err
+		}
+
+		sql, args, err := sqlx.In(
+			"UPDATE `games` SET `tx_id` = ?, `vout` = ?, `net_amount` = ?, `network_fee` = ?, `status` = ? WHERE `coin` = ? AND `game_of` = ? AND `status` IN (?)",
+			game.TransactionID,
+			game.Vout,
+			game.NetAmount,
+			game.NetworkFee,
+			models.GameStatusEnded,
+			s.coin,
+			game.GameOf,
+			[]string{models.GameStatusDrawingNeeded, models.GameStatusPaying},
+		)
+		if err != nil {
+			return fmt.Errorf("fail to build sql with in: %v", err)
+		}
+
+		result, err := tx.Exec(sql, args...)
+		if err != nil {
+			return fmt.Errorf("update game to ended status error: %#v", err)
+		}
+
+		if affect, _ := result.RowsAffected(); affect != 1 {
+			return fmt.Errorf("update game to ended status affected row not 1 but %v", affect)
+		}
+
+		return nil
+	})
+}
+
+// GetLatestClosedGame gets the latest game that no longer accepts deposits
+func (s Storage) GetLatestClosedGame() (models.Game, error) {
+	game := models.Game{}
+	err := s.db.Get(&game, "SELECT * FROM `games` WHERE `coin` = ? AND `status` != ? ORDER BY `game_of` DESC LIMIT 1", s.coin, models.GameStatusPending)
+	if err == sql.ErrNoRows {
+		return game, jerrors.ErrNotFound
 	}
 
-	if affect, _ := result.RowsAffected(); affect != 1 {
-		return fmt.Errorf("update game to ended status affected row not 1 but %v", affect)
+	return game, err
+}
+
+// GetGameByStartHeight gets game starting from block of height
+func (s Storage) GetGameByStartHeight(height int64) (models.Game, error) {
+	game := models.Game{}
+	err := s.db.Get(&game, "SELECT * FROM `games` WHERE `coin` = ? AND `start_height` = ? ORDER BY `game_of` DESC LIMIT 1", s.coin, height)
+	if err == sql.ErrNoRows {
+		return game, jerrors.ErrNotFound
 	}
 
-	return nil
+	return game, err
 }
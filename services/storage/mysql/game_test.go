@@ -6,6 +6,8 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
 )
 
 func TestUpsertGame(t *testing.T) {
@@ -14,7 +16,7 @@ func TestUpsertGame(t *testing.T) {
 
 		Convey("When upsert game", func() {
 			err := s.withTx(func(tx *sqlx.Tx) error {
-				return upsertGame(tx, "hash", 1, 0.2, time.Now().UTC())
+				return s.upsertGame(tx, models.Game{GameOf: time.Now().UTC()}, models.Block{Hash: "hash", Height: 1}, 20000000)
 			})
 
 			Convey("Error should be nil", func() {
@@ -29,7 +31,7 @@ func TestUpsertGame(t *testing.T) {
 		Convey("When upsert game with commited connection", func() {
 			err := s.withTx(func(tx *sqlx.Tx) error {
 				tx.Commit()
-				return upsertGame(tx, "hash", 1, 0.2, time.Now().UTC())
+				return s.upsertGame(tx, models.Game{GameOf: time.Now().UTC()}, models.Block{Hash: "hash", Height: 1}, 20000000)
 			})
 
 			Convey("Error should not be nil", func() {
@@ -37,4 +39,127 @@ func TestUpsertGame(t *testing.T) {
 			})
 		})
 	})
+
+	Convey("Given mysql storage with closed game", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.upsertGame(tx, models.Game{GameOf: gameOf}, models.Block{Hash: "hash1", Height: 1}, 20000000)
+			return s.closeGamesBefore(tx, gameOf.Add(time.Hour), models.Block{Hash: "hash2", Height: 2})
+		})
+
+		Convey("When upsert closed game", func() {
+			err := s.withTx(func(tx *sqlx.Tx) error {
+				return s.upsertGame(tx, models.Game{GameOf: gameOf}, models.Block{Hash: "hash3", Height: 3}, 20000000)
+			})
+			games, _ := s.GetDrawingNeededGames()
+
+			Convey("Game should stay untouched", func() {
+				So(err, ShouldEqual, jerrors.ErrGameClosed)
+				So(games[0].Hash, ShouldEqual, "hash2")
+				So(games[0].TotalAmount, ShouldEqual, models.Amount(20000000))
+			})
+		})
+	})
+}
+
+func TestCloseGamesBefore(t *testing.T) {
+	Convey("Given mysql storage with pending games separated by empty windows", t, func() {
+		s := prepareDatabaseForTesting()
+		now := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.upsertGame(tx, models.Game{GameOf: now.Add(-3 * time.Hour)}, models.Block{Hash: "hash1", Height: 1}, 20000000)
+			return s.upsertGame(tx, models.Game{GameOf: now.Add(-2 * time.Hour)}, models.Block{Hash: "hash2", Height: 2}, 20000000)
+		})
+
+		Convey("When close games before now", func() {
+			err := s.withTx(func(tx *sqlx.Tx) error {
+				return s.closeGamesBefore(tx, now, models.Block{Hash: "hash3", Height: 3})
+			})
+			games, _ := s.GetDrawingNeededGames()
+
+			Convey("Every previous game should be drawn on the same block", func() {
+				So(err, ShouldBeNil)
+				So(len(games), ShouldEqual, 2)
+				So(games[0].Hash, ShouldEqual, "hash3")
+				So(games[1].Hash, ShouldEqual, "hash3")
+			})
+		})
+	})
+}
+
+func TestGetGameByStartHeight(t *testing.T) {
+	Convey("Given mysql storage with game spanning fixed number of blocks", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Second)
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.upsertGame(tx, models.Game{GameOf: gameOf, StartHeight: 144, EndHeight: 287}, models.Block{Hash: "hash1", Height: 150}, 20000000)
+		})
+
+		Convey("When get game by start height", func() {
+			game, err := s.GetGameByStartHeight(144)
+
+			Convey("Game should record its start and end heights", func() {
+				So(err, ShouldBeNil)
+				So(game.GameOf, ShouldResemble, gameOf)
+				So(game.StartHeight, ShouldEqual, 144)
+				So(game.EndHeight, ShouldEqual, 287)
+			})
+		})
+
+		Convey("When get game by missing start height", func() {
+			_, err := s.GetGameByStartHeight(288)
+
+			Convey("Error should be not found", func() {
+				So(err, ShouldEqual, jerrors.ErrNotFound)
+			})
+		})
+	})
+
+	withClosedConn(t, "When get game by start height", func(s Storage) error {
+		_, err := s.GetGameByStartHeight(144)
+		return err
+	})
+}
+
+func TestGetLatestClosedGame(t *testing.T) {
+	Convey("Given mysql storage with pending games only", t, func() {
+		s := prepareDatabaseForTesting()
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.upsertGame(tx, models.Game{GameOf: time.Now().UTC().Truncate(time.Hour)}, models.Block{Hash: "hash1", Height: 1}, 20000000)
+		})
+
+		Convey("When get latest closed game", func() {
+			_, err := s.GetLatestClosedGame()
+
+			Convey("Error should be not found", func() {
+				So(err, ShouldEqual, jerrors.ErrNotFound)
+			})
+		})
+	})
+
+	Convey("Given mysql storage with closed games", t, func() {
+		s := prepareDatabaseForTesting()
+		now := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.upsertGame(tx, models.Game{GameOf: now.Add(-3 * time.Hour)}, models.Block{Hash: "hash1", Height: 1}, 20000000)
+			s.upsertGame(tx, models.Game{GameOf: now.Add(-2 * time.Hour)}, models.Block{Hash: "hash2", Height: 2}, 20000000)
+			s.upsertGame(tx, models.Game{GameOf: now}, models.Block{Hash: "hash3", Height: 3}, 20000000)
+			return s.closeGamesBefore(tx, now, models.Block{Hash: "hash3", Height: 3})
+		})
+
+		Convey("When get latest closed game", func() {
+			game, err := s.GetLatestClosedGame()
+
+			Convey("Game should be the latest one drawing needed", func() {
+				So(err, ShouldBeNil)
+				So(game.GameOf, ShouldResemble, now.Add(-2*time.Hour))
+			})
+		})
+	})
+
+	withClosedConn(t, "When get latest closed game", func(s Storage) error {
+		_, err := s.GetLatestClosedGame()
+		return err
+	})
 }
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+// ReplaceMempoolTransactions replaces stored mempool transactions with transactions,
+// those no longer in mempool are dropped as they are either mined or replaced
+func (s Storage) ReplaceMempoolTransactions(transactions []models.MempoolTransaction) error {
+	return s.withTx(func(tx *sqlx.Tx) error {
+		txids := make([]string, len(transactions))
+		for i, v := range transactions {
+			txids[i] = v.TransactionID
+		}
+
+		if len(txids) == 0 {
+			if _, err := tx.Exec("DELETE FROM `mempool_transactions` WHERE `coin` = ?", s.coin); err != nil {
+				return fmt.Errorf("delete mempool transactions error: %#v", err)
+			}
+			return nil
+		}
+
+		sql, args, err := sqlx.In("DELETE FROM `mempool_transactions` WHERE `coin` = ? AND `tx_id` NOT IN (?)", s.coin, txids)
+		if err != nil {
+			return fmt.Errorf("fail to build sql with in: %v", err)
+		}
+
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return fmt.Errorf("delete mempool transactions error: %#v", err)
+		}
+
+		// transaction mined in between listing mempool and now is never provisional again
+		stmt, _ := tx.PrepareNamed("INSERT INTO `mempool_transactions` (`coin`, `address`, `amount`, `tx_id`, `received_at`) SELECT :coin, :address, :amount, :tx_id, :received_at FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM `transactions` WHERE `coin` = :coin AND `tx_id` = :tx_id AND `orphaned` = 0) ON DUPLICATE KEY UPDATE `address` = VALUES(`address`), `amount` = VALUES(`amount`)")
+		defer stmt.Close()
+
+		for _, v := range transactions {
+			v.Coin = s.coin
+			if _, err := stmt.Exec(v); err != nil {
+				return fmt.Errorf("save mempool transactions error: %#v", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// deleteMempoolTransactions drops mempool transactions once they are mined
+func (s Storage) deleteMempoolTransactions(tx *sqlx.Tx, txids []string) error {
+	if len(txids) == 0 {
+		return nil
+	}
+
+	sql, args, err := sqlx.In("DELETE FROM `mempool_transactions` WHERE `coin` = ? AND `tx_id` IN (?)", s.coin, txids)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("delete mined mempool transactions error: %#v", err)
+	}
+
+	return nil
+}
+
+// GetMempoolTransactions gets all mempool transactions order by received_at desc
+func (s Storage) GetMempoolTransactions() ([]models.MempoolTransaction, error) {
+	transactions := []models.MempoolTransaction{}
+	err := s.db.Select(&transactions, "SELECT * FROM `mempool_transactions` WHERE `coin` = ? ORDER BY `received_at` DESC", s.coin)
+	return transactions, err
+}
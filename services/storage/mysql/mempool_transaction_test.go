@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestReplaceMempoolTransactions(t *testing.T) {
+	Convey("Given mysql storage with mempool transactions", t, func() {
+		s := prepareDatabaseForTesting()
+		s.ReplaceMempoolTransactions([]models.MempoolTransaction{
+			{Address: "addr1", Amount: 10, TransactionID: "id1", ReceivedAt: time.Now()},
+			{Address: "addr2", Amount: 10, TransactionID: "id2", ReceivedAt: time.Now()},
+		})
+
+		Convey("When replace mempool transactions", func() {
+			err := s.ReplaceMempoolTransactions([]models.MempoolTransaction{
+				{Address: "addr2", Amount: 20, TransactionID: "id2", ReceivedAt: time.Now()},
+			})
+			transactions, _ := s.GetMempoolTransactions()
+
+			Convey("Transactions no longer in mempool should be dropped", func() {
+				So(err, ShouldBeNil)
+				So(len(transactions), ShouldEqual, 1)
+				So(transactions[0].TransactionID, ShouldEqual, "id2")
+				So(transactions[0].Amount, ShouldEqual, models.Amount(20))
+			})
+		})
+
+		Convey("When save block with mined mempool transaction", func() {
+			s.SaveBlockAndTransactions(models.Game{GameOf: time.Now()}, models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()}, []models.Transaction{
+				{Address: "addr1", Amount: 10, TransactionID: "id1", Hash: "hash", BlockCreatedAt: time.Now()},
+			}, nil, nil)
+			transactions, _ := s.GetMempoolTransactions()
+
+			Convey("Mined transaction should be dropped", func() {
+				So(len(transactions), ShouldEqual, 1)
+				So(transactions[0].TransactionID, ShouldEqual, "id2")
+			})
+		})
+
+		Convey("When replace mempool transactions with empty mempool", func() {
+			err := s.ReplaceMempoolTransactions(nil)
+			transactions, _ := s.GetMempoolTransactions()
+
+			Convey("Every transaction should be dropped", func() {
+				So(err, ShouldBeNil)
+				So(transactions, ShouldBeEmpty)
+			})
+		})
+	})
+
+	withClosedConn(t, "When replace mempool transactions", func(s Storage) error {
+		return s.ReplaceMempoolTransactions(nil)
+	})
+
+	withClosedConn(t, "When get mempool transactions", func(s Storage) error {
+		_, err := s.GetMempoolTransactions()
+		return err
+	})
+}
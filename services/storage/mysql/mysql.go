@@ -1,17 +1,17 @@
 package mysql
 
 import (
-	"time"
-
 	_ "github.com/go-sql-driver/mysql" // is needed for mysql driver registeration
 	"github.com/jmoiron/sqlx"
 	"github.com/solefaucet/jackpot-server/models"
 	"github.com/solefaucet/jackpot-server/services/storage"
 )
 
-// Storage implements Storage interface for data storage
+// Storage implements Storage interface for data storage,
+// rows of every coin share tables and are namespaced by coin column
 type Storage struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	coin string
 }
 
 var _ storage.Storage = Storage{}
@@ -23,6 +23,12 @@ func New(dsn string) Storage {
 	}
 }
 
+// WithCoin returns a Storage sharing connections that reads and writes rows of coin only
+func (s Storage) WithCoin(coin string) Storage {
+	s.coin = coin
+	return s
+}
+
 // SetMaxOpenConns alias sql.DB.SetMaxOpenConns
 func (s *Storage) SetMaxOpenConns(n int) {
 	s.db.SetMaxOpenConns(n)
@@ -47,30 +53,55 @@ func (s Storage) withTx(f func(*sqlx.Tx) error) error {
 	return tx.Commit()
 }
 
-// SaveBlockAndTransactions save block and transactions
-func (s Storage) SaveBlockAndTransactions(gameOf time.Time, block models.Block, transactions []models.Transaction, game *models.Game) error {
+// SaveBlockAndTransactions save block, transactions, transactions excluded from games and refunds,
+// the block is counted in game which carries game of time and block heights of the game
+func (s Storage) SaveBlockAndTransactions(game models.Game, block models.Block, transactions []models.Transaction, excludedTransactions []models.ExcludedTransaction, refunds []models.Refund) error {
 	return s.withTx(func(tx *sqlx.Tx) error {
 		// save block
-		if err := saveBlock(tx, block); err != nil {
+		if err := s.saveBlock(tx, block); err != nil {
 			return err
 		}
 
-		// save transactions
-		if err := saveTransactions(tx, transactions); err != nil {
+		// games drawn on the block it replaces are drawn on it instead
+		if err := s.redrawGamesOnBlock(tx, block); err != nil {
 			return err
 		}
 
-		// update or insert game
-		totalAmount := 0.0
+		// save transactions, only those new to main chain count in game
+		totalAmount, err := s.saveTransactions(tx, transactions)
+		if err != nil {
+			return err
+		}
+
+		// save transactions left for operators
+		if err := s.saveExcludedTransactions(tx, excludedTransactions); err != nil {
+			return err
+		}
+
+		// save deposits or excess portions to send back
+		if err := s.saveRefunds(tx, refunds); err != nil {
+			return err
+		}
+
+		// mined transactions are no longer provisional
+		txids := make([]string, 0, len(transactions)+len(excludedTransactions))
 		for _, v := range transactions {
-			totalAmount += v.Amount
+			txids = append(txids, v.TransactionID)
+		}
+		for _, v := range excludedTransactions {
+			txids = append(txids, v.TransactionID)
 		}
-		if err := upsertGame(tx, gameOf, block.Hash, block.Height, totalAmount); err != nil {
+		if err := s.deleteMempoolTransactions(tx, txids); err != nil {
+			return err
+		}
+
+		// update or insert game
+		if err := s.upsertGame(tx, game, block, totalAmount); err != nil {
 			return err
 		}
 
-		// update previous game to processing status if needed
-		if err := updateGameToDrawingNeededStatus(tx, game); err != nil {
+		// close previous games whose windows ended before this block
+		if err := s.closeGamesBefore(tx, game.GameOf, block); err != nil {
 			return err
 		}
 
@@ -11,6 +11,7 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/jerrors"
 	"github.com/solefaucet/jackpot-server/models"
 )
 
@@ -85,6 +86,7 @@ func TestSaveBlockAndTransactions(t *testing.T) {
 
 		Convey("When save block and transactions", func() {
 			err := s.SaveBlockAndTransactions(
+				models.Game{GameOf: time.Now(), StartHeight: 1, EndHeight: 1},
 				models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()},
 				[]models.Transaction{
 					{
@@ -95,6 +97,28 @@ func TestSaveBlockAndTransactions(t *testing.T) {
 						BlockCreatedAt: time.Now(),
 					},
 				},
+				[]models.ExcludedTransaction{
+					{
+						Amount:         10,
+						TransactionID:  "id2",
+						Hash:           "hash",
+						Reason:         models.ExcludedReasonUnattributed,
+						Detail:         "coinbase input",
+						BlockCreatedAt: time.Now(),
+					},
+				},
+				[]models.Refund{
+					{
+						GameOf:               time.Now(),
+						IdempotencyKey:       "jackpot refund of id3:0",
+						Address:              "addr",
+						Amount:               10,
+						Reason:               models.RefundReasonBelowMinBet,
+						DepositTransactionID: "id3",
+						Hash:                 "hash",
+						Height:               1,
+					},
+				},
 			)
 
 			Convey("Error should be nil", func() {
@@ -103,3 +127,46 @@ func TestSaveBlockAndTransactions(t *testing.T) {
 		})
 	})
 }
+
+func TestWithCoin(t *testing.T) {
+	Convey("Given mysql storage with block and game of doge", t, func() {
+		s := prepareDatabaseForTesting()
+		doge, ltc := s.WithCoin("doge"), s.WithCoin("ltc")
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		doge.SaveBlockAndTransactions(
+			models.Game{GameOf: gameOf, StartHeight: 1, EndHeight: 1},
+			models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()},
+			[]models.Transaction{{Address: "addr", Amount: 10, TransactionID: "id", Hash: "hash", Height: 1, GameOf: gameOf, BlockCreatedAt: time.Now()}},
+			nil,
+			nil,
+		)
+
+		Convey("When save the same block and game of ltc", func() {
+			err := ltc.SaveBlockAndTransactions(
+				models.Game{GameOf: gameOf, StartHeight: 1, EndHeight: 1},
+				models.Block{Hash: "hash", Height: 1, BlockCreatedAt: time.Now()},
+				[]models.Transaction{{Address: "addr", Amount: 20, TransactionID: "id", Hash: "hash", Height: 1, GameOf: gameOf, BlockCreatedAt: time.Now()}},
+				nil,
+				nil,
+			)
+			dogeGames, _ := doge.GetGames(10, 0)
+			ltcGames, _ := ltc.GetGames(10, 0)
+
+			Convey("Rows of each coin should be kept apart", func() {
+				So(err, ShouldBeNil)
+				So(len(dogeGames), ShouldEqual, 1)
+				So(dogeGames[0].TotalAmount, ShouldEqual, models.Amount(10))
+				So(len(ltcGames), ShouldEqual, 1)
+				So(ltcGames[0].TotalAmount, ShouldEqual, models.Amount(20))
+			})
+		})
+
+		Convey("When get latest block of another coin", func() {
+			_, err := s.WithCoin("btc").GetLatestBlock()
+
+			Convey("Error should be not found", func() {
+				So(err, ShouldEqual, jerrors.ErrNotFound)
+			})
+		})
+	})
+}
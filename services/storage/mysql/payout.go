@@ -0,0 +1,226 @@
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+// maxLastErrorLength is length of payouts.last_error column, longer errors are truncated
+const maxLastErrorLength = 1024
+
+// payouts in these statuses have no transaction in wallet yet as far as storage knows
+var unsentPayoutStatuses = []string{
+	models.PayoutStatusQueued,
+	models.PayoutStatusBroadcasting,
+	models.PayoutStatusFailedRetryable,
+	models.PayoutStatusFailedPermanent,
+}
+
+func (s Storage) savePayout(tx *sqlx.Tx, payout models.Payout) error {
+	payout.Coin = s.coin
+	_, err := tx.NamedExec("INSERT INTO `payouts` (`coin`, `game_of`, `idempotency_key`, `address`, `amount`, `raw_tx`) VALUES (:coin, :game_of, :idempotency_key, :address, :amount, :raw_tx)", payout)
+	if err != nil {
+		return fmt.Errorf("save payout error: %#v", err)
+	}
+
+	return nil
+}
+
+// updatePayoutToBroadcastStatus records payout transaction of game, permanently failed payout
+// is left as it is unless operator requeues it
+func (s Storage) updatePayoutToBroadcastStatus(tx *sqlx.Tx, game models.Game) error {
+	sql, args, err := sqlx.In(
+		"UPDATE `payouts` SET `tx_id` = ?, `vout` = ?, `network_fee` = ?, `status` = ? WHERE `coin` = ? AND `game_of` = ? AND `status` IN (?)",
+		game.TransactionID,
+		game.Vout,
+		game.NetworkFee,
+		models.PayoutStatusBroadcast,
+		s.coin,
+		game.GameOf,
+		[]string{models.PayoutStatusQueued, models.PayoutStatusBroadcasting, models.PayoutStatusFailedRetryable, models.PayoutStatusFailedPermanent},
+	)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("update payout to broadcast status error: %#v", err)
+	}
+
+	return nil
+}
+
+// GetDuePayouts gets payouts to send at now, i.e. queued ones, ones left broadcasting by a crash
+// and failed ones whose backoff has passed
+func (s Storage) GetDuePayouts(now time.Time) ([]models.Payout, error) {
+	payouts := []models.Payout{}
+	err := s.db.Select(
+		&payouts,
+		"SELECT * FROM `payouts` WHERE `coin` = ? AND (`status` IN (?, ?) OR (`status` = ? AND `next_attempt_at` <= ?)) ORDER BY `game_of` ASC",
+		s.coin,
+		models.PayoutStatusQueued,
+		models.PayoutStatusBroadcasting,
+		models.PayoutStatusFailedRetryable,
+		now,
+	)
+	return payouts, err
+}
+
+// GetBroadcastPayouts gets payouts with transaction not confirmed yet
+func (s Storage) GetBroadcastPayouts() ([]models.Payout, error) {
+	payouts := []models.Payout{}
+	err := s.db.Select(&payouts, "SELECT * FROM `payouts` WHERE `coin` = ? AND `status` = ? ORDER BY `game_of` ASC", s.coin, models.PayoutStatusBroadcast)
+	return payouts, err
+}
+
+// GetUnsentPayouts gets payouts without transaction, including failed ones, it is the payout queue operators watch
+func (s Storage) GetUnsentPayouts() ([]models.Payout, error) {
+	sql, args, err := sqlx.In("SELECT * FROM `payouts` WHERE `coin` = ? AND `status` IN (?) ORDER BY `game_of` ASC", s.coin, unsentPayoutStatuses)
+	if err != nil {
+		return nil, fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	payouts := []models.Payout{}
+	err = s.db.Select(&payouts, sql, args...)
+	return payouts, err
+}
+
+// UpdatePayoutToBroadcastingStatus marks payout as being sent and counts the attempt
+func (s Storage) UpdatePayoutToBroadcastingStatus(id int64) error {
+	sql := "UPDATE `payouts` SET `status` = ?, `attempts` = `attempts` + 1 WHERE `coin` = ? AND `id` = ? AND `status` IN (?, ?, ?)"
+	result, err := s.db.Exec(sql, models.PayoutStatusBroadcasting, s.coin, id, models.PayoutStatusQueued, models.PayoutStatusBroadcasting, models.PayoutStatusFailedRetryable)
+	if err != nil {
+		return fmt.Errorf("update payout to broadcasting status error: %#v", err)
+	}
+
+	if affect, _ := result.RowsAffected(); affect != 1 {
+		return fmt.Errorf("update payout to broadcasting status affected row not 1 but %v", affect)
+	}
+
+	return nil
+}
+
+// UpdatePayoutsToBroadcastingStatus marks payouts as being sent in batch of batchKey and counts the attempt,
+// none is updated unless all of them are
+func (s Storage) UpdatePayoutsToBroadcastingStatus(ids []int64, batchKey string) error {
+	return s.withTx(func(tx *sqlx.Tx) error {
+		sql, args, err := sqlx.In(
+			"UPDATE `payouts` SET `status` = ?, `batch_key` = ?, `attempts` = `attempts` + 1 WHERE `coin` = ? AND `id` IN (?) AND `status` IN (?)",
+			models.PayoutStatusBroadcasting,
+			batchKey,
+			s.coin,
+			ids,
+			[]string{models.PayoutStatusQueued, models.PayoutStatusBroadcasting, models.PayoutStatusFailedRetryable},
+		)
+		if err != nil {
+			return fmt.Errorf("fail to build sql with in: %v", err)
+		}
+
+		result, err := tx.Exec(sql, args...)
+		if err != nil {
+			return fmt.Errorf("update payouts to broadcasting status error: %#v", err)
+		}
+
+		if affect, _ := result.RowsAffected(); affect != int64(len(ids)) {
+			return fmt.Errorf("update payouts to broadcasting status affected row not %v but %v", len(ids), affect)
+		}
+
+		return nil
+	})
+}
+
+// UpdatePayoutsTransaction records transaction funded for broadcasting payouts before it is broadcast,
+// amount is updated to the output paying winner, none is updated unless all of them are
+func (s Storage) UpdatePayoutsTransaction(payouts []models.Payout) error {
+	return s.withTx(func(tx *sqlx.Tx) error {
+		for _, payout := range payouts {
+			sql := "UPDATE `payouts` SET `tx_id` = ?, `vout` = ?, `amount` = ?, `network_fee` = ?, `raw_tx` = ? WHERE `coin` = ? AND `id` = ? AND `status` = ?"
+			result, err := tx.Exec(sql, payout.TransactionID, payout.Vout, payout.Amount, payout.NetworkFee, payout.RawTransaction, s.coin, payout.ID, models.PayoutStatusBroadcasting)
+			if err != nil {
+				return fmt.Errorf("update payout transaction error: %#v", err)
+			}
+
+			if affect, _ := result.RowsAffected(); affect != 1 {
+				return fmt.Errorf("update payout transaction affected row not 1 but %v", affect)
+			}
+		}
+
+		return nil
+	})
+}
+
+// UpdatePayoutToFailedStatus records failed attempt of broadcasting payout,
+// status is either models.PayoutStatusFailedRetryable or models.PayoutStatusFailedPermanent
+func (s Storage) UpdatePayoutToFailedStatus(id int64, status, lastError string, nextAttemptAt time.Time) error {
+	if len(lastError) > maxLastErrorLength {
+		lastError = lastError[:maxLastErrorLength]
+	}
+
+	sql := "UPDATE `payouts` SET `status` = ?, `last_error` = ?, `next_attempt_at` = ? WHERE `coin` = ? AND `id` = ? AND `status` = ?"
+	if _, err := s.db.Exec(sql, status, lastError, nextAttemptAt, s.coin, id, models.PayoutStatusBroadcasting); err != nil {
+		return fmt.Errorf("update payout to failed status error: %#v", err)
+	}
+
+	return nil
+}
+
+// UpdatePayoutToConfirmedStatus marks broadcast payout as confirmed
+func (s Storage) UpdatePayoutToConfirmedStatus(id int64) error {
+	sql := "UPDATE `payouts` SET `status` = ? WHERE `coin` = ? AND `id` = ? AND `status` = ?"
+	if _, err := s.db.Exec(sql, models.PayoutStatusConfirmed, s.coin, id, models.PayoutStatusBroadcast); err != nil {
+		return fmt.Errorf("update payout to confirmed status error: %#v", err)
+	}
+
+	return nil
+}
+
+// RequeuePayout queues failed payout to be sent right away, jerrors.ErrNotFound is returned if there is no such failed payout,
+// transaction of permanently failed payout can never be broadcast so it is dropped and payout of win amount is funded again
+func (s Storage) RequeuePayout(id int64) error {
+	return s.withTx(func(tx *sqlx.Tx) error {
+		sql := "UPDATE `payouts` p JOIN `games` g ON g.`coin` = p.`coin` AND g.`game_of` = p.`game_of` " +
+			"SET p.`tx_id` = '', p.`vout` = 0, p.`network_fee` = 0, p.`raw_tx` = '', p.`amount` = g.`win_amount` " +
+			"WHERE p.`coin` = ? AND p.`id` = ? AND p.`status` = ? AND p.`raw_tx` != ''"
+		if _, err := tx.Exec(sql, s.coin, id, models.PayoutStatusFailedPermanent); err != nil {
+			return fmt.Errorf("drop payout transaction error: %#v", err)
+		}
+
+		sql = "UPDATE `payouts` SET `status` = ?, `next_attempt_at` = CURRENT_TIMESTAMP WHERE `coin` = ? AND `id` = ? AND `status` IN (?, ?)"
+		result, err := tx.Exec(sql, models.PayoutStatusQueued, s.coin, id, models.PayoutStatusFailedRetryable, models.PayoutStatusFailedPermanent)
+		if err != nil {
+			return fmt.Errorf("requeue payout error: %#v", err)
+		}
+
+		if affect, _ := result.RowsAffected(); affect != 1 {
+			return jerrors.ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// SavePayoutAndUpdateGameToPayingStatus records payout and updates game status to paying,
+// it must succeed before the payout is sent
+func (s Storage) SavePayoutAndUpdateGameToPayingStatus(game models.Game, payout models.Payout) error {
+	return s.withTx(func(tx *sqlx.Tx) error {
+		if err := s.savePayout(tx, payout); err != nil {
+			return err
+		}
+
+		sql := "UPDATE `games` SET `address` = ?, `win_amount` = ?, `fee` = ?, `net_amount` = ?, `status` = ? WHERE `coin` = ? AND `game_of` = ? AND `status` = ?"
+		result, err := tx.Exec(sql, game.Address, game.WinAmount, game.Fee, game.NetAmount, models.GameStatusPaying, s.coin, game.GameOf, models.GameStatusDrawingNeeded)
+		if err != nil {
+			return fmt.Errorf("update game to paying status error: %#v", err)
+		}
+
+		if affect, _ := result.RowsAffected(); affect != 1 {
+			return fmt.Errorf("update game to paying status affected row not 1 but %v", affect)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,303 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestSavePayoutAndUpdateGameToPayingStatus(t *testing.T) {
+	Convey("Given mysql storage with drawing needed game", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.upsertGame(tx, models.Game{GameOf: gameOf}, models.Block{Hash: "hash1", Height: 1}, 100000000)
+			return s.closeGamesBefore(tx, gameOf.Add(time.Hour), models.Block{Hash: "hash2", Height: 2})
+		})
+		game := models.Game{Address: "addr", WinAmount: 90000000, Fee: 10000000, NetAmount: 89990000, GameOf: gameOf}
+		payout := models.Payout{GameOf: gameOf, IdempotencyKey: "key", Address: "addr", Amount: 89990000}
+
+		Convey("When save payout", func() {
+			err := s.SavePayoutAndUpdateGameToPayingStatus(game, payout)
+			payouts, _ := s.GetDuePayouts(time.Now())
+			games, _ := s.GetDrawingNeededGames()
+
+			Convey("Game should be paying with a queued payout", func() {
+				So(err, ShouldBeNil)
+				So(len(payouts), ShouldEqual, 1)
+				So(payouts[0].IdempotencyKey, ShouldEqual, "key")
+				So(payouts[0].Status, ShouldEqual, models.PayoutStatusQueued)
+				So(games, ShouldBeEmpty)
+			})
+
+			Convey("When save payout of the same game again", func() {
+				err := s.SavePayoutAndUpdateGameToPayingStatus(game, payout)
+
+				Convey("Error should not be nil", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("When update game to ended status", func() {
+				err := s.UpdateGameToEndedStatus(models.Game{TransactionID: "tx_id", Vout: 1, NetAmount: 89992000, NetworkFee: 8000, GameOf: gameOf})
+				payouts, _ := s.GetDuePayouts(time.Now())
+				broadcast, _ := s.GetBroadcastPayouts()
+				games, _ := s.GetGames(10, 0)
+
+				Convey("Payout should be broadcast", func() {
+					So(err, ShouldBeNil)
+					So(payouts, ShouldBeEmpty)
+					So(len(broadcast), ShouldEqual, 1)
+					So(broadcast[0].TransactionID, ShouldEqual, "tx_id")
+					So(broadcast[0].Vout, ShouldEqual, 1)
+				})
+
+				Convey("When update payout to confirmed status", func() {
+					err := s.UpdatePayoutToConfirmedStatus(broadcast[0].ID)
+					broadcast, _ := s.GetBroadcastPayouts()
+
+					Convey("Payout should no longer be broadcast", func() {
+						So(err, ShouldBeNil)
+						So(broadcast, ShouldBeEmpty)
+					})
+				})
+
+				Convey("Game should record amount sent and network fee", func() {
+					So(games[0].NetAmount, ShouldEqual, 89992000)
+					So(games[0].NetworkFee, ShouldEqual, 8000)
+				})
+			})
+		})
+	})
+
+	withClosedConn(t, "When save payout", func(s Storage) error {
+		return s.SavePayoutAndUpdateGameToPayingStatus(models.Game{}, models.Payout{})
+	})
+}
+
+func TestPayoutRetry(t *testing.T) {
+	Convey("Given mysql storage with a broadcasting payout", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.savePayout(tx, models.Payout{GameOf: gameOf, IdempotencyKey: "key", Address: "addr", Amount: 90000000})
+		})
+		payouts, _ := s.GetDuePayouts(time.Now())
+		id := payouts[0].ID
+		s.UpdatePayoutToBroadcastingStatus(id)
+
+		Convey("When payout fails with retryable error", func() {
+			nextAttemptAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+			err := s.UpdatePayoutToFailedStatus(id, models.PayoutStatusFailedRetryable, "insufficient funds", nextAttemptAt)
+			due, _ := s.GetDuePayouts(time.Now())
+			dueLater, _ := s.GetDuePayouts(nextAttemptAt)
+			unsent, _ := s.GetUnsentPayouts()
+
+			Convey("Payout should be due after backoff only", func() {
+				So(err, ShouldBeNil)
+				So(due, ShouldBeEmpty)
+				So(len(dueLater), ShouldEqual, 1)
+				So(dueLater[0].Attempts, ShouldEqual, 1)
+				So(dueLater[0].LastError, ShouldEqual, "insufficient funds")
+			})
+
+			Convey("Payout should be in the queue", func() {
+				So(len(unsent), ShouldEqual, 1)
+				So(unsent[0].Status, ShouldEqual, models.PayoutStatusFailedRetryable)
+			})
+		})
+
+		Convey("When payout fails with permanent error", func() {
+			err := s.UpdatePayoutToFailedStatus(id, models.PayoutStatusFailedPermanent, "invalid address", time.Now())
+			due, _ := s.GetDuePayouts(time.Now().Add(time.Hour))
+
+			Convey("Payout should never be due", func() {
+				So(err, ShouldBeNil)
+				So(due, ShouldBeEmpty)
+			})
+
+			Convey("When requeue payout", func() {
+				err := s.RequeuePayout(id)
+				due, _ := s.GetDuePayouts(time.Now())
+
+				Convey("Payout should be due again", func() {
+					So(err, ShouldBeNil)
+					So(len(due), ShouldEqual, 1)
+					So(due[0].Status, ShouldEqual, models.PayoutStatusQueued)
+				})
+			})
+		})
+
+		Convey("When requeue payout not failed", func() {
+			err := s.RequeuePayout(id)
+
+			Convey("Error should be not found", func() {
+				So(err, ShouldEqual, jerrors.ErrNotFound)
+			})
+		})
+	})
+
+	withClosedConn(t, "When update payout to broadcasting status", func(s Storage) error {
+		return s.UpdatePayoutToBroadcastingStatus(1)
+	})
+
+	withClosedConn(t, "When update payout to failed status", func(s Storage) error {
+		return s.UpdatePayoutToFailedStatus(1, models.PayoutStatusFailedRetryable, "", time.Now())
+	})
+
+	withClosedConn(t, "When update payout to confirmed status", func(s Storage) error {
+		return s.UpdatePayoutToConfirmedStatus(1)
+	})
+
+	withClosedConn(t, "When requeue payout", func(s Storage) error {
+		return s.RequeuePayout(1)
+	})
+}
+
+func TestUpdatePayoutsToBroadcastingStatus(t *testing.T) {
+	Convey("Given mysql storage with queued payouts", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.savePayout(tx, models.Payout{GameOf: gameOf, IdempotencyKey: "key1", Address: "addr1", Amount: 90000000})
+			return s.savePayout(tx, models.Payout{GameOf: gameOf.Add(time.Hour), IdempotencyKey: "key2", Address: "addr2", Amount: 90000000})
+		})
+		payouts, _ := s.GetDuePayouts(time.Now())
+		ids := []int64{payouts[0].ID, payouts[1].ID}
+
+		Convey("When update payouts to broadcasting status in a batch", func() {
+			err := s.UpdatePayoutsToBroadcastingStatus(ids, "batch")
+			payouts, _ := s.GetDuePayouts(time.Now())
+
+			Convey("Payouts should be broadcasting in the batch", func() {
+				So(err, ShouldBeNil)
+				So(len(payouts), ShouldEqual, 2)
+				for _, payout := range payouts {
+					So(payout.Status, ShouldEqual, models.PayoutStatusBroadcasting)
+					So(payout.BatchKey, ShouldEqual, "batch")
+					So(payout.Attempts, ShouldEqual, 1)
+				}
+			})
+		})
+
+		Convey("When update payouts to broadcasting status with unknown payout", func() {
+			err := s.UpdatePayoutsToBroadcastingStatus(append(ids, ids[1]+1), "batch")
+			payouts, _ := s.GetDuePayouts(time.Now())
+
+			Convey("No payout should be updated", func() {
+				So(err, ShouldNotBeNil)
+				So(payouts[0].Status, ShouldEqual, models.PayoutStatusQueued)
+				So(payouts[1].Status, ShouldEqual, models.PayoutStatusQueued)
+			})
+		})
+	})
+
+	withClosedConn(t, "When update payouts to broadcasting status", func(s Storage) error {
+		return s.UpdatePayoutsToBroadcastingStatus([]int64{1}, "batch")
+	})
+}
+
+func TestUpdatePayoutsTransaction(t *testing.T) {
+	Convey("Given mysql storage with a paying game and its queued payout", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.upsertGame(tx, models.Game{GameOf: gameOf}, models.Block{Hash: "hash1", Height: 1}, 100000000)
+			return s.closeGamesBefore(tx, gameOf.Add(time.Hour), models.Block{Hash: "hash2", Height: 2})
+		})
+		game := models.Game{Address: "addr", WinAmount: 90000000, Fee: 10000000, NetAmount: 89990000, GameOf: gameOf}
+		s.SavePayoutAndUpdateGameToPayingStatus(game, models.Payout{GameOf: gameOf, IdempotencyKey: "key", Address: "addr", Amount: 90000000})
+		payouts, _ := s.GetDuePayouts(time.Now())
+		payout := payouts[0]
+		payout.TransactionID, payout.Vout, payout.Amount, payout.NetworkFee, payout.RawTransaction = "tx_id", 1, 89992000, 8000, "raw_tx"
+
+		Convey("When update transaction of queued payout", func() {
+			err := s.UpdatePayoutsTransaction([]models.Payout{payout})
+			payouts, _ := s.GetDuePayouts(time.Now())
+
+			Convey("Payout should not be updated", func() {
+				So(err, ShouldNotBeNil)
+				So(payouts[0].RawTransaction, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When update transaction of broadcasting payout", func() {
+			s.UpdatePayoutToBroadcastingStatus(payout.ID)
+			err := s.UpdatePayoutsTransaction([]models.Payout{payout})
+			payouts, _ := s.GetDuePayouts(time.Now())
+
+			Convey("Payout should record the transaction", func() {
+				So(err, ShouldBeNil)
+				So(payouts[0].TransactionID, ShouldEqual, "tx_id")
+				So(payouts[0].Vout, ShouldEqual, 1)
+				So(payouts[0].Amount, ShouldEqual, 89992000)
+				So(payouts[0].NetworkFee, ShouldEqual, 8000)
+				So(payouts[0].RawTransaction, ShouldEqual, "raw_tx")
+			})
+
+			Convey("When payout fails with retryable error and is requeued", func() {
+				s.UpdatePayoutToFailedStatus(payout.ID, models.PayoutStatusFailedRetryable, "timeout", time.Now())
+				err := s.RequeuePayout(payout.ID)
+				payouts, _ := s.GetDuePayouts(time.Now())
+
+				Convey("Payout should keep the transaction", func() {
+					So(err, ShouldBeNil)
+					So(payouts[0].RawTransaction, ShouldEqual, "raw_tx")
+					So(payouts[0].Amount, ShouldEqual, 89992000)
+				})
+			})
+
+			Convey("When game of payout failed with permanent error ends as wallet knows its transaction", func() {
+				s.UpdatePayoutToFailedStatus(payout.ID, models.PayoutStatusFailedPermanent, "missing inputs", time.Now())
+				err := s.UpdateGameToEndedStatus(models.Game{TransactionID: "tx_id", Vout: 1, NetAmount: 89992000, NetworkFee: 8000, GameOf: gameOf})
+				broadcast, _ := s.GetBroadcastPayouts()
+
+				Convey("Payout should be broadcast", func() {
+					So(err, ShouldBeNil)
+					So(len(broadcast), ShouldEqual, 1)
+					So(broadcast[0].TransactionID, ShouldEqual, "tx_id")
+				})
+			})
+
+			Convey("When payout fails with permanent error and is requeued", func() {
+				s.UpdatePayoutToFailedStatus(payout.ID, models.PayoutStatusFailedPermanent, "rejected", time.Now())
+				err := s.RequeuePayout(payout.ID)
+				payouts, _ := s.GetDuePayouts(time.Now())
+
+				Convey("Payout of win amount should be funded again", func() {
+					So(err, ShouldBeNil)
+					So(payouts[0].Status, ShouldEqual, models.PayoutStatusQueued)
+					So(payouts[0].TransactionID, ShouldBeEmpty)
+					So(payouts[0].RawTransaction, ShouldBeEmpty)
+					So(payouts[0].NetworkFee, ShouldEqual, 0)
+					So(payouts[0].Amount, ShouldEqual, 90000000)
+				})
+			})
+		})
+	})
+
+	withClosedConn(t, "When update payouts transaction", func(s Storage) error {
+		return s.UpdatePayoutsTransaction([]models.Payout{{ID: 1}})
+	})
+}
+
+func TestGetPayouts(t *testing.T) {
+	withClosedConn(t, "When get due payouts", func(s Storage) error {
+		_, err := s.GetDuePayouts(time.Now())
+		return err
+	})
+
+	withClosedConn(t, "When get broadcast payouts", func(s Storage) error {
+		_, err := s.GetBroadcastPayouts()
+		return err
+	})
+
+	withClosedConn(t, "When get unsent payouts", func(s Storage) error {
+		_, err := s.GetUnsentPayouts()
+		return err
+	})
+}
@@ -0,0 +1,94 @@
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func (s Storage) saveRefunds(tx *sqlx.Tx, refunds []models.Refund) error {
+	if len(refunds) == 0 {
+		return nil
+	}
+
+	// refund of deposit mined again is reactivated, a sent or funded one is never changed or sent again
+	stmt, _ := tx.PrepareNamed("INSERT INTO `refunds` (`coin`, `game_of`, `idempotency_key`, `address`, `amount`, `reason`, `deposit_tx_id`, `deposit_vout`, `hash`, `height`, `raw_tx`) VALUES (:coin, :game_of, :idempotency_key, :address, :amount, :reason, :deposit_tx_id, :deposit_vout, :hash, :height, :raw_tx) ON DUPLICATE KEY UPDATE `amount` = IF(`status` = 'pending' AND `raw_tx` = '', VALUES(`amount`), `amount`), `reason` = IF(`status` = 'pending' AND `raw_tx` = '', VALUES(`reason`), `reason`), `game_of` = VALUES(`game_of`), `hash` = VALUES(`hash`), `height` = VALUES(`height`), `orphaned` = 0")
+	defer stmt.Close()
+
+	for _, v := range refunds {
+		v.Coin = s.coin
+		if _, err := stmt.Exec(v); err != nil {
+			return fmt.Errorf("save refunds error: %#v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s Storage) orphanRefunds(tx *sqlx.Tx, hashes []string) error {
+	sql, args, err := sqlx.In("UPDATE `refunds` SET `orphaned` = 1 WHERE `coin` = ? AND `hash` IN (?)", s.coin, hashes)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("orphan refunds error: %#v", err)
+	}
+
+	return nil
+}
+
+// GetPendingRefunds gets refunds not sent yet of deposits on main chain at or below height
+func (s Storage) GetPendingRefunds(height int64) ([]models.Refund, error) {
+	refunds := []models.Refund{}
+	err := s.db.Select(&refunds, "SELECT * FROM `refunds` WHERE `coin` = ? AND `status` = ? AND `orphaned` = 0 AND `height` <= ? ORDER BY `id` ASC", s.coin, models.RefundStatusPending, height)
+	return refunds, err
+}
+
+// GetRefundsByGameOfs gets refunds of deposits on main chain, filter by game_of
+func (s Storage) GetRefundsByGameOfs(gameOfs ...time.Time) ([]models.Refund, error) {
+	if len(gameOfs) <= 0 {
+		return nil, nil
+	}
+
+	sql, args, err := sqlx.In(
+		"SELECT * FROM `refunds` WHERE `coin` = ? AND `game_of` IN (?) AND `orphaned` = 0 ORDER BY `id` DESC",
+		s.coin,
+		gameOfs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	refunds := []models.Refund{}
+	err = s.db.Select(&refunds, sql, args...)
+	return refunds, err
+}
+
+// UpdateRefundTransaction records transaction funded for pending refund before it is broadcast,
+// empty transaction drops the recorded one
+func (s Storage) UpdateRefundTransaction(refund models.Refund) error {
+	sql := "UPDATE `refunds` SET `tx_id` = ?, `raw_tx` = ? WHERE `coin` = ? AND `id` = ? AND `status` = ?"
+	result, err := s.db.Exec(sql, refund.TransactionID, refund.RawTransaction, s.coin, refund.ID, models.RefundStatusPending)
+	if err != nil {
+		return fmt.Errorf("update refund transaction error: %#v", err)
+	}
+
+	if affect, _ := result.RowsAffected(); affect != 1 {
+		return fmt.Errorf("update refund transaction affected row not 1 but %v", affect)
+	}
+
+	return nil
+}
+
+// UpdateRefundToSentStatus records refund transaction id
+func (s Storage) UpdateRefundToSentStatus(id int64, transactionID string) error {
+	sql := "UPDATE `refunds` SET `tx_id` = ?, `status` = ? WHERE `coin` = ? AND `id` = ? AND `status` = ?"
+	if _, err := s.db.Exec(sql, transactionID, models.RefundStatusSent, s.coin, id, models.RefundStatusPending); err != nil {
+		return fmt.Errorf("update refund to sent status error: %#v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestRefunds(t *testing.T) {
+	Convey("Given mysql storage with refunds", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.saveRefunds(tx, []models.Refund{
+				{GameOf: gameOf, IdempotencyKey: "key1", Address: "addr", Amount: 10, Reason: models.RefundReasonBelowMinBet, DepositTransactionID: "id1", Hash: "hash1", Height: 1},
+				{GameOf: gameOf, IdempotencyKey: "key2", Address: "addr", Amount: 10, Reason: models.RefundReasonAboveMaxBet, DepositTransactionID: "id2", Hash: "hash2", Height: 2},
+				{GameOf: gameOf, IdempotencyKey: "key3", Address: "addr", Amount: 10, Reason: models.RefundReasonAboveMaxBet, DepositTransactionID: "id3", Hash: "hash3", Height: 3},
+			})
+		})
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.orphanRefunds(tx, []string{"hash2"})
+		})
+
+		Convey("When get pending refunds at height 2", func() {
+			refunds, err := s.GetPendingRefunds(2)
+
+			Convey("Only confirmed refunds on main chain should be returned", func() {
+				So(err, ShouldBeNil)
+				So(len(refunds), ShouldEqual, 1)
+				So(refunds[0].IdempotencyKey, ShouldEqual, "key1")
+			})
+		})
+
+		Convey("When update refund transaction", func() {
+			refunds, _ := s.GetPendingRefunds(3)
+			refund := refunds[0]
+			refund.TransactionID, refund.RawTransaction = "refund_tx_id", "raw_tx"
+			err := s.UpdateRefundTransaction(refund)
+			s.withTx(func(tx *sqlx.Tx) error {
+				return s.saveRefunds(tx, []models.Refund{
+					{GameOf: gameOf, IdempotencyKey: "key1", Address: "addr", Amount: 20, Reason: models.RefundReasonAboveMaxBet, DepositTransactionID: "id1", Hash: "hash4", Height: 1},
+				})
+			})
+			pending, _ := s.GetPendingRefunds(3)
+
+			Convey("Refund should stay pending with its transaction, which deposit mined again never changes", func() {
+				So(err, ShouldBeNil)
+				So(pending[0].TransactionID, ShouldEqual, "refund_tx_id")
+				So(pending[0].RawTransaction, ShouldEqual, "raw_tx")
+				So(pending[0].Amount, ShouldEqual, 10)
+				So(pending[0].Reason, ShouldEqual, models.RefundReasonBelowMinBet)
+			})
+
+			Convey("When update transaction of refund sent", func() {
+				s.UpdateRefundToSentStatus(refund.ID, refund.TransactionID)
+				err := s.UpdateRefundTransaction(refund)
+
+				Convey("Error should not be nil", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey("When update refund to sent status", func() {
+			refunds, _ := s.GetPendingRefunds(3)
+			err := s.UpdateRefundToSentStatus(refunds[0].ID, "refund_tx_id")
+			pending, _ := s.GetPendingRefunds(3)
+			all, _ := s.GetRefundsByGameOfs(gameOf)
+
+			Convey("Refund should no longer be pending", func() {
+				So(err, ShouldBeNil)
+				So(len(pending), ShouldEqual, 1)
+				So(len(all), ShouldEqual, 2)
+			})
+		})
+	})
+
+	withClosedConn(t, "When get pending refunds", func(s Storage) error {
+		_, err := s.GetPendingRefunds(1)
+		return err
+	})
+
+	withClosedConn(t, "When get refunds by game ofs", func(s Storage) error {
+		_, err := s.GetRefundsByGameOfs(time.Now())
+		return err
+	})
+
+	withClosedConn(t, "When update refund transaction", func(s Storage) error {
+		return s.UpdateRefundTransaction(models.Refund{ID: 1})
+	})
+
+	withClosedConn(t, "When update refund to sent status", func(s Storage) error {
+		return s.UpdateRefundToSentStatus(1, "")
+	})
+}
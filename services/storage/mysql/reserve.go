@@ -0,0 +1,36 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+// GetLiabilities sums deposits of games not drawn yet, payouts not sent yet and pending refunds on main chain,
+// payout broadcasting with a transaction may have left wallet already so it is not counted, failed one is counted as it has not
+func (s Storage) GetLiabilities() (models.Liabilities, error) {
+	liabilities := models.Liabilities{}
+	sql, args, err := sqlx.In(
+		"SELECT "+
+			"(SELECT SUM(`total_amount`) FROM `games` WHERE `coin` = ? AND `status` IN (?)) AS `outstanding_pots`, "+
+			"(SELECT SUM(`amount`) FROM `payouts` WHERE `coin` = ? AND `status` IN (?) AND NOT (`status` = ? AND `tx_id` != '')) AS `unsent_payouts`, "+
+			"(SELECT SUM(`amount`) FROM `refunds` WHERE `coin` = ? AND `status` = ? AND `orphaned` = 0) AS `pending_refunds`",
+		s.coin,
+		[]string{models.GameStatusPending, models.GameStatusDrawingNeeded},
+		s.coin,
+		unsentPayoutStatuses,
+		models.PayoutStatusBroadcasting,
+		s.coin,
+		models.RefundStatusPending,
+	)
+	if err != nil {
+		return liabilities, fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if err := s.db.Get(&liabilities, sql, args...); err != nil {
+		return liabilities, fmt.Errorf("get liabilities error: %#v", err)
+	}
+
+	return liabilities, nil
+}
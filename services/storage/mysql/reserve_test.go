@@ -0,0 +1,94 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestGetLiabilities(t *testing.T) {
+	Convey("Given empty mysql storage", t, func() {
+		s := prepareDatabaseForTesting()
+
+		Convey("When get liabilities", func() {
+			liabilities, err := s.GetLiabilities()
+
+			Convey("Nothing should be owed", func() {
+				So(err, ShouldBeNil)
+				So(liabilities, ShouldResemble, models.Liabilities{})
+			})
+		})
+	})
+
+	Convey("Given mysql storage with games, payouts and refunds", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.upsertGame(tx, models.Game{GameOf: gameOf.Add(-2 * time.Hour)}, models.Block{Hash: "hash1", Height: 1}, 100000000)
+			s.upsertGame(tx, models.Game{GameOf: gameOf.Add(-time.Hour)}, models.Block{Hash: "hash2", Height: 2}, 20000000)
+			s.upsertGame(tx, models.Game{GameOf: gameOf}, models.Block{Hash: "hash3", Height: 3}, 3000000)
+			s.closeGamesBefore(tx, gameOf, models.Block{Hash: "hash3", Height: 3})
+			return s.saveRefunds(tx, []models.Refund{
+				{GameOf: gameOf, IdempotencyKey: "key1", Address: "addr", Amount: 400000, Reason: models.RefundReasonBelowMinBet, DepositTransactionID: "id1", Hash: "hash1", Height: 1},
+				{GameOf: gameOf, IdempotencyKey: "key2", Address: "addr", Amount: 50000, Reason: models.RefundReasonBelowMinBet, DepositTransactionID: "id2", Hash: "hash2", Height: 2},
+			})
+		})
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.orphanRefunds(tx, []string{"hash2"})
+		})
+		s.SavePayoutAndUpdateGameToPayingStatus(
+			models.Game{Address: "addr", WinAmount: 90000000, Fee: 10000000, NetAmount: 90000000, GameOf: gameOf.Add(-2 * time.Hour)},
+			models.Payout{GameOf: gameOf.Add(-2 * time.Hour), IdempotencyKey: "key", Address: "addr", Amount: 90000000},
+		)
+
+		Convey("When get liabilities", func() {
+			liabilities, err := s.GetLiabilities()
+
+			Convey("Pots of games not drawn, unsent payouts and pending refunds on main chain should be owed", func() {
+				So(err, ShouldBeNil)
+				So(liabilities.OutstandingPots, ShouldEqual, 23000000)
+				So(liabilities.UnsentPayouts, ShouldEqual, 90000000)
+				So(liabilities.PendingRefunds, ShouldEqual, 400000)
+			})
+		})
+
+		Convey("When payout is broadcasting with its transaction", func() {
+			payouts, _ := s.GetDuePayouts(time.Now())
+			payout := payouts[0]
+			payout.TransactionID, payout.RawTransaction = "tx_id", "raw_tx"
+			s.UpdatePayoutToBroadcastingStatus(payout.ID)
+			s.UpdatePayoutsTransaction([]models.Payout{payout})
+			liabilities, _ := s.GetLiabilities()
+
+			Convey("Payout should no longer be owed", func() {
+				So(liabilities.UnsentPayouts, ShouldEqual, 0)
+			})
+
+			Convey("When payout fails", func() {
+				s.UpdatePayoutToFailedStatus(payout.ID, models.PayoutStatusFailedRetryable, "timeout", time.Now())
+				liabilities, _ := s.GetLiabilities()
+
+				Convey("Payout should be owed again", func() {
+					So(liabilities.UnsentPayouts, ShouldEqual, 90000000)
+				})
+			})
+		})
+
+		Convey("When payout is broadcast", func() {
+			s.UpdateGameToEndedStatus(models.Game{TransactionID: "tx_id", GameOf: gameOf.Add(-2 * time.Hour)})
+			liabilities, _ := s.GetLiabilities()
+
+			Convey("Payout should no longer be owed", func() {
+				So(liabilities.UnsentPayouts, ShouldEqual, 0)
+			})
+		})
+	})
+
+	withClosedConn(t, "When get liabilities", func(s Storage) error {
+		_, err := s.GetLiabilities()
+		return err
+	})
+}
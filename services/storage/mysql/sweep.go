@@ -0,0 +1,108 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+// GetUnsweptFees gets house fees of ended games less what sweeps not failed have taken,
+// house keeps what winner paid of network fee beyond the actual one and pays the rest,
+// games ended before net amount was recorded count fee only
+func (s Storage) GetUnsweptFees() (models.Amount, error) {
+	var fees models.Amount
+	err := s.db.Get(&fees,
+		"SELECT "+
+			"(SELECT COALESCE(SUM(`fee` + IF(`net_amount` > 0, `win_amount` - `net_amount` - `network_fee`, 0)), 0) FROM `games` WHERE `coin` = ? AND `status` = ?) - "+
+			"(SELECT COALESCE(SUM(`amount` + `network_fee`), 0) FROM `sweeps` WHERE `coin` = ? AND `status` != ?)",
+		s.coin,
+		models.GameStatusEnded,
+		s.coin,
+		models.SweepStatusFailed,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("get unswept fees error: %#v", err)
+	}
+
+	return fees, nil
+}
+
+// GetSweeps gets sweeps order by id desc, limit n, offset n
+func (s Storage) GetSweeps(limit, offset int64) ([]models.Sweep, error) {
+	sweeps := []models.Sweep{}
+	err := s.db.Select(&sweeps, "SELECT * FROM `sweeps` WHERE `coin` = ? ORDER BY `id` DESC LIMIT ? OFFSET ?", s.coin, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("get sweeps error: %#v", err)
+	}
+
+	return sweeps, nil
+}
+
+// GetPendingSweep gets the sweep not sent yet, there is at most one as a new sweep is saved only without pending one
+func (s Storage) GetPendingSweep() (models.Sweep, error) {
+	sweep := models.Sweep{}
+	err := s.db.Get(&sweep, "SELECT * FROM `sweeps` WHERE `coin` = ? AND `status` = ? ORDER BY `id` ASC LIMIT 1", s.coin, models.SweepStatusPending)
+	if err == sql.ErrNoRows {
+		return sweep, jerrors.ErrNotFound
+	}
+
+	return sweep, err
+}
+
+// SaveSweep records pending sweep, it must succeed before the sweep is sent
+func (s Storage) SaveSweep(sweep models.Sweep) error {
+	sweep.Coin = s.coin
+	_, err := s.db.NamedExec("INSERT INTO `sweeps` (`coin`, `idempotency_key`, `address`, `amount`, `raw_tx`) VALUES (:coin, :idempotency_key, :address, :amount, :raw_tx)", sweep)
+	if err != nil {
+		return fmt.Errorf("save sweep error: %#v", err)
+	}
+
+	return nil
+}
+
+// UpdateSweepTransaction records transaction funded for pending sweep before it is broadcast,
+// network fee is taken out of amount so that amount and network fee still sum to fees swept
+func (s Storage) UpdateSweepTransaction(sweep models.Sweep) error {
+	sql := "UPDATE `sweeps` SET `tx_id` = ?, `amount` = ?, `network_fee` = ?, `raw_tx` = ? WHERE `coin` = ? AND `id` = ? AND `status` = ?"
+	result, err := s.db.Exec(sql, sweep.TransactionID, sweep.Amount, sweep.NetworkFee, sweep.RawTransaction, s.coin, sweep.ID, models.SweepStatusPending)
+	if err != nil {
+		return fmt.Errorf("update sweep transaction error: %#v", err)
+	}
+
+	if affect, _ := result.RowsAffected(); affect != 1 {
+		return fmt.Errorf("update sweep transaction affected row not 1 but %v", affect)
+	}
+
+	return nil
+}
+
+// UpdateSweepToSentStatus updates pending sweep to sent with transaction id and network fee
+func (s Storage) UpdateSweepToSentStatus(id int64, transactionID string, networkFee models.Amount) error {
+	sql := "UPDATE `sweeps` SET `tx_id` = ?, `network_fee` = ?, `status` = ? WHERE `coin` = ? AND `id` = ? AND `status` = ?"
+	result, err := s.db.Exec(sql, transactionID, networkFee, models.SweepStatusSent, s.coin, id, models.SweepStatusPending)
+	if err != nil {
+		return fmt.Errorf("update sweep to sent status error: %#v", err)
+	}
+
+	if affect, _ := result.RowsAffected(); affect != 1 {
+		return fmt.Errorf("update sweep to sent status affected row not 1 but %v", affect)
+	}
+
+	return nil
+}
+
+// UpdateSweepToFailedStatus gives up pending sweep that can never be sent, its amount is swept again by a new sweep
+func (s Storage) UpdateSweepToFailedStatus(id int64, lastError string) error {
+	if len(lastError) > maxLastErrorLength {
+		lastError = lastError[:maxLastErrorLength]
+	}
+
+	sql := "UPDATE `sweeps` SET `status` = ?, `last_error` = ? WHERE `coin` = ? AND `id` = ? AND `status` = ?"
+	if _, err := s.db.Exec(sql, models.SweepStatusFailed, lastError, s.coin, id, models.SweepStatusPending); err != nil {
+		return fmt.Errorf("update sweep to failed status error: %#v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestSweeps(t *testing.T) {
+	Convey("Given mysql storage with ended games", t, func() {
+		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			s.upsertGame(tx, models.Game{GameOf: gameOf.Add(-2 * time.Hour)}, models.Block{Hash: "hash1", Height: 1}, 100000000)
+			s.upsertGame(tx, models.Game{GameOf: gameOf.Add(-time.Hour)}, models.Block{Hash: "hash2", Height: 2}, 100000000)
+			return s.closeGamesBefore(tx, gameOf, models.Block{Hash: "hash3", Height: 3})
+		})
+		// house pays network fee of the first payout, winner pays more than network fee of the second
+		s.SavePayoutAndUpdateGameToPayingStatus(
+			models.Game{Address: "addr", WinAmount: 90000000, Fee: 10000000, NetAmount: 90000000, GameOf: gameOf.Add(-2 * time.Hour)},
+			models.Payout{GameOf: gameOf.Add(-2 * time.Hour), IdempotencyKey: "key1", Address: "addr", Amount: 90000000},
+		)
+		s.UpdateGameToEndedStatus(models.Game{TransactionID: "tx_id1", NetworkFee: 10000, GameOf: gameOf.Add(-2 * time.Hour)})
+		s.SavePayoutAndUpdateGameToPayingStatus(
+			models.Game{Address: "addr", WinAmount: 90000000, Fee: 10000000, NetAmount: 89980000, GameOf: gameOf.Add(-time.Hour)},
+			models.Payout{GameOf: gameOf.Add(-time.Hour), IdempotencyKey: "key2", Address: "addr", Amount: 89980000},
+		)
+		s.UpdateGameToEndedStatus(models.Game{TransactionID: "tx_id2", NetworkFee: 15000, GameOf: gameOf.Add(-time.Hour)})
+
+		Convey("When get unswept fees", func() {
+			fees, err := s.GetUnsweptFees()
+
+			Convey("House fees less network fees house pays should be unswept", func() {
+				So(err, ShouldBeNil)
+				So(fees, ShouldEqual, 19995000)
+			})
+		})
+
+		Convey("When get pending sweep", func() {
+			_, err := s.GetPendingSweep()
+
+			Convey("Error should be not found", func() {
+				So(err, ShouldEqual, jerrors.ErrNotFound)
+			})
+		})
+
+		Convey("When save sweep", func() {
+			err := s.SaveSweep(models.Sweep{IdempotencyKey: "sweep", Address: "cold", Amount: 19000000})
+			sweep, _ := s.GetPendingSweep()
+			fees, _ := s.GetUnsweptFees()
+
+			Convey("Sweep should be pending and its amount swept", func() {
+				So(err, ShouldBeNil)
+				So(sweep.IdempotencyKey, ShouldEqual, "sweep")
+				So(sweep.Status, ShouldEqual, models.SweepStatusPending)
+				So(fees, ShouldEqual, 995000)
+			})
+
+			Convey("When save sweep of the same key again", func() {
+				err := s.SaveSweep(models.Sweep{IdempotencyKey: "sweep", Address: "cold", Amount: 19000000})
+
+				Convey("Error should not be nil", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("When update sweep to sent status", func() {
+				err := s.UpdateSweepToSentStatus(sweep.ID, "sweep_tx_id", 5000)
+				_, pendingErr := s.GetPendingSweep()
+				sweeps, _ := s.GetSweeps(10, 0)
+				fees, _ := s.GetUnsweptFees()
+
+				Convey("Sweep should be sent with network fee paid out of fees", func() {
+					So(err, ShouldBeNil)
+					So(pendingErr, ShouldEqual, jerrors.ErrNotFound)
+					So(sweeps[0].TransactionID, ShouldEqual, "sweep_tx_id")
+					So(sweeps[0].Status, ShouldEqual, models.SweepStatusSent)
+					So(fees, ShouldEqual, 990000)
+				})
+
+				Convey("When update sweep to sent status again", func() {
+					err := s.UpdateSweepToSentStatus(sweep.ID, "sweep_tx_id", 5000)
+
+					Convey("Error should not be nil", func() {
+						So(err, ShouldNotBeNil)
+					})
+				})
+			})
+
+			Convey("When update sweep transaction", func() {
+				sweep.TransactionID, sweep.Amount, sweep.NetworkFee, sweep.RawTransaction = "sweep_tx_id", 18995000, 5000, "raw_tx"
+				err := s.UpdateSweepTransaction(sweep)
+				pending, _ := s.GetPendingSweep()
+				fees, _ := s.GetUnsweptFees()
+
+				Convey("Sweep should stay pending with network fee taken out of amount", func() {
+					So(err, ShouldBeNil)
+					So(pending.TransactionID, ShouldEqual, "sweep_tx_id")
+					So(pending.Amount, ShouldEqual, 18995000)
+					So(pending.NetworkFee, ShouldEqual, 5000)
+					So(pending.RawTransaction, ShouldEqual, "raw_tx")
+					So(fees, ShouldEqual, 995000)
+				})
+
+				Convey("When update sweep to sent status with network fee of its transaction", func() {
+					err := s.UpdateSweepToSentStatus(sweep.ID, sweep.TransactionID, sweep.NetworkFee)
+					fees, _ := s.GetUnsweptFees()
+
+					Convey("Fees swept should not change", func() {
+						So(err, ShouldBeNil)
+						So(fees, ShouldEqual, 995000)
+					})
+				})
+			})
+
+			Convey("When update transaction of sweep not pending", func() {
+				s.UpdateSweepToFailedStatus(sweep.ID, "invalid address")
+				err := s.UpdateSweepTransaction(sweep)
+
+				Convey("Error should not be nil", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("When update sweep to failed status", func() {
+				err := s.UpdateSweepToFailedStatus(sweep.ID, "invalid address")
+				sweeps, _ := s.GetSweeps(10, 0)
+				fees, _ := s.GetUnsweptFees()
+
+				Convey("Sweep amount should be unswept again", func() {
+					So(err, ShouldBeNil)
+					So(sweeps[0].Status, ShouldEqual, models.SweepStatusFailed)
+					So(sweeps[0].LastError, ShouldEqual, "invalid address")
+					So(fees, ShouldEqual, 19995000)
+				})
+			})
+		})
+	})
+
+	withClosedConn(t, "When get unswept fees", func(s Storage) error {
+		_, err := s.GetUnsweptFees()
+		return err
+	})
+
+	withClosedConn(t, "When get sweeps", func(s Storage) error {
+		_, err := s.GetSweeps(10, 0)
+		return err
+	})
+
+	withClosedConn(t, "When get pending sweep", func(s Storage) error {
+		_, err := s.GetPendingSweep()
+		return err
+	})
+
+	withClosedConn(t, "When save sweep", func(s Storage) error {
+		return s.SaveSweep(models.Sweep{})
+	})
+
+	withClosedConn(t, "When update sweep transaction", func(s Storage) error {
+		return s.UpdateSweepTransaction(models.Sweep{ID: 1})
+	})
+
+	withClosedConn(t, "When update sweep to sent status", func(s Storage) error {
+		return s.UpdateSweepToSentStatus(1, "tx_id", 0)
+	})
+
+	withClosedConn(t, "When update sweep to failed status", func(s Storage) error {
+		return s.UpdateSweepToFailedStatus(1, "")
+	})
+}
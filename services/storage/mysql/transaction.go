@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -8,14 +9,94 @@ import (
 	"github.com/solefaucet/jackpot-server/models"
 )
 
-func saveTransactions(tx *sqlx.Tx, transactions []models.Transaction) error {
-	stmt, _ := tx.PrepareNamed("INSERT INTO `transactions` (`address`, `amount`, `tx_id`, `hash`, `block_created_at`, `game_of`, `confirmations`) VALUES (:address, :amount, :tx_id, :hash, :block_created_at, :game_of, :confirmations)")
+// saveTransactions saves outputs identified by (tx_id, vout) idempotently, orphaned outputs mined again are reactivated,
+// it returns total amount of outputs new to main chain so that each output is counted in game exactly once,
+// orphaned outputs still counted in the game they were drawn or paid in stay there instead of joining another game
+func (s Storage) saveTransactions(tx *sqlx.Tx, transactions []models.Transaction) (models.Amount, error) {
+	stmt, _ := tx.PrepareNamed("INSERT INTO `transactions` (`coin`, `address`, `amount`, `tx_id`, `vout`, `output_address`, `hash`, `height`, `block_created_at`, `game_of`, `confirmations`) VALUES (:coin, :address, :amount, :tx_id, :vout, :output_address, :hash, :height, :block_created_at, :game_of, :confirmations) ON DUPLICATE KEY UPDATE `address` = VALUES(`address`), `amount` = VALUES(`amount`), `output_address` = VALUES(`output_address`), `hash` = VALUES(`hash`), `height` = VALUES(`height`), `block_created_at` = VALUES(`block_created_at`), `game_of` = VALUES(`game_of`), `confirmations` = VALUES(`confirmations`), `orphaned` = 0")
 	defer stmt.Close()
 
+	totalAmount := models.Amount(0)
 	for _, v := range transactions {
+		v.Coin = s.coin
+		saved := struct {
+			ID         int64     `db:"id"`
+			LegacyVout bool      `db:"legacy_vout"`
+			Orphaned   bool      `db:"orphaned"`
+			GameOf     time.Time `db:"game_of"`
+			GameStatus string    `db:"game_status"`
+		}{}
+		// rows stored before outputs were tracked have synthetic vouts and are matched by address and amount instead
+		err := tx.Get(
+			&saved,
+			"SELECT t.`id`, t.`legacy_vout`, t.`orphaned`, t.`game_of`, COALESCE(g.`status`, '') AS `game_status` FROM `transactions` t LEFT JOIN `games` g ON g.`coin` = t.`coin` AND g.`game_of` = t.`game_of` "+
+				"WHERE t.`coin` = ? AND t.`tx_id` = ? AND ((t.`vout` = ? AND t.`legacy_vout` = 0) OR (t.`legacy_vout` = 1 AND t.`address` = ? AND t.`amount` = ?)) "+
+				"ORDER BY t.`legacy_vout` ASC, t.`id` ASC LIMIT 1 FOR UPDATE",
+			s.coin, v.TransactionID, v.Vout, v.Address, v.Amount,
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, fmt.Errorf("get transaction error: %#v", err)
+		}
+
+		// legacy row takes vout of the output it stands for, synthetic vouts never take vouts of real outputs
+		if err == nil && saved.LegacyVout {
+			if _, err := tx.Exec(
+				"UPDATE `transactions` SET `vout` = ?, `output_address` = ?, `legacy_vout` = 0 WHERE `id` = ?",
+				v.Vout, v.OutputAddress, saved.ID,
+			); err != nil {
+				return 0, fmt.Errorf("update legacy transaction vout error: %#v", err)
+			}
+		}
+
+		// output is on main chain already
+		if err == nil && !saved.Orphaned {
+			continue
+		}
+
+		// orphaning does not reverse amount of games drawn already, the output is still counted in its game
+		if err == nil && (saved.GameStatus == models.GameStatusPaying || saved.GameStatus == models.GameStatusEnded) {
+			if _, err := tx.Exec(
+				"UPDATE `transactions` SET `hash` = ?, `height` = ?, `block_created_at` = ?, `confirmations` = ?, `orphaned` = 0 WHERE `coin` = ? AND `tx_id` = ? AND `vout` = ?",
+				v.Hash, v.Height, v.BlockCreatedAt, v.Confirmations, s.coin, v.TransactionID, v.Vout,
+			); err != nil {
+				return 0, fmt.Errorf("reactivate transaction error: %#v", err)
+			}
+			continue
+		}
+
 		if _, err := stmt.Exec(v); err != nil {
-			return fmt.Errorf("save transactions error: %#v", err)
+			return 0, fmt.Errorf("save transactions error: %#v", err)
 		}
+		totalAmount += v.Amount
+	}
+
+	return totalAmount, nil
+}
+
+func (s Storage) orphanTransactions(tx *sqlx.Tx, hashes []string) error {
+	// reverse contributions to games that are not paid out yet
+	sql, args, err := sqlx.In(
+		"UPDATE `games` g JOIN (SELECT `game_of`, SUM(`amount`) AS `amount` FROM `transactions` WHERE `coin` = ? AND `hash` IN (?) AND `orphaned` = 0 GROUP BY `game_of`) t ON g.`game_of` = t.`game_of` SET g.`total_amount` = g.`total_amount` - t.`amount` WHERE g.`coin` = ? AND g.`status` IN (?)",
+		s.coin,
+		hashes,
+		s.coin,
+		[]string{models.GameStatusPending, models.GameStatusDrawingNeeded},
+	)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("reverse orphaned transactions amount error: %#v", err)
+	}
+
+	sql, args, err = sqlx.In("UPDATE `transactions` SET `orphaned` = 1 WHERE `coin` = ? AND `hash` IN (?)", s.coin, hashes)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return fmt.Errorf("orphan transactions error: %#v", err)
 	}
 
 	return nil
@@ -24,7 +105,7 @@ func saveTransactions(tx *sqlx.Tx, transactions []models.Transaction) error {
 // GetUnconfirmedTransactions gets all unconfirmed transactions
 func (s Storage) GetUnconfirmedTransactions(confirmations int64) ([]models.Transaction, error) {
 	transactions := []models.Transaction{}
-	err := s.db.Select(&transactions, "SELECT * FROM `transactions` WHERE `confirmations` < ? ORDER BY `block_created_at` DESC", confirmations)
+	err := s.db.Select(&transactions, "SELECT * FROM `transactions` WHERE `coin` = ? AND `confirmations` < ? AND `orphaned` = 0 ORDER BY `block_created_at` DESC", s.coin, confirmations)
 	return transactions, err
 }
 
@@ -35,7 +116,8 @@ func (s Storage) GetTransactionsByGameOfs(gameOfs ...time.Time) ([]models.Transa
 	}
 
 	sql, args, err := sqlx.In(
-		"SELECT * FROM `transactions` WHERE `game_of` IN (?) ORDER BY `block_created_at` DESC",
+		"SELECT * FROM `transactions` WHERE `coin` = ? AND `game_of` IN (?) AND `orphaned` = 0 ORDER BY `block_created_at` DESC",
+		s.coin,
 		gameOfs,
 	)
 	if err != nil {
@@ -47,9 +129,22 @@ func (s Storage) GetTransactionsByGameOfs(gameOfs ...time.Time) ([]models.Transa
 	return transactions, err
 }
 
-// UpdateTransactionConfirmationByID update confirmations by transaction id
-func (s Storage) UpdateTransactionConfirmationByID(id int64, confirmations int64) error {
-	sql := "UPDATE `transactions` SET `confirmations` = ? WHERE `id` = ?"
-	_, err := s.db.Exec(sql, confirmations, id)
+// UpdateConfirmationsByTipHeight derives confirmations from height of chain tip in a single update,
+// only transactions below minConfirmations or within the latest minConfirmations blocks can change,
+// transactions of excludedIDs are left untouched
+func (s Storage) UpdateConfirmationsByTipHeight(tipHeight, minConfirmations int64, excludedIDs ...int64) error {
+	query := "UPDATE `transactions` SET `confirmations` = ? - `height` + 1 WHERE `coin` = ? AND `orphaned` = 0 AND `height` <= ? AND (`confirmations` < ? OR `height` > ?)"
+	args := []interface{}{tipHeight, s.coin, tipHeight, minConfirmations, tipHeight - minConfirmations}
+	if len(excludedIDs) > 0 {
+		query += " AND `id` NOT IN (?)"
+		args = append(args, excludedIDs)
+	}
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return fmt.Errorf("fail to build sql with in: %v", err)
+	}
+
+	_, err = s.db.Exec(query, args...)
 	return err
 }
@@ -13,51 +13,162 @@ func TestSaveTransactions(t *testing.T) {
 	Convey("Given empty mysql storage", t, func() {
 		s := prepareDatabaseForTesting()
 
-		Convey("When save duplicated transactions", func() {
-			err := s.withTx(func(tx *sqlx.Tx) error {
-				return saveTransactions(tx, []models.Transaction{
-					{
-						Address:        "addr",
-						Amount:         10,
-						TransactionID:  "id",
-						Hash:           "hash",
-						BlockCreatedAt: time.Now(),
-					},
-					{
-						Address:        "addr",
-						Amount:         10,
-						TransactionID:  "id",
-						Hash:           "hash",
-						BlockCreatedAt: time.Now(),
-					},
+		Convey("When save duplicated outputs and another output of the same transaction", func() {
+			var totalAmount models.Amount
+			err := s.withTx(func(tx *sqlx.Tx) (err error) {
+				totalAmount, err = s.saveTransactions(tx, []models.Transaction{
+					{Address: "addr", Amount: 10, TransactionID: "id", Vout: 0, Hash: "hash", BlockCreatedAt: time.Now()},
+					{Address: "addr", Amount: 10, TransactionID: "id", Vout: 0, Hash: "hash", BlockCreatedAt: time.Now()},
+					{Address: "addr", Amount: 5, TransactionID: "id", Vout: 1, Hash: "hash", BlockCreatedAt: time.Now()},
 				})
+				return
 			})
 
-			Convey("Error should not be nil", func() {
-				So(err, ShouldNotBeNil)
+			Convey("Each output should be counted once", func() {
+				So(err, ShouldBeNil)
+				So(totalAmount, ShouldEqual, models.Amount(15))
 			})
 		})
 	})
 
-	Convey("Given empty mysql storage", t, func() {
+	Convey("Given mysql storage with orphaned transaction", t, func() {
+		s := prepareDatabaseForTesting()
+		s.withTx(func(tx *sqlx.Tx) error {
+			if _, err := s.saveTransactions(tx, []models.Transaction{
+				{Address: "addr", Amount: 10, TransactionID: "id", Hash: "hash1", BlockCreatedAt: time.Now()},
+			}); err != nil {
+				return err
+			}
+			return s.orphanTransactions(tx, []string{"hash1"})
+		})
+
+		Convey("When save the transaction mined again", func() {
+			var totalAmount models.Amount
+			err := s.withTx(func(tx *sqlx.Tx) (err error) {
+				totalAmount, err = s.saveTransactions(tx, []models.Transaction{
+					{Address: "addr", Amount: 10, TransactionID: "id", Hash: "hash2", BlockCreatedAt: time.Now()},
+				})
+				return
+			})
+			transactions, _ := s.GetUnconfirmedTransactions(1)
+
+			Convey("Transaction should be reactivated and counted", func() {
+				So(err, ShouldBeNil)
+				So(totalAmount, ShouldEqual, models.Amount(10))
+				So(len(transactions), ShouldEqual, 1)
+				So(transactions[0].Hash, ShouldEqual, "hash2")
+			})
+		})
+	})
+}
+
+func TestSaveTransactionsOfLegacyRows(t *testing.T) {
+	Convey("Given mysql storage with outputs stored before vouts were tracked", t, func() {
+		s := prepareDatabaseForTesting()
+		s.db.MustExec(
+			"INSERT INTO `transactions` (`address`, `amount`, `tx_id`, `vout`, `legacy_vout`, `hash`, `block_created_at`, `game_of`) VALUES (?, ?, ?, ?, 1, ?, ?, ?), (?, ?, ?, ?, 1, ?, ?, ?)",
+			"addr1", 10, "id", uint32(4294967295), "hash", time.Now(), time.Now(),
+			"addr2", 5, "id", uint32(4294967294), "hash", time.Now(), time.Now(),
+		)
+
+		Convey("When save the outputs seen again with real vouts", func() {
+			var totalAmount models.Amount
+			err := s.withTx(func(tx *sqlx.Tx) (err error) {
+				totalAmount, err = s.saveTransactions(tx, []models.Transaction{
+					{Address: "addr2", Amount: 5, TransactionID: "id", Vout: 0, Hash: "hash", BlockCreatedAt: time.Now()},
+					{Address: "addr1", Amount: 10, TransactionID: "id", Vout: 1, Hash: "hash", BlockCreatedAt: time.Now()},
+				})
+				return
+			})
+			transactions, _ := s.GetUnconfirmedTransactions(1)
+
+			Convey("Legacy rows should take the real vouts without being counted again", func() {
+				So(err, ShouldBeNil)
+				So(totalAmount, ShouldEqual, models.Amount(0))
+				So(len(transactions), ShouldEqual, 2)
+				for _, v := range transactions {
+					So(v.Vout, ShouldBeLessThan, 2)
+				}
+			})
+		})
+	})
+}
+
+func TestSaveTransactionsOfDrawnGame(t *testing.T) {
+	Convey("Given mysql storage with orphaned transaction counted in a paying game", t, func() {
 		s := prepareDatabaseForTesting()
+		gameOf := time.Now().UTC().Truncate(time.Hour)
+		s.withTx(func(tx *sqlx.Tx) error {
+			if _, err := s.saveTransactions(tx, []models.Transaction{
+				{Address: "addr", Amount: 10, TransactionID: "id", Hash: "hash1", GameOf: gameOf, BlockCreatedAt: time.Now()},
+			}); err != nil {
+				return err
+			}
+			s.upsertGame(tx, models.Game{GameOf: gameOf}, models.Block{Hash: "hash1", Height: 1}, 10)
+			return s.closeGamesBefore(tx, gameOf.Add(time.Hour), models.Block{Hash: "hash2", Height: 2})
+		})
+		s.SavePayoutAndUpdateGameToPayingStatus(
+			models.Game{Address: "addr", WinAmount: 9, Fee: 1, NetAmount: 9, GameOf: gameOf},
+			models.Payout{GameOf: gameOf, IdempotencyKey: "key", Address: "addr", Amount: 9},
+		)
+		s.withTx(func(tx *sqlx.Tx) error {
+			return s.orphanTransactions(tx, []string{"hash1"})
+		})
 
-		Convey("When save transactions", func() {
-			err := s.withTx(func(tx *sqlx.Tx) error {
-				return saveTransactions(tx, []models.Transaction{
-					{
-						Address:        "addr",
-						Amount:         10,
-						TransactionID:  "id",
-						Hash:           "hash",
-						BlockCreatedAt: time.Now(),
-					},
+		Convey("When save the transaction mined again in a later game", func() {
+			var totalAmount models.Amount
+			err := s.withTx(func(tx *sqlx.Tx) (err error) {
+				totalAmount, err = s.saveTransactions(tx, []models.Transaction{
+					{Address: "addr", Amount: 10, TransactionID: "id", Hash: "hash3", GameOf: gameOf.Add(time.Hour), BlockCreatedAt: time.Now()},
 				})
+				return
+			})
+			transactions, _ := s.GetTransactionsByGameOfs(gameOf, gameOf.Add(time.Hour))
+
+			Convey("Transaction should be reactivated in its drawn game without counting again", func() {
+				So(err, ShouldBeNil)
+				So(totalAmount, ShouldEqual, models.Amount(0))
+				So(len(transactions), ShouldEqual, 1)
+				So(transactions[0].Hash, ShouldEqual, "hash3")
+				So(transactions[0].GameOf.Equal(gameOf), ShouldBeTrue)
 			})
+		})
+	})
+}
+
+func TestUpdateConfirmationsByTipHeight(t *testing.T) {
+	Convey("Given mysql storage with transactions of different heights", t, func() {
+		s := prepareDatabaseForTesting()
+		s.withTx(func(tx *sqlx.Tx) error {
+			_, err := s.saveTransactions(tx, []models.Transaction{
+				{Address: "addr", Amount: 10, TransactionID: "id1", Hash: "hash1", Height: 1, Confirmations: 1, BlockCreatedAt: time.Now()},
+				{Address: "addr", Amount: 10, TransactionID: "id2", Hash: "hash2", Height: 2, Confirmations: 1, BlockCreatedAt: time.Now()},
+				{Address: "addr", Amount: 10, TransactionID: "id3", Hash: "hash3", Height: 3, Confirmations: 1, BlockCreatedAt: time.Now()},
+			})
+			return err
+		})
+		transactions, _ := s.GetUnconfirmedTransactions(2)
+		ids := map[string]int64{}
+		for _, v := range transactions {
+			ids[v.TransactionID] = v.ID
+		}
+
+		Convey("When update confirmations by tip height excluding conflicted transaction", func() {
+			err := s.UpdateConfirmationsByTipHeight(3, 3, ids["id2"])
+			transactions, _ := s.GetUnconfirmedTransactions(10)
+			confirmations := map[string]int64{}
+			for _, v := range transactions {
+				confirmations[v.TransactionID] = v.Confirmations
+			}
 
-			Convey("Error should be nil", func() {
+			Convey("Confirmations should be derived from tip height", func() {
 				So(err, ShouldBeNil)
+				So(confirmations, ShouldResemble, map[string]int64{"id1": 3, "id2": 1, "id3": 1})
 			})
 		})
 	})
+
+	withClosedConn(t, "When update confirmations by tip height", func(s Storage) error {
+		return s.UpdateConfirmationsByTipHeight(1, 1)
+	})
 }
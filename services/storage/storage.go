@@ -10,17 +10,59 @@ import (
 type Storage interface {
 	// block
 	GetLatestBlock() (models.Block, error)
+	GetBlockByHeight(height int64) (models.Block, error)
+	OrphanBlocksAfter(height int64) error
+	GetBlockCreatedAtsBefore(height, n int64) ([]time.Time, error)
 
 	// transaction
 	GetUnconfirmedTransactions(confirmations int64) ([]models.Transaction, error)
 	GetTransactionsByGameOfs(gameOfs ...time.Time) ([]models.Transaction, error)
-	UpdateTransactionConfirmationByID(id int64, confirmations int64) error
+	UpdateConfirmationsByTipHeight(tipHeight, minConfirmations int64, excludedIDs ...int64) error
 
 	// game
 	GetGames(limit, offset int64) ([]models.Game, error)
 	GetDrawingNeededGames() ([]models.Game, error)
+	GetLatestClosedGame() (models.Game, error)
+	GetGameByStartHeight(height int64) (models.Game, error)
 	UpdateGameToEndedStatus(models.Game) error
 
+	// excluded transaction
+	GetExcludedTransactions(limit, offset int64) ([]models.ExcludedTransaction, error)
+
+	// mempool transaction
+	GetMempoolTransactions() ([]models.MempoolTransaction, error)
+	ReplaceMempoolTransactions([]models.MempoolTransaction) error
+
+	// refund
+	GetPendingRefunds(height int64) ([]models.Refund, error)
+	GetRefundsByGameOfs(gameOfs ...time.Time) ([]models.Refund, error)
+	UpdateRefundTransaction(refund models.Refund) error
+	UpdateRefundToSentStatus(id int64, transactionID string) error
+
+	// payout
+	GetDuePayouts(now time.Time) ([]models.Payout, error)
+	GetBroadcastPayouts() ([]models.Payout, error)
+	GetUnsentPayouts() ([]models.Payout, error)
+	SavePayoutAndUpdateGameToPayingStatus(models.Game, models.Payout) error
+	UpdatePayoutToBroadcastingStatus(id int64) error
+	UpdatePayoutsToBroadcastingStatus(ids []int64, batchKey string) error
+	UpdatePayoutsTransaction(payouts []models.Payout) error
+	UpdatePayoutToFailedStatus(id int64, status, lastError string, nextAttemptAt time.Time) error
+	UpdatePayoutToConfirmedStatus(id int64) error
+	RequeuePayout(id int64) error
+
+	// reserve
+	GetLiabilities() (models.Liabilities, error)
+
+	// sweep
+	GetUnsweptFees() (models.Amount, error)
+	GetSweeps(limit, offset int64) ([]models.Sweep, error)
+	GetPendingSweep() (models.Sweep, error)
+	SaveSweep(models.Sweep) error
+	UpdateSweepTransaction(models.Sweep) error
+	UpdateSweepToSentStatus(id int64, transactionID string, networkFee models.Amount) error
+	UpdateSweepToFailedStatus(id int64, lastError string) error
+
 	// batch
-	SaveBlockAndTransactions(time.Time, models.Block, []models.Transaction, *models.Game) error
+	SaveBlockAndTransactions(models.Game, models.Block, []models.Transaction, []models.ExcludedTransaction, []models.Refund) error
 }
@@ -1,6 +1,55 @@
 package core
 
-// GetDestAddress gets destination address
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// GetDestAddress gets destination address, the first receiving address of the default label,
+// a new one is labeled if there is none
 func (w Wallet) GetDestAddress() (string, error) {
-	return w.client.GetAccountAddress("")
+	result, err := w.rawRequest("getaddressesbylabel", "")
+	if code, ok := rpcErrorCode(err); ok && code == errRPCWalletInvalidLabelName {
+		return w.getNewAddress()
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("core wallet get addresses by label error: %#v", err)
+	}
+
+	purposes := map[string]struct {
+		Purpose string `json:"purpose"`
+	}{}
+	if err := json.Unmarshal(result, &purposes); err != nil {
+		return "", fmt.Errorf("core wallet get addresses by label unmarshal result error: %#v", err)
+	}
+
+	addresses := []string{}
+	for address, v := range purposes {
+		if v.Purpose == "receive" {
+			addresses = append(addresses, address)
+		}
+	}
+
+	if len(addresses) == 0 {
+		return w.getNewAddress()
+	}
+
+	sort.Strings(addresses)
+	return addresses[0], nil
+}
+
+func (w Wallet) getNewAddress() (string, error) {
+	result, err := w.rawRequest("getnewaddress", "")
+	if err != nil {
+		return "", fmt.Errorf("core wallet get new address error: %#v", err)
+	}
+
+	var address string
+	if err := json.Unmarshal(result, &address); err != nil {
+		return "", fmt.Errorf("core wallet get new address unmarshal result error: %#v", err)
+	}
+
+	return address, nil
 }
@@ -0,0 +1,20 @@
+package core
+
+import "testing"
+
+func TestGetDestAddress(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	w := f.wallet(f.username, f.password)
+
+	// wallet without address of default label gets a new one, which is the dest address from then on
+	address, err := w.GetDestAddress()
+	if err != nil || address != "dest0" {
+		t.Errorf("dest address of new wallet expected dest0 but get %v, error: %v", address, err)
+	}
+
+	f.labeled = append(f.labeled, "another")
+	if address, err := w.GetDestAddress(); err != nil || address != "another" {
+		t.Errorf("dest address expected the first address of default label but get %v, error: %v", address, err)
+	}
+}
@@ -0,0 +1,24 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+// GetBalance gets balance of the whole wallet spendable by payouts, i.e. with outputs confirmed enough to spend,
+// as transactions are funded from any output of the wallet
+func (w Wallet) GetBalance() (models.Amount, error) {
+	result, err := w.rawRequest("getbalance", "*", minConfirmationsToSpend)
+	if err != nil {
+		return 0, fmt.Errorf("core wallet get balance error: %#v", err)
+	}
+
+	var balance float64
+	if err := json.Unmarshal(result, &balance); err != nil {
+		return 0, fmt.Errorf("core wallet get balance unmarshal result error: %#v", err)
+	}
+
+	return models.AmountFromFloat64(balance), nil
+}
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+func TestGetBalance(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	f.setBalance(12.3456789)
+	w := f.wallet(f.username, f.password)
+
+	if balance, err := w.GetBalance(); err != nil || balance != 1234567890 {
+		t.Errorf("balance expected 1234567890 but get %v, error: %v", balance, err)
+	}
+
+	f.failWith("getbalance", btcjson.ErrRPCWallet, "Wallet error")
+	if _, err := w.GetBalance(); err == nil {
+		t.Errorf("get balance with rpc error expected error but get nil")
+	}
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+const (
+	// maxBatchSize caps number of calls sent in one round-trip, larger batches are split
+	maxBatchSize = 100
+	// batchTimeout bounds a round-trip so that a stalled node does not hang the caller forever
+	batchTimeout = 30 * time.Second
+)
+
+// batchCall is a single call of JSON-RPC batch
+type batchCall struct {
+	method string
+	params []interface{}
+}
+
+// batchResult is the result of a single call, err is *btcjson.RPCError if bitcoind rejects the call
+type batchResult struct {
+	result json.RawMessage
+	err    error
+}
+
+// batchClient sends JSON-RPC batch arrays which btcrpcclient does not support
+type batchClient struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newBatchClient(rpchost, rpcusername, rpcpassword string) batchClient {
+	return batchClient{
+		url:      "http://" + rpchost,
+		username: rpcusername,
+		password: rpcpassword,
+		client:   &http.Client{Timeout: batchTimeout},
+	}
+}
+
+// do sends calls in as few round-trips as possible, results are in order of calls,
+// error is returned only if a whole round-trip fails, every call fails or succeeds on its own otherwise
+func (c batchClient) do(calls []batchCall) ([]batchResult, error) {
+	results := make([]batchResult, 0, len(calls))
+	for start := 0; start < len(calls); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+
+		batchResults, err := c.roundTrip(calls[start:end])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batchResults...)
+	}
+
+	return results, nil
+}
+
+func (c batchClient) roundTrip(calls []batchCall) ([]batchResult, error) {
+	requests := make([]btcjson.Request, len(calls))
+	for i, call := range calls {
+		params := make([]json.RawMessage, len(call.params))
+		for j, param := range call.params {
+			rawParam, err := json.Marshal(param)
+			if err != nil {
+				return nil, err
+			}
+			params[j] = rawParam
+		}
+		requests[i] = btcjson.Request{Jsonrpc: "1.0", ID: i, Method: call.method, Params: params}
+	}
+
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responses := []struct {
+		Result json.RawMessage   `json:"result"`
+		Error  *btcjson.RPCError `json:"error"`
+		ID     *int              `json:"id"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
+		return nil, fmt.Errorf("core wallet batch request status %v, unmarshal response error: %#v", resp.StatusCode, err)
+	}
+
+	// responses may come in any order, they are matched to calls by id
+	results := make([]batchResult, len(calls))
+	answered := make([]bool, len(calls))
+	for _, response := range responses {
+		if response.ID == nil || *response.ID < 0 || *response.ID >= len(calls) {
+			continue
+		}
+
+		i := *response.ID
+		answered[i] = true
+		if response.Error != nil {
+			results[i].err = response.Error
+			continue
+		}
+		results[i].result = response.Result
+	}
+
+	for i := range results {
+		if !answered[i] {
+			results[i].err = fmt.Errorf("core wallet batch request got no response of %v", calls[i].method)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+func TestBatchClient(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	f.addPrevout(txid(1), 1, []string{"sender"})
+	c := newBatchClient(strings.TrimPrefix(f.server.URL, "http://"), f.username, f.password)
+
+	calls := make([]batchCall, maxBatchSize+1)
+	for i := range calls {
+		calls[i] = batchCall{method: "getrawtransaction", params: []interface{}{txid(i%2 + 1), 1}}
+	}
+
+	results, err := c.do(calls)
+	if err != nil || len(results) != len(calls) {
+		t.Fatalf("batch results expected %v but get %v, error: %v", len(calls), len(results), err)
+	}
+
+	// each call fails or succeeds on its own
+	if r := results[0]; r.err != nil || !strings.Contains(string(r.result), txid(1)) {
+		t.Errorf("result of known transaction is unexpected %s, error: %v", r.result, r.err)
+	}
+
+	if code, ok := rpcErrorCode(results[1].err); !ok || code != btcjson.ErrRPCInvalidAddressOrKey {
+		t.Errorf("result of unknown transaction expected invalid address or key error but get %v", results[1].err)
+	}
+
+	if f.roundTrips != 2 {
+		t.Errorf("batch of %v calls expected 2 round-trips but get %v", len(calls), f.roundTrips)
+	}
+}
+
+func TestBatchClientAuthenticationFailure(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	c := newBatchClient(strings.TrimPrefix(f.server.URL, "http://"), f.username, "wrong password")
+
+	if _, err := c.do([]batchCall{{method: "getblockcount"}}); err == nil {
+		t.Errorf("batch with wrong password expected error but get nil")
+	}
+}
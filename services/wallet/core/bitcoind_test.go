@@ -0,0 +1,617 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// fakeBitcoind is a local stand-in of bitcoind JSON-RPC server with canned chain data
+type fakeBitcoind struct {
+	mu              sync.Mutex
+	server          *httptest.Server
+	username        string
+	password        string
+	blocks          []*wire.MsgBlock
+	received        []btcjson.ListTransactionsResult
+	sent            []btcjson.ListTransactionsResult
+	rawTransactions map[string]btcjson.TxRawResult
+	conflicted      map[string]bool
+	roundTrips      int     // number of http requests served, a batch counts once
+	feeRate         float64 // per kB returned by estimatesmartfee, zero if node cannot estimate
+	balance         float64
+	labeled         []string // receiving addresses of the default label
+	txIndex         bool     // whether raw transactions not in wallet are found, e.g. coinbase of every block
+	errors          map[string]*btcjson.RPCError
+	coins           int             // wallet coins spent by funded transactions so far, each funds one transaction
+	locked          map[string]bool // txid of wallet coins locked by fundrawtransaction
+}
+
+func newFakeBitcoind() *fakeBitcoind {
+	f := &fakeBitcoind{
+		username:        "username",
+		password:        "password",
+		rawTransactions: map[string]btcjson.TxRawResult{},
+		conflicted:      map[string]bool{},
+		txIndex:         true,
+		errors:          map[string]*btcjson.RPCError{},
+		locked:          map[string]bool{},
+	}
+	f.addBlock(time.Unix(1468000000, 0))
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+func (f *fakeBitcoind) close() {
+	f.server.Close()
+}
+
+// wallet returns core wallet connecting to the fake server with credentials
+func (f *fakeBitcoind) wallet(username, password string) Wallet {
+	return f.walletWithSenderPolicy(username, password, wallet.SenderPolicyLargestInput)
+}
+
+func (f *fakeBitcoind) walletWithSenderPolicy(username, password, senderPolicy string) Wallet {
+	w, err := New(strings.TrimPrefix(f.server.URL, "http://"), username, password, senderPolicy)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// coinbaseTxID is id of coinbase transaction of fake block at height, only found with txindex
+func coinbaseTxID(height int64) string {
+	return fmt.Sprintf("coinbase%v", height)
+}
+
+func (f *fakeBitcoind) addBlock(createdAt time.Time) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	header := wire.BlockHeader{Version: 1, Timestamp: createdAt, Nonce: uint32(len(f.blocks))}
+	if len(f.blocks) > 0 {
+		header.PrevBlock = f.blocks[len(f.blocks)-1].BlockSha()
+	}
+	f.blocks = append(f.blocks, wire.NewMsgBlock(&header))
+	return header.BlockSha().String()
+}
+
+// addReceive adds a receive entry of output 0 to wallet and a raw transaction spending prevouts
+func (f *fakeBitcoind) addReceive(blockHash, txid string, amount float64, vin ...btcjson.Vin) {
+	f.addReceiveOutput(blockHash, txid, 0, "dest", amount, vin...)
+}
+
+// addReceiveOutput adds a receive entry of output vout paying to address
+func (f *fakeBitcoind) addReceiveOutput(blockHash, txid string, vout uint32, address string, amount float64, vin ...btcjson.Vin) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.received = append(f.received, btcjson.ListTransactionsResult{
+		Category:  "receive",
+		Address:   address,
+		Amount:    amount,
+		BlockHash: blockHash,
+		BlockTime: f.blockByHash(blockHash).Header.Timestamp.Unix(),
+		TxID:      txid,
+		Vout:      vout,
+	})
+	f.rawTransactions[txid] = btcjson.TxRawResult{Txid: txid, Vin: vin}
+}
+
+// addMempoolReceive adds an unconfirmed receive entry to wallet and a raw transaction spending prevouts
+func (f *fakeBitcoind) addMempoolReceive(txid string, amount float64, receivedAt time.Time, vin ...btcjson.Vin) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.received = append(f.received, btcjson.ListTransactionsResult{
+		Category: "receive",
+		Address:  "dest",
+		Amount:   amount,
+		Time:     receivedAt.Unix(),
+		TxID:     txid,
+	})
+	f.rawTransactions[txid] = btcjson.TxRawResult{Txid: txid, Vin: vin}
+}
+
+// addPrevout adds a raw transaction whose outputs of value are paid to addresses
+func (f *fakeBitcoind) addPrevout(txid string, value float64, addresses ...[]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	vout := make([]btcjson.Vout, len(addresses))
+	for i, a := range addresses {
+		vout[i] = btcjson.Vout{N: uint32(i), Value: value, ScriptPubKey: btcjson.ScriptPubKeyResult{Addresses: a}}
+	}
+	f.rawTransactions[txid] = btcjson.TxRawResult{Txid: txid, Vout: vout, Confirmations: 1}
+}
+
+// markConflicted makes wallet report transaction as conflicted
+func (f *fakeBitcoind) markConflicted(txid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.conflicted[txid] = true
+}
+
+// setBalance sets spendable balance of account
+func (f *fakeBitcoind) setBalance(balance float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.balance = balance
+}
+
+// mineSent mines every sent transaction not mined yet in block of blockHash
+func (f *fakeBitcoind) mineSent(blockHash string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.sent {
+		if f.sent[i].BlockHash == "" {
+			f.sent[i].BlockHash = blockHash
+		}
+	}
+}
+
+// sendTo funds and broadcasts transaction paying amount to address, as payouts are sent
+func sendTo(t *testing.T, w Wallet, address string, amount models.Amount) string {
+	tx, err := w.FundTransaction(map[string]models.Amount{address: amount}, nil, 6)
+	if err != nil {
+		t.Fatalf("fund transaction expected no error but get %v", err)
+	}
+
+	if err := w.BroadcastTransaction(tx.RawTransaction); err != nil {
+		t.Fatalf("broadcast transaction expected no error but get %v", err)
+	}
+
+	return tx.TransactionID
+}
+
+// failWith makes every call of method fail with rpc error
+func (f *fakeBitcoind) failWith(method string, code btcjson.RPCErrorCode, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.errors[method] = &btcjson.RPCError{Code: code, Message: message}
+}
+
+// fakeTransactionSize is size in bytes of every transaction funded by fake wallet
+const fakeTransactionSize = 226
+
+// fakeFallbackFeeRate is fee rate per kB wallet pays if fee rate is not given
+const fakeFallbackFeeRate = 0.0002
+
+// setFeeRate makes node estimate fee rate per kB, zero if it cannot estimate
+func (f *fakeBitcoind) setFeeRate(feeRate float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.feeRate = feeRate
+}
+
+// fee returns fee of fake transaction at fee rate per kB, wallet falls back to its own rate if it is zero
+func fee(feeRate float64) float64 {
+	if feeRate == 0 {
+		feeRate = fakeFallbackFeeRate
+	}
+	return feeRate * fakeTransactionSize / 1000
+}
+
+// fakeChangeAddress receives change of every transaction funded by fake wallet
+const fakeChangeAddress = "change"
+
+// fakeRawTransaction is what hex of fake raw transaction encodes
+type fakeRawTransaction struct {
+	Inputs  []string     `json:"inputs"` // txid of wallet coin spent at vout 0
+	Outputs []fakeOutput `json:"outputs"`
+	Fee     float64      `json:"fee"`
+	Signed  bool         `json:"signed"`
+}
+
+type fakeOutput struct {
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+}
+
+func (tx fakeRawTransaction) encode() string {
+	b, _ := json.Marshal(tx)
+	return hex.EncodeToString(b)
+}
+
+func decodeFakeRawTransaction(rawTransaction string) (fakeRawTransaction, bool) {
+	tx := fakeRawTransaction{}
+	b, err := hex.DecodeString(rawTransaction)
+	if err != nil || json.Unmarshal(b, &tx) != nil {
+		return tx, false
+	}
+	return tx, true
+}
+
+// fakeTxID returns id of fake raw transaction, which is hash of it
+func fakeTxID(rawTransaction string) string {
+	sum := sha256.Sum256([]byte(rawTransaction))
+	return hex.EncodeToString(sum[:])
+}
+
+// txid returns a valid transaction id made of n
+func txid(n int) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+func (f *fakeBitcoind) blockByHash(hash string) *wire.MsgBlock {
+	if height := f.heightByHash(hash); height >= 0 {
+		return f.blocks[height]
+	}
+	return nil
+}
+
+func (f *fakeBitcoind) heightByHash(hash string) int64 {
+	for i, b := range f.blocks {
+		if b.BlockSha().String() == hash {
+			return int64(i)
+		}
+	}
+	return -1
+}
+
+func (f *fakeBitcoind) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if username, password, ok := r.BasicAuth(); !ok || username != f.username || password != f.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body := json.RawMessage{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.roundTrips++
+
+	// bitcoind answers batch with array of responses and http status 200
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		requests := []btcjson.Request{}
+		if err := json.Unmarshal(body, &requests); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		responses := make([]map[string]interface{}, len(requests))
+		for i, request := range requests {
+			result, rpcErr := f.handle(request.Method, request.Params)
+			responses[i] = map[string]interface{}{"result": result, "error": rpcErr, "id": request.ID}
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+
+	request := btcjson.Request{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	result, rpcErr := f.handle(request.Method, request.Params)
+
+	// bitcoind responds rpc errors with http status 500
+	if rpcErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"result": result,
+		"error":  rpcErr,
+		"id":     request.ID,
+	})
+}
+
+func (f *fakeBitcoind) handle(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+	if err, ok := f.errors[method]; ok {
+		return nil, err
+	}
+
+	switch method {
+	case "getblockcount":
+		return len(f.blocks) - 1, nil
+
+	case "getblockhash":
+		var height int
+		json.Unmarshal(params[0], &height)
+		if height < 0 || height >= len(f.blocks) {
+			return nil, &btcjson.RPCError{Code: btcjson.ErrRPCOutOfRange, Message: "Block height out of range"}
+		}
+		return f.blocks[height].BlockSha().String(), nil
+
+	case "getblock":
+		var hash string
+		json.Unmarshal(params[0], &hash)
+		b := f.blockByHash(hash)
+		if b == nil {
+			return nil, &btcjson.RPCError{Code: btcjson.ErrRPCBlockNotFound, Message: "Block not found"}
+		}
+		var verbose bool
+		if len(params) > 1 {
+			json.Unmarshal(params[1], &verbose)
+		}
+		if verbose {
+			height := f.heightByHash(hash)
+			return btcjson.GetBlockVerboseResult{
+				Hash:          hash,
+				Confirmations: uint64(int64(len(f.blocks)) - height),
+				Height:        height,
+				Time:          b.Header.Timestamp.Unix(),
+				PreviousHash:  b.Header.PrevBlock.String(),
+				Tx:            []string{coinbaseTxID(height)},
+			}, nil
+		}
+		buf := bytes.Buffer{}
+		b.Serialize(&buf)
+		return hex.EncodeToString(buf.Bytes()), nil
+
+	case "listsinceblock":
+		var hash string
+		json.Unmarshal(params[0], &hash)
+		height := f.heightByHash(hash)
+		if height < 0 {
+			return nil, &btcjson.RPCError{Code: btcjson.ErrRPCInvalidAddressOrKey, Message: "Block not found"}
+		}
+		transactions := []btcjson.ListTransactionsResult{}
+		for _, tx := range f.received {
+			if txHeight := f.heightByHash(tx.BlockHash); txHeight > height {
+				tx.Confirmations = int64(len(f.blocks)) - txHeight
+				transactions = append(transactions, tx)
+			}
+		}
+		return btcjson.ListSinceBlockResult{Transactions: transactions, LastBlock: f.blocks[len(f.blocks)-1].BlockSha().String()}, nil
+
+	case "getindexinfo":
+		if f.txIndex {
+			return map[string]interface{}{"txindex": map[string]interface{}{"synced": true}}, nil
+		}
+		return map[string]interface{}{}, nil
+
+	case "getrawtransaction":
+		var txid string
+		json.Unmarshal(params[0], &txid)
+		if f.txIndex && strings.HasPrefix(txid, "coinbase") {
+			return btcjson.TxRawResult{Txid: txid}, nil
+		}
+		tx, ok := f.rawTransactions[txid]
+		if !ok {
+			return nil, &btcjson.RPCError{Code: btcjson.ErrRPCInvalidAddressOrKey, Message: "No information available about transaction"}
+		}
+		return tx, nil
+
+	case "gettransaction":
+		var txid string
+		json.Unmarshal(params[0], &txid)
+		for _, tx := range f.received {
+			if tx.TxID != txid {
+				continue
+			}
+			result := btcjson.GetTransactionResult{TxID: txid, BlockHash: tx.BlockHash}
+			if height := f.heightByHash(tx.BlockHash); height >= 0 {
+				result.Confirmations = int64(len(f.blocks)) - height
+			}
+			if f.conflicted[txid] {
+				result.Confirmations = -1
+			}
+			return result, nil
+		}
+		for _, tx := range f.sent {
+			if tx.TxID == txid {
+				result := btcjson.GetTransactionResult{TxID: txid, BlockHash: tx.BlockHash, Fee: *tx.Fee}
+				if height := f.heightByHash(tx.BlockHash); height >= 0 {
+					result.Confirmations = int64(len(f.blocks)) - height
+				}
+				return result, nil
+			}
+		}
+		return nil, &btcjson.RPCError{Code: btcjson.ErrRPCInvalidAddressOrKey, Message: "Invalid or non-wallet transaction id"}
+
+	case "estimatesmartfee":
+		if f.feeRate == 0 {
+			return map[string]interface{}{"errors": []string{"Insufficient data or no feerate found"}, "blocks": 0}, nil
+		}
+		return map[string]interface{}{"feerate": f.feeRate, "blocks": 2}, nil
+
+	case "createrawtransaction":
+		amounts := map[string]float64{}
+		json.Unmarshal(params[1], &amounts)
+		addresses := []string{}
+		for address := range amounts {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+		tx := fakeRawTransaction{}
+		for _, address := range addresses {
+			tx.Outputs = append(tx.Outputs, fakeOutput{Address: address, Amount: amounts[address]})
+		}
+		return tx.encode(), nil
+
+	case "fundrawtransaction":
+		var rawTransaction string
+		json.Unmarshal(params[0], &rawTransaction)
+		tx, ok := decodeFakeRawTransaction(rawTransaction)
+		if !ok {
+			return nil, &btcjson.RPCError{Code: btcjson.ErrRPCDeserialization, Message: "TX decode failed"}
+		}
+		options := struct {
+			FeeRate                float64 `json:"feeRate"`
+			SubtractFeeFromOutputs []int   `json:"subtractFeeFromOutputs"`
+			LockUnspents           bool    `json:"lockUnspents"`
+		}{}
+		json.Unmarshal(params[1], &options)
+		tx.Fee = fee(options.FeeRate)
+		f.coins++
+		coin := fmt.Sprintf("coin%v", f.coins)
+		tx.Inputs = []string{coin}
+		if options.LockUnspents {
+			f.locked[coin] = true
+		}
+
+		// fee is split evenly in satoshis, the first output pays the remainder
+		if n := int64(len(options.SubtractFeeFromOutputs)); n > 0 {
+			feeSatoshis := int64(tx.Fee*1e8 + 0.5)
+			for i, pos := range options.SubtractFeeFromOutputs {
+				share := feeSatoshis / n
+				if i == 0 {
+					share += feeSatoshis % n
+				}
+				tx.Outputs[pos].Amount = float64(int64(tx.Outputs[pos].Amount*1e8+0.5)-share) / 1e8
+			}
+		}
+		tx.Outputs = append(tx.Outputs, fakeOutput{Address: fakeChangeAddress, Amount: 0.01})
+		return map[string]interface{}{"hex": tx.encode(), "fee": tx.Fee, "changepos": len(tx.Outputs) - 1}, nil
+
+	case "signrawtransactionwithwallet":
+		var rawTransaction string
+		json.Unmarshal(params[0], &rawTransaction)
+		tx, ok := decodeFakeRawTransaction(rawTransaction)
+		if !ok {
+			return nil, &btcjson.RPCError{Code: btcjson.ErrRPCDeserialization, Message: "TX decode failed"}
+		}
+		tx.Signed = true
+		return map[string]interface{}{"hex": tx.encode(), "complete": true}, nil
+
+	case "decoderawtransaction":
+		var rawTransaction string
+		json.Unmarshal(params[0], &rawTransaction)
+		tx, ok := decodeFakeRawTransaction(rawTransaction)
+		if !ok {
+			return nil, &btcjson.RPCError{Code: btcjson.ErrRPCDeserialization, Message: "TX decode failed"}
+		}
+		vout := []map[string]interface{}{}
+		for i, output := range tx.Outputs {
+			vout = append(vout, map[string]interface{}{"value": output.Amount, "n": i, "scriptPubKey": map[string]interface{}{"address": output.Address}})
+		}
+		vin := []map[string]interface{}{}
+		for _, input := range tx.Inputs {
+			vin = append(vin, map[string]interface{}{"txid": input, "vout": 0})
+		}
+		return map[string]interface{}{"txid": fakeTxID(rawTransaction), "vin": vin, "vout": vout}, nil
+
+	case "lockunspent":
+		var unlock bool
+		outpoints := []struct {
+			TxID string `json:"txid"`
+			Vout uint32 `json:"vout"`
+		}{}
+		json.Unmarshal(params[0], &unlock)
+		json.Unmarshal(params[1], &outpoints)
+		for _, outpoint := range outpoints {
+			if unlock != f.locked[outpoint.TxID] {
+				return nil, &btcjson.RPCError{Code: btcjson.ErrRPCInvalidParameter, Message: "Invalid parameter, expected locked output"}
+			}
+			if unlock {
+				delete(f.locked, outpoint.TxID)
+			} else {
+				f.locked[outpoint.TxID] = true
+			}
+		}
+		return true, nil
+
+	case "sendrawtransaction":
+		var rawTransaction string
+		json.Unmarshal(params[0], &rawTransaction)
+		tx, ok := decodeFakeRawTransaction(rawTransaction)
+		if !ok || !tx.Signed {
+			return nil, &btcjson.RPCError{Code: btcjson.ErrRPCVerify, Message: "mandatory-script-verify-flag-failed"}
+		}
+		id := fakeTxID(rawTransaction)
+		for _, sent := range f.sent {
+			if sent.TxID == id && sent.BlockHash != "" {
+				return nil, &btcjson.RPCError{Code: errRPCVerifyAlreadyInChain, Message: "Transaction already in block chain"}
+			}
+			if sent.TxID == id {
+				return id, nil
+			}
+		}
+		sentFee := -tx.Fee
+		for i, output := range tx.Outputs {
+			if output.Address != fakeChangeAddress {
+				f.sent = append(f.sent, btcjson.ListTransactionsResult{Address: output.Address, Amount: -output.Amount, Fee: &sentFee, Category: "send", TxID: id, Vout: uint32(i)})
+			}
+		}
+		for _, input := range tx.Inputs {
+			delete(f.locked, input)
+		}
+		return id, nil
+
+	case "listtransactions":
+		var account string
+		json.Unmarshal(params[0], &account)
+		transactions := []btcjson.ListTransactionsResult{}
+		for _, tx := range f.received {
+			if account == "*" {
+				if height := f.heightByHash(tx.BlockHash); height >= 0 {
+					tx.Confirmations = int64(len(f.blocks)) - height
+				}
+				transactions = append(transactions, tx)
+			}
+		}
+		for _, tx := range f.sent {
+			if tx.Account == account || account == "*" {
+				transactions = append(transactions, tx)
+			}
+		}
+
+		// count most recent transactions skipping from most recent ones, oldest first
+		count, from := 10, 0
+		if len(params) > 1 {
+			json.Unmarshal(params[1], &count)
+		}
+		if len(params) > 2 {
+			json.Unmarshal(params[2], &from)
+		}
+		end := len(transactions) - from
+		if end < 0 {
+			end = 0
+		}
+		start := end - count
+		if start < 0 {
+			start = 0
+		}
+		return transactions[start:end], nil
+
+	case "getbalance":
+		var dummy string
+		json.Unmarshal(params[0], &dummy)
+		if dummy != "*" {
+			return nil, &btcjson.RPCError{Code: -32, Message: "dummy first argument must be excluded or set to \"*\"."}
+		}
+		return f.balance, nil
+
+	case "getaddressesbylabel":
+		if len(f.labeled) == 0 {
+			return nil, &btcjson.RPCError{Code: errRPCWalletInvalidLabelName, Message: "No addresses with label "}
+		}
+		addresses := map[string]interface{}{}
+		for _, address := range f.labeled {
+			addresses[address] = map[string]string{"purpose": "receive"}
+		}
+		return addresses, nil
+
+	case "getnewaddress":
+		address := fmt.Sprintf("dest%v", len(f.labeled))
+		f.labeled = append(f.labeled, address)
+		return address, nil
+	}
+
+	return nil, &btcjson.RPCError{Code: -32601, Message: "Method not found"}
+}
@@ -1,6 +1,11 @@
 package core
 
 import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/solefaucet/jackpot-server/jerrors"
 	"github.com/solefaucet/jackpot-server/services/wallet"
@@ -8,23 +13,96 @@ import (
 
 // GetBlock get block
 func (w Wallet) GetBlock(bestBlock bool, height int64) (*wallet.Block, error) {
+	if !bestBlock {
+		hash, err := w.getBlockHashOrNoNewBlock(height)
+		return w.getBlockFromHash(height, hash, err)
+	}
+
 	h, err := w.client.GetBlockCount()
 	if err != nil {
 		return nil, err
 	}
 
-	if bestBlock {
-		height = h
+	hash, err := w.client.GetBlockHash(h)
+	return w.getBlockFromHash(h, hash, err)
+}
+
+// getBlockHashOrNoNewBlock gets block count and hash of height in a single batched round-trip,
+// it returns jerrors.ErrNoNewBlock if height is above the tip
+func (w Wallet) getBlockHashOrNoNewBlock(height int64) (*wire.ShaHash, error) {
+	results, err := w.batch.do([]batchCall{
+		{method: "getblockcount"},
+		{method: "getblockhash", params: []interface{}{height}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	count, hash := results[0], results[1]
+	if count.err != nil {
+		return nil, count.err
+	}
+
+	h := int64(0)
+	if err := json.Unmarshal(count.result, &h); err != nil {
+		return nil, fmt.Errorf("core wallet get block count unmarshal result error: %#v", err)
 	}
 
 	if height > h {
 		return nil, jerrors.ErrNoNewBlock
 	}
 
-	hash, err := w.client.GetBlockHash(height)
-	return w.getBlockFromHash(height, hash, err)
+	if hash.err != nil {
+		return nil, hash.err
+	}
+
+	hashStr := ""
+	if err := json.Unmarshal(hash.result, &hashStr); err != nil {
+		return nil, fmt.Errorf("core wallet get block hash unmarshal result error: %#v", err)
+	}
+
+	return wire.NewShaHashFromStr(hashStr)
+}
+
+// GetBestHeight returns height of the tip of main chain
+func (w Wallet) GetBestHeight() (int64, error) {
+	return w.client.GetBlockCount()
+}
+
+// GetBlockByHash gets block known to node whether it is on main chain or not,
+// returns jerrors.ErrNotFound if node does not know the block
+func (w Wallet) GetBlockByHash(hash string) (*wallet.Block, error) {
+	// btcrpcclient cannot unmarshal confirmations of -1 that bitcoind returns for block not on main chain
+	result, err := w.rawRequest("getblock", hash, true)
+	if code, ok := rpcErrorCode(err); ok && code == btcjson.ErrRPCBlockNotFound {
+		return nil, jerrors.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	block := struct {
+		Hash         string `json:"hash"`
+		Height       int64  `json:"height"`
+		PreviousHash string `json:"previousblockhash"`
+		Time         int64  `json:"time"`
+	}{}
+	if err := json.Unmarshal(result, &block); err != nil {
+		return nil, fmt.Errorf("core wallet get block by hash unmarshal result error: %#v", err)
+	}
+
+	return &wallet.Block{
+		Height:         block.Height,
+		PrevHash:       block.PreviousHash,
+		Hash:           block.Hash,
+		BlockCreatedAt: time.Unix(block.Time, 0),
+	}, nil
 }
 
+// getBlockFromHash gets block of hash in a round-trip of its own,
+// getblock takes the hash that getblockhash returns so they cannot share a batch,
+// and transactions of the block are only known once listsinceblock has returned
 func (w Wallet) getBlockFromHash(height int64, hash *wire.ShaHash, err error) (*wallet.Block, error) {
 	if err != nil {
 		return nil, err
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/solefaucet/jackpot-server/jerrors"
+)
+
+func TestGetBlock(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	genesis := f.blocks[0].BlockSha().String()
+	createdAt := time.Unix(1468000600, 0)
+	hash := f.addBlock(createdAt)
+	w := f.wallet(f.username, f.password)
+
+	block, err := w.GetBlock(false, 1)
+	if err != nil {
+		t.Fatalf("get block expected no error but get %v", err)
+	}
+
+	if block.Height != 1 || block.Hash != hash || block.PrevHash != genesis || !block.BlockCreatedAt.Equal(createdAt) {
+		t.Errorf("get block is unexpected %#v", block)
+	}
+
+	best, err := w.GetBlock(true, 0)
+	if err != nil || best.Hash != hash {
+		t.Errorf("best block expected %v but get %#v, error: %v", hash, best, err)
+	}
+
+	if _, err := w.GetBlock(false, 2); err != jerrors.ErrNoNewBlock {
+		t.Errorf("get block ahead of tip expected %v but get %v", jerrors.ErrNoNewBlock, err)
+	}
+}
+
+func TestGetBestHeight(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	f.addBlock(time.Unix(1468000600, 0))
+	f.addBlock(time.Unix(1468001200, 0))
+	w := f.wallet(f.username, f.password)
+
+	if height, err := w.GetBestHeight(); err != nil || height != 2 {
+		t.Errorf("best height expected 2 but get %v, error: %v", height, err)
+	}
+}
+
+func TestGetBlockByHash(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	genesis := f.blocks[0].BlockSha().String()
+	createdAt := time.Unix(1468000600, 0)
+	hash := f.addBlock(createdAt)
+	f.addBlock(time.Unix(1468001200, 0))
+	w := f.wallet(f.username, f.password)
+
+	block, err := w.GetBlockByHash(hash)
+	if err != nil {
+		t.Fatalf("get block by hash expected no error but get %v", err)
+	}
+
+	if block.Height != 1 || block.Hash != hash || block.PrevHash != genesis || !block.BlockCreatedAt.Equal(createdAt) {
+		t.Errorf("get block by hash is unexpected %#v", block)
+	}
+
+	if _, err := w.GetBlockByHash(txid(1)); err != jerrors.ErrNotFound {
+		t.Errorf("get unknown block expected %v but get %v", jerrors.ErrNotFound, err)
+	}
+}
+
+func TestGetBlockWithRPCError(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	f.addBlock(time.Unix(1468000600, 0))
+	w := f.wallet(f.username, f.password)
+
+	f.failWith("getblockhash", btcjson.ErrRPCOutOfRange, "Block height out of range")
+	if _, err := w.GetBlock(false, 1); err == nil {
+		t.Errorf("get block with errored getblockhash expected error but get nil")
+	}
+
+	if _, err := w.GetBlock(false, 2); err != jerrors.ErrNoNewBlock {
+		t.Errorf("get block above tip expected %v but get %v", jerrors.ErrNoNewBlock, err)
+	}
+
+	f.failWith("getblockcount", btcjson.ErrRPCClientInInitialDownload, "Loading block index...")
+	if _, err := w.GetBlock(true, 0); err == nil {
+		t.Errorf("get block with errored getblockcount expected error but get nil")
+	}
+}
@@ -1,19 +1,23 @@
 package core
 
 import (
+	"encoding/json"
+
 	"github.com/btcsuite/btcrpcclient"
 	"github.com/solefaucet/jackpot-server/services/wallet"
 )
 
 // Wallet implements Wallet interface for blockchain manipulation
 type Wallet struct {
-	client *btcrpcclient.Client
+	client       *btcrpcclient.Client
+	batch        batchClient
+	senderPolicy string
 }
 
 var _ wallet.Wallet = Wallet{}
 
-// New create
-func New(rpchost, rpcusername, rpcpassword string) (Wallet, error) {
+// New create, senderPolicy is one of wallet.SenderPolicyLargestInput and wallet.SenderPolicySingleAddress
+func New(rpchost, rpcusername, rpcpassword, senderPolicy string) (Wallet, error) {
 	config := &btcrpcclient.ConnConfig{
 		Host:         rpchost,
 		User:         rpcusername,
@@ -23,5 +27,19 @@ func New(rpchost, rpcusername, rpcpassword string) (Wallet, error) {
 	}
 	client, err := btcrpcclient.New(config, nil)
 
-	return Wallet{client: client}, err
+	return Wallet{client: client, batch: newBatchClient(rpchost, rpcusername, rpcpassword), senderPolicy: senderPolicy}, err
+}
+
+// rawRequest sends request not supported by btcrpcclient, params are marshaled as json
+func (w Wallet) rawRequest(method string, params ...interface{}) (json.RawMessage, error) {
+	rawParams := make([]json.RawMessage, len(params))
+	for i, param := range params {
+		rawParam, err := json.Marshal(param)
+		if err != nil {
+			return nil, err
+		}
+		rawParams[i] = rawParam
+	}
+
+	return w.client.RawRequest(method, rawParams)
 }
@@ -0,0 +1,17 @@
+package core
+
+import "testing"
+
+func TestAuthenticationFailure(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	w := f.wallet(f.username, "wrong password")
+
+	if _, err := w.GetBlock(true, 0); err == nil {
+		t.Errorf("get block with wrong password expected error but get nil")
+	}
+
+	if _, err := w.GetDestAddress(); err == nil {
+		t.Errorf("get dest address with wrong password expected error but get nil")
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// permanentSendErrorCodes are codes of send errors retrying never fixes,
+// others, e.g. insufficient funds, locked wallet or node warming up, may go away
+var permanentSendErrorCodes = map[btcjson.RPCErrorCode]bool{
+	btcjson.ErrRPCType:                     true,
+	btcjson.ErrRPCInvalidAddressOrKey:      true,
+	btcjson.ErrRPCInvalidParameter:         true,
+	btcjson.ErrRPCWalletInvalidAccountName: true,
+	btcjson.ErrRPCRawTxString:              true, // invalid params
+}
+
+// bitcoind error codes btcjson does not define, or names differently since accounts were replaced by labels
+const (
+	errRPCVerifyRejected         btcjson.RPCErrorCode = -26
+	errRPCVerifyAlreadyInChain   btcjson.RPCErrorCode = -27
+	errRPCWalletInvalidLabelName btcjson.RPCErrorCode = -11
+)
+
+// permanentBroadcastErrorCodes are codes of broadcast errors rebroadcasting the same transaction never fixes,
+// e.g. its inputs are spent by another one or it is rejected by policy of node
+var permanentBroadcastErrorCodes = map[btcjson.RPCErrorCode]bool{
+	btcjson.ErrRPCDeserialization: true,
+	btcjson.ErrRPCVerify:          true,
+	errRPCVerifyRejected:          true,
+}
+
+// rpcErrorCode extracts bitcoind error code from err,
+// in HTTP POST mode btcrpcclient returns the raw response body as error message
+func rpcErrorCode(err error) (btcjson.RPCErrorCode, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	if rpcErr, ok := err.(*btcjson.RPCError); ok {
+		return rpcErr.Code, true
+	}
+
+	response := struct {
+		Error *btcjson.RPCError `json:"error"`
+	}{}
+	if json.Unmarshal([]byte(err.Error()), &response) != nil || response.Error == nil {
+		return 0, false
+	}
+
+	return response.Error.Code, true
+}
+
+// classifySendError wraps err of send in wallet.PermanentError if bitcoind error code of cause is permanent
+func classifySendError(err, cause error) error {
+	if code, ok := rpcErrorCode(cause); ok && permanentSendErrorCodes[code] {
+		return wallet.PermanentError{Err: err}
+	}
+	return err
+}
+
+// classifyBroadcastError wraps err of broadcast in wallet.PermanentError if bitcoind error code of cause is permanent
+func classifyBroadcastError(err, cause error) error {
+	if code, ok := rpcErrorCode(cause); ok && permanentBroadcastErrorCodes[code] {
+		return wallet.PermanentError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+type estimateSmartFeeResult struct {
+	FeeRate float64  `json:"feerate"` // per kB, missing if node has not seen enough blocks to estimate
+	Errors  []string `json:"errors"`
+}
+
+type fundRawTransactionResult struct {
+	Hex string  `json:"hex"`
+	Fee float64 `json:"fee"`
+}
+
+// EstimateFee estimates network fee of sending amount to address within confTarget blocks,
+// wallet funds the transaction at fee rate of estimatesmartfee as it would on sending, but never broadcasts it
+func (w Wallet) EstimateFee(address string, amount models.Amount, confTarget int64) (models.Amount, error) {
+	funded, err := w.fundRawTransaction(map[string]models.Amount{address: amount}, nil, confTarget, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return models.AmountFromFloat64(funded.Fee), nil
+}
+
+// fundRawTransaction creates transaction paying amounts and funds it at fee rate of estimatesmartfee,
+// fee is taken evenly out of outputs to subtractFeeFrom, and paid on top of amounts if there are none,
+// inputs are locked if lockUnspents is set so that no other spend of wallet selects them before it is broadcast
+func (w Wallet) fundRawTransaction(amounts map[string]models.Amount, subtractFeeFrom []string, confTarget int64, lockUnspents bool) (fundRawTransactionResult, error) {
+	feeRate, err := w.estimateFeeRate(confTarget)
+	if err != nil {
+		return fundRawTransactionResult{}, err
+	}
+
+	result, err := w.rawRequest("createrawtransaction", []interface{}{}, amounts)
+	if err != nil {
+		return fundRawTransactionResult{}, classifySendError(fmt.Errorf("core wallet create raw transaction error: %#v", err), err)
+	}
+
+	var rawTransaction string
+	if err := json.Unmarshal(result, &rawTransaction); err != nil {
+		return fundRawTransactionResult{}, fmt.Errorf("core wallet create raw transaction unmarshal result error: %#v", err)
+	}
+
+	// wallet falls back to its own fee rate if node cannot estimate
+	options := map[string]interface{}{"lockUnspents": lockUnspents}
+	if feeRate > 0 {
+		options["feeRate"] = feeRate
+	}
+
+	// outputs are created in order of addresses as amounts are marshaled with sorted keys
+	if len(subtractFeeFrom) > 0 {
+		addresses := make([]string, 0, len(amounts))
+		for address := range amounts {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+
+		positions := make([]int, 0, len(subtractFeeFrom))
+		for i, address := range addresses {
+			for _, a := range subtractFeeFrom {
+				if a == address {
+					positions = append(positions, i)
+				}
+			}
+		}
+		options["subtractFeeFromOutputs"] = positions
+	}
+
+	result, err = w.rawRequest("fundrawtransaction", rawTransaction, options)
+	if err != nil {
+		return fundRawTransactionResult{}, classifySendError(fmt.Errorf("core wallet fund raw transaction error: %#v", err), err)
+	}
+
+	funded := fundRawTransactionResult{}
+	if err := json.Unmarshal(result, &funded); err != nil {
+		return fundRawTransactionResult{}, fmt.Errorf("core wallet fund raw transaction unmarshal result error: %#v", err)
+	}
+
+	return funded, nil
+}
+
+// estimateFeeRate returns fee rate per kB to confirm within confTarget blocks, zero if node cannot estimate
+func (w Wallet) estimateFeeRate(confTarget int64) (models.Amount, error) {
+	result, err := w.rawRequest("estimatesmartfee", confTarget)
+	if err != nil {
+		return 0, fmt.Errorf("core wallet estimate smart fee error: %#v", err)
+	}
+
+	estimate := estimateSmartFeeResult{}
+	if err := json.Unmarshal(result, &estimate); err != nil {
+		return 0, fmt.Errorf("core wallet estimate smart fee unmarshal result error: %#v", err)
+	}
+
+	return models.AmountFromFloat64(estimate.FeeRate), nil
+}
+
+// GetTransactionFee gets network fee paid by transaction sent from wallet
+func (w Wallet) GetTransactionFee(txid string) (models.Amount, error) {
+	transaction, err := w.getTransaction(txid)
+	if err != nil {
+		return 0, err
+	}
+
+	// wallet reports fee of sent transaction as negative amount
+	return -models.AmountFromFloat64(transaction.Fee), nil
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestEstimateFee(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	w := f.wallet(f.username, f.password)
+
+	// 0.001 per kB of 226 bytes
+	f.setFeeRate(0.001)
+	if fee, err := w.EstimateFee("winner", 29000000, 6); err != nil || fee != 22600 {
+		t.Errorf("estimate fee expected 22600 but get %v, error: %v", fee, err)
+	}
+
+	// wallet falls back to 0.0002 per kB
+	f.setFeeRate(0)
+	if fee, err := w.EstimateFee("winner", 29000000, 6); err != nil || fee != 4520 {
+		t.Errorf("estimate fee without node estimation expected 4520 but get %v, error: %v", fee, err)
+	}
+
+	f.failWith("fundrawtransaction", btcjson.ErrRPCWalletInsufficientFunds, "Insufficient funds")
+	if _, err := w.EstimateFee("winner", 29000000, 6); err == nil {
+		t.Errorf("estimate fee with insufficient funds expected error but get nil")
+	}
+}
+
+func TestGetTransactionFee(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	f.setFeeRate(0.001)
+	w := f.wallet(f.username, f.password)
+
+	txid := sendTo(t, w, "winner", 29000000)
+	if fee, err := w.GetTransactionFee(txid); err != nil || fee != models.Amount(22600) {
+		t.Errorf("transaction fee expected 22600 but get %v, error: %v", fee, err)
+	}
+
+	if _, err := w.GetTransactionFee(txid + "0"); err == nil {
+		t.Errorf("fee of unknown transaction expected error but get nil")
+	}
+}
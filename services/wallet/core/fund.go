@@ -0,0 +1,142 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+type signRawTransactionResult struct {
+	Hex      string `json:"hex"`
+	Complete bool   `json:"complete"`
+}
+
+type decodeRawTransactionResult struct {
+	TxID string `json:"txid"`
+	Vin  []struct {
+		TxID string `json:"txid"`
+		Vout uint32 `json:"vout"`
+	} `json:"vin"`
+	Vout []struct {
+		Value        float64 `json:"value"`
+		N            uint32  `json:"n"`
+		ScriptPubKey struct {
+			Address   string   `json:"address"`
+			Addresses []string `json:"addresses"` // nodes before 22.0
+		} `json:"scriptPubKey"`
+	} `json:"vout"`
+}
+
+// FundTransaction funds transaction paying amounts at fee rate of estimatesmartfee and signs it,
+// fee is taken evenly out of outputs to subtractFeeFrom, and paid on top of amounts if there are none,
+// the transaction is not broadcast so that it is recorded before it leaves the wallet, and its inputs stay locked
+// until it is broadcast or released, node forgets the locks when it restarts
+func (w Wallet) FundTransaction(amounts map[string]models.Amount, subtractFeeFrom []string, confTarget int64) (wallet.FundedTransaction, error) {
+	funded, err := w.fundRawTransaction(amounts, subtractFeeFrom, confTarget, true)
+	if err != nil {
+		return wallet.FundedTransaction{}, err
+	}
+
+	signed, err := w.signRawTransaction(funded.Hex)
+	if err != nil {
+		return wallet.FundedTransaction{}, err
+	}
+
+	decoded, err := w.decodeRawTransaction(signed)
+	if err != nil {
+		return wallet.FundedTransaction{}, err
+	}
+
+	transaction := wallet.FundedTransaction{
+		TransactionID:  decoded.TxID,
+		RawTransaction: signed,
+		Fee:            models.AmountFromFloat64(funded.Fee),
+	}
+	for _, vout := range decoded.Vout {
+		address := vout.ScriptPubKey.Address
+		if address == "" && len(vout.ScriptPubKey.Addresses) == 1 {
+			address = vout.ScriptPubKey.Addresses[0]
+		}
+
+		transaction.Outputs = append(transaction.Outputs, wallet.SentOutput{
+			TransactionID: decoded.TxID,
+			Vout:          vout.N,
+			Address:       address,
+			Amount:        models.AmountFromFloat64(vout.Value),
+		})
+	}
+
+	return transaction, nil
+}
+
+// ReleaseTransaction unlocks inputs of funded transaction dropped without being broadcast, so that other spends can use them,
+// input no longer locked, e.g. as node restarted, or spent already is left as it is
+func (w Wallet) ReleaseTransaction(rawTransaction string) error {
+	decoded, err := w.decodeRawTransaction(rawTransaction)
+	if err != nil {
+		return err
+	}
+
+	for _, vin := range decoded.Vin {
+		_, err := w.rawRequest("lockunspent", true, []map[string]interface{}{{"txid": vin.TxID, "vout": vin.Vout}})
+		if code, ok := rpcErrorCode(err); ok && code == btcjson.ErrRPCInvalidParameter {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("core wallet unlock unspent error: %#v", err)
+		}
+	}
+
+	return nil
+}
+
+func (w Wallet) decodeRawTransaction(rawTransaction string) (decodeRawTransactionResult, error) {
+	decoded := decodeRawTransactionResult{}
+	result, err := w.rawRequest("decoderawtransaction", rawTransaction)
+	if err != nil {
+		return decoded, fmt.Errorf("core wallet decode raw transaction error: %#v", err)
+	}
+
+	if err := json.Unmarshal(result, &decoded); err != nil {
+		return decoded, fmt.Errorf("core wallet decode raw transaction unmarshal result error: %#v", err)
+	}
+
+	return decoded, nil
+}
+
+// signRawTransaction signs transaction with wallet keys
+func (w Wallet) signRawTransaction(rawTransaction string) (string, error) {
+	result, err := w.rawRequest("signrawtransactionwithwallet", rawTransaction)
+	if err != nil {
+		return "", classifySendError(fmt.Errorf("core wallet sign raw transaction error: %#v", err), err)
+	}
+
+	signed := signRawTransactionResult{}
+	if err := json.Unmarshal(result, &signed); err != nil {
+		return "", fmt.Errorf("core wallet sign raw transaction unmarshal result error: %#v", err)
+	}
+
+	if !signed.Complete {
+		return "", fmt.Errorf("core wallet cannot sign every input of raw transaction")
+	}
+
+	return signed.Hex, nil
+}
+
+// BroadcastTransaction broadcasts signed transaction, transaction already on chain counts as broadcast
+func (w Wallet) BroadcastTransaction(rawTransaction string) error {
+	_, err := w.rawRequest("sendrawtransaction", rawTransaction)
+	if code, ok := rpcErrorCode(err); ok && code == errRPCVerifyAlreadyInChain {
+		return nil
+	}
+
+	if err != nil {
+		return classifyBroadcastError(fmt.Errorf("core wallet send raw transaction error: %#v", err), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+func TestFundTransaction(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	f.setFeeRate(0.001)
+	w := f.wallet(f.username, f.password)
+
+	// house pays 22600 on top of amounts
+	tx, err := w.FundTransaction(map[string]models.Amount{"winner2": 1000000, "winner1": 29000000}, nil, 6)
+	if err != nil {
+		t.Fatalf("fund transaction expected no error but get %v", err)
+	}
+
+	if tx.Fee != 22600 || tx.TransactionID == "" || tx.RawTransaction == "" {
+		t.Errorf("funded transaction is unexpected %#v", tx)
+	}
+
+	if output, ok := tx.OutputTo("winner1"); !ok || output.Vout != 0 || output.Amount != 29000000 || output.TransactionID != tx.TransactionID {
+		t.Errorf("output to winner1 is unexpected %#v", output)
+	}
+
+	// fee is taken evenly out of outputs to subtract fee from
+	tx, err = w.FundTransaction(map[string]models.Amount{"winner2": 1000000, "winner1": 29000000}, []string{"winner2"}, 6)
+	if err != nil {
+		t.Fatalf("fund transaction subtracting fee expected no error but get %v", err)
+	}
+
+	if output, _ := tx.OutputTo("winner2"); output.Amount != 977400 {
+		t.Errorf("output to winner2 expected 977400 but get %v", output.Amount)
+	}
+
+	if output, _ := tx.OutputTo("winner1"); output.Amount != 29000000 {
+		t.Errorf("output to winner1 expected 29000000 but get %v", output.Amount)
+	}
+
+	// funding never broadcasts
+	if len(f.sent) != 0 {
+		t.Errorf("funded transaction expected not broadcast but get %#v", f.sent)
+	}
+
+	f.failWith("fundrawtransaction", btcjson.ErrRPCInvalidAddressOrKey, "Invalid address")
+	if _, err := w.FundTransaction(map[string]models.Amount{"winner1": 29000000}, nil, 6); !wallet.IsPermanent(err) {
+		t.Errorf("fund transaction to invalid address expected permanent error but get %v", err)
+	}
+}
+
+func TestReleaseTransaction(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	w := f.wallet(f.username, f.password)
+
+	if _, err := w.EstimateFee("winner1", 29000000, 6); err != nil || len(f.locked) != 0 {
+		t.Errorf("estimate fee expected to lock no inputs but get %v, error: %v", f.locked, err)
+	}
+
+	tx, err := w.FundTransaction(map[string]models.Amount{"winner1": 29000000}, nil, 6)
+	if err != nil || len(f.locked) != 1 {
+		t.Fatalf("fund transaction expected to lock its input but get %v, error: %v", f.locked, err)
+	}
+
+	if err := w.ReleaseTransaction(tx.RawTransaction); err != nil || len(f.locked) != 0 {
+		t.Errorf("release transaction expected to unlock its input but get %v, error: %v", f.locked, err)
+	}
+
+	// input no longer locked, e.g. node restarted, is left as it is
+	if err := w.ReleaseTransaction(tx.RawTransaction); err != nil {
+		t.Errorf("release transaction again expected no error but get %v", err)
+	}
+
+	f.failWith("lockunspent", btcjson.ErrRPCWallet, "Wallet error")
+	if err := w.ReleaseTransaction(tx.RawTransaction); err == nil {
+		t.Errorf("release transaction with wallet error expected error but get nil")
+	}
+}
+
+func TestBroadcastTransaction(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	f.setFeeRate(0.001)
+	w := f.wallet(f.username, f.password)
+
+	tx, err := w.FundTransaction(map[string]models.Amount{"winner1": 29000000}, nil, 6)
+	if err != nil {
+		t.Fatalf("fund transaction expected no error but get %v", err)
+	}
+
+	if err := w.BroadcastTransaction(tx.RawTransaction); err != nil {
+		t.Fatalf("broadcast transaction expected no error but get %v", err)
+	}
+
+	// network fee recorded by wallet is the one funded
+	if fee, err := w.GetTransactionFee(tx.TransactionID); err != nil || fee != tx.Fee {
+		t.Errorf("fee of broadcast transaction expected %v but get %v, error: %v", tx.Fee, fee, err)
+	}
+
+	// broadcasting again is harmless, even once mined
+	f.mineSent(f.addBlock(f.blocks[0].Header.Timestamp))
+	if err := w.BroadcastTransaction(tx.RawTransaction); err != nil {
+		t.Errorf("broadcast mined transaction expected no error but get %v", err)
+	}
+
+	if len(f.sent) != 1 {
+		t.Errorf("broadcast transaction expected sent once but get %#v", f.sent)
+	}
+
+	f.failWith("sendrawtransaction", btcjson.ErrRPCVerify, "Missing inputs")
+	if err := w.BroadcastTransaction(tx.RawTransaction); !wallet.IsPermanent(err) {
+		t.Errorf("broadcast transaction with missing inputs expected permanent error but get %v", err)
+	}
+
+	f.failWith("sendrawtransaction", btcjson.ErrRPCClientNotConnected, "Bitcoin is not connected")
+	if err := w.BroadcastTransaction(tx.RawTransaction); err == nil || wallet.IsPermanent(err) {
+		t.Errorf("broadcast transaction without peers expected transient error but get %v", err)
+	}
+}
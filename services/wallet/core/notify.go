@@ -0,0 +1,85 @@
+package core
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcrpcclient"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// buffered so that node is never blocked by a slow consumer,
+// notifications overflowing it are dropped as blocks are refetched from chain anyway
+const notificationBufferSize = 64
+
+// Notifier implements Notifier interface with websocket notifications,
+// it is supported by btcd compatible nodes only since Bitcoin core has no websocket RPC
+type Notifier struct {
+	client        *btcrpcclient.Client
+	notifications chan wallet.BlockNotification
+}
+
+var _ wallet.Notifier = &Notifier{}
+
+// NewNotifier connects to websocket endpoint of rpchost and subscribes to block notifications,
+// TLS is disabled if certificates is empty
+func NewNotifier(rpchost, rpcusername, rpcpassword string, certificates []byte) (*Notifier, error) {
+	n := &Notifier{notifications: make(chan wallet.BlockNotification, notificationBufferSize)}
+
+	config := &btcrpcclient.ConnConfig{
+		Host:         rpchost,
+		Endpoint:     "ws",
+		User:         rpcusername,
+		Pass:         rpcpassword,
+		Certificates: certificates,
+		DisableTLS:   len(certificates) == 0,
+	}
+	client, err := btcrpcclient.New(config, &btcrpcclient.NotificationHandlers{
+		OnClientConnected:   n.onClientConnected,
+		OnBlockConnected:    n.onBlockConnected,
+		OnBlockDisconnected: n.onBlockDisconnected,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// subscription is registered again by client on reconnect
+	if err := client.NotifyBlocks(); err != nil {
+		client.Shutdown()
+		return nil, err
+	}
+
+	n.client = client
+	return n, nil
+}
+
+// BlockNotifications returns channel of block notifications
+func (n *Notifier) BlockNotifications() <-chan wallet.BlockNotification {
+	return n.notifications
+}
+
+// Shutdown closes websocket connection
+func (n *Notifier) Shutdown() {
+	n.client.Shutdown()
+}
+
+// blocks connected while disconnected from node are never notified,
+// a notification without hash wakes consumer up to catch up
+func (n *Notifier) onClientConnected() {
+	n.notify(wallet.BlockNotification{Height: -1, Connected: true})
+}
+
+func (n *Notifier) onBlockConnected(hash *wire.ShaHash, height int32, _ time.Time) {
+	n.notify(wallet.BlockNotification{Height: int64(height), Hash: hash.String(), Connected: true})
+}
+
+func (n *Notifier) onBlockDisconnected(hash *wire.ShaHash, height int32, _ time.Time) {
+	n.notify(wallet.BlockNotification{Height: int64(height), Hash: hash.String(), Connected: false})
+}
+
+func (n *Notifier) notify(notification wallet.BlockNotification) {
+	select {
+	case n.notifications <- notification:
+	default:
+	}
+}
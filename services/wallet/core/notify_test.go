@@ -0,0 +1,42 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+func TestNotifierHandlers(t *testing.T) {
+	n := &Notifier{notifications: make(chan wallet.BlockNotification, notificationBufferSize)}
+	hash, _ := wire.NewShaHashFromStr(txid(1))
+
+	n.onClientConnected()
+	n.onBlockConnected(hash, 10, time.Now())
+	n.onBlockDisconnected(hash, 10, time.Now())
+
+	expected := []wallet.BlockNotification{
+		{Height: -1, Connected: true},
+		{Height: 10, Hash: txid(1), Connected: true},
+		{Height: 10, Hash: txid(1), Connected: false},
+	}
+	for _, e := range expected {
+		if actual := <-n.BlockNotifications(); actual != e {
+			t.Errorf("block notification expected %#v but get %#v", e, actual)
+		}
+	}
+}
+
+func TestNotifierDropsOverflow(t *testing.T) {
+	n := &Notifier{notifications: make(chan wallet.BlockNotification, notificationBufferSize)}
+	hash, _ := wire.NewShaHashFromStr(txid(1))
+
+	for i := 0; i < notificationBufferSize+1; i++ {
+		n.onBlockConnected(hash, int32(i), time.Now())
+	}
+
+	if l := len(n.BlockNotifications()); l != notificationBufferSize {
+		t.Errorf("buffered notifications expected %v but get %v", notificationBufferSize, l)
+	}
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+type input struct {
+	address string
+	amount  models.Amount
+}
+
+// resolveSenderAddress resolves sender of txid with sender policy of wallet,
+// deposits that cannot be attributed to one address are returned with a non-empty reason instead of error
+func (c senderCache) resolveSenderAddress(txid string) (address, unattributedReason string, err error) {
+	resultVin, err := c.getRawTransactionResult(txid)
+	if err != nil {
+		return "", "", err
+	}
+
+	inputs, unattributedReason, err := c.resolveInputs(resultVin.Vin)
+	if err != nil || unattributedReason != "" {
+		return "", unattributedReason, err
+	}
+
+	if len(inputs) == 0 {
+		return "", "transaction has no inputs", nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":      models.LogEventGetSenderAddress,
+		"tx_id":      txid,
+		"result_vin": resultVin,
+		"inputs":     fmt.Sprintf("%v", inputs),
+		"policy":     c.w.senderPolicy,
+	}).Debug("get sender address information")
+
+	switch c.w.senderPolicy {
+	case wallet.SenderPolicySingleAddress:
+		for _, in := range inputs {
+			if in.address != inputs[0].address {
+				return "", "inputs are from multiple addresses", nil
+			}
+		}
+		return inputs[0].address, "", nil
+
+	default:
+		largest := inputs[0]
+		for _, in := range inputs[1:] {
+			if in.amount > largest.amount {
+				largest = in
+			}
+		}
+		return largest.address, "", nil
+	}
+}
+
+// resolveInputs looks up address and amount of every input from its previous output
+func (c senderCache) resolveInputs(vins []btcjson.Vin) ([]input, string, error) {
+	prevTransactions := map[string]*btcjson.TxRawResult{}
+	inputs := make([]input, 0, len(vins))
+	for _, vin := range vins {
+		if vin.IsCoinBase() {
+			return nil, "coinbase input", nil
+		}
+
+		prevTransaction, ok := prevTransactions[vin.Txid]
+		if !ok {
+			// node is checked to run with -txindex on start, so that the previous transaction really does not exist
+			result, err := c.getRawTransactionResult(vin.Txid)
+			if code, ok := rpcErrorCode(err); ok && code == btcjson.ErrRPCInvalidAddressOrKey {
+				return nil, "previous output not found", nil
+			}
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			prevTransaction = result
+			prevTransactions[vin.Txid] = prevTransaction
+		}
+
+		if int(vin.Vout) >= len(prevTransaction.Vout) {
+			return nil, "previous output index out of range", nil
+		}
+
+		prevout := prevTransaction.Vout[vin.Vout]
+		if len(prevout.ScriptPubKey.Addresses) != 1 {
+			return nil, fmt.Sprintf("previous output script %q has %v addresses", prevout.ScriptPubKey.Type, len(prevout.ScriptPubKey.Addresses)), nil
+		}
+
+		inputs = append(inputs, input{
+			address: prevout.ScriptPubKey.Addresses[0],
+			amount:  models.AmountFromFloat64(prevout.Value),
+		})
+	}
+
+	return inputs, "", nil
+}
+
+// outpoint identifies an output of transaction
+type outpoint struct {
+	txid string
+	vout uint32
+}
+
+type sender struct {
+	address            string
+	unattributedReason string
+}
+
+// senderCache resolves sender of every transaction once,
+// outputs of the same transaction share its sender
+type senderCache struct {
+	w               Wallet
+	senders         map[string]sender
+	rawTransactions map[string]rawTransaction
+}
+
+func newSenderCache(w Wallet) senderCache {
+	return senderCache{w: w, senders: make(map[string]sender), rawTransactions: make(map[string]rawTransaction)}
+}
+
+// prefetch gets raw transactions of txids and then their previous transactions,
+// each level takes a single batched round-trip instead of one call per transaction
+func (c senderCache) prefetch(txids []string) error {
+	if err := c.fetchRawTransactions(txids); err != nil {
+		return err
+	}
+
+	prevTxids := []string{}
+	for _, txid := range txids {
+		rawTx := c.rawTransactions[txid]
+		if rawTx.err != nil {
+			continue
+		}
+
+		for _, vin := range rawTx.result.Vin {
+			if !vin.IsCoinBase() {
+				prevTxids = append(prevTxids, vin.Txid)
+			}
+		}
+	}
+
+	return c.fetchRawTransactions(prevTxids)
+}
+
+func (c senderCache) fetchRawTransactions(txids []string) error {
+	missing := []string{}
+	seen := make(map[string]bool)
+	for _, txid := range txids {
+		if _, ok := c.rawTransactions[txid]; !ok && !seen[txid] {
+			seen[txid] = true
+			missing = append(missing, txid)
+		}
+	}
+
+	rawTransactions, err := c.w.getRawTransactionResults(missing)
+	if err != nil {
+		return err
+	}
+
+	for i, txid := range missing {
+		c.rawTransactions[txid] = rawTransactions[i]
+	}
+	return nil
+}
+
+// getRawTransactionResult returns prefetched raw transaction, it falls back to a single call if not prefetched
+func (c senderCache) getRawTransactionResult(txid string) (*btcjson.TxRawResult, error) {
+	if rawTx, ok := c.rawTransactions[txid]; ok {
+		return rawTx.result, rawTx.err
+	}
+
+	return c.w.getRawTransactionResult(txid)
+}
+
+func (c senderCache) getSenderAddress(txid string) (string, string, error) {
+	if s, ok := c.senders[txid]; ok {
+		return s.address, s.unattributedReason, nil
+	}
+
+	address, unattributedReason, err := c.resolveSenderAddress(txid)
+	if err != nil {
+		return "", "", err
+	}
+
+	c.senders[txid] = sender{address: address, unattributedReason: unattributedReason}
+	return address, unattributedReason, nil
+}
@@ -1,16 +1,23 @@
 package core
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/solefaucet/jackpot-server/jerrors"
 	"github.com/solefaucet/jackpot-server/models"
 	"github.com/solefaucet/jackpot-server/services/wallet"
 )
 
+const (
+	minConfirmationsToSpend = 1
+	mempoolLookback         = 1000
+)
+
 // GetReceivedSince returns transactions since block
 func (w Wallet) GetReceivedSince(prevHash, curHash string) ([]wallet.Transaction, error) {
 	blockHash, err := wire.NewShaHashFromStr(prevHash)
@@ -24,24 +31,47 @@ func (w Wallet) GetReceivedSince(prevHash, curHash string) ([]wallet.Transaction
 	}
 
 	var transactions []wallet.Transaction
+	var received []btcjson.ListTransactionsResult
+	senders := newSenderCache(w)
+	seen := make(map[outpoint]bool)
 	for i := len(result.Transactions) - 1; i >= 0; i-- {
 		tx := result.Transactions[i]
 		if tx.Category != "receive" || tx.BlockHash != curHash {
 			continue
 		}
 
-		senderAddress, err := w.getSenderAddress(tx.TxID)
+		// the same output may be listed more than once, e.g. once per label it is credited to
+		if seen[outpoint{tx.TxID, tx.Vout}] {
+			continue
+		}
+		seen[outpoint{tx.TxID, tx.Vout}] = true
+		received = append(received, tx)
+	}
+
+	txids := make([]string, len(received))
+	for i, tx := range received {
+		txids[i] = tx.TxID
+	}
+	if err := senders.prefetch(txids); err != nil {
+		return nil, err
+	}
+
+	for _, tx := range received {
+		senderAddress, unattributedReason, err := senders.getSenderAddress(tx.TxID)
 		if err != nil {
 			return nil, err
 		}
 
 		transaction := wallet.Transaction{
-			Address:        senderAddress,
-			Amount:         tx.Amount,
-			TransactionID:  tx.TxID,
-			Hash:           tx.BlockHash,
-			Confirmations:  tx.Confirmations,
-			BlockCreatedAt: time.Unix(tx.BlockTime, 0),
+			Address:            senderAddress,
+			UnattributedReason: unattributedReason,
+			Amount:             models.AmountFromFloat64(tx.Amount),
+			TransactionID:      tx.TxID,
+			Vout:               tx.Vout,
+			OutputAddress:      tx.Address,
+			Hash:               tx.BlockHash,
+			Confirmations:      tx.Confirmations,
+			BlockCreatedAt:     time.Unix(tx.BlockTime, 0),
 		}
 		transactions = append(transactions, transaction)
 	}
@@ -49,29 +79,40 @@ func (w Wallet) GetReceivedSince(prevHash, curHash string) ([]wallet.Transaction
 	return transactions, nil
 }
 
-// NOTE: getSenderAddress is a bit tricky, not sure if it's reliable or not
-func (w Wallet) getSenderAddress(txid string) (string, error) {
-	resultVin, err := w.getRawTransactionResult(txid)
+// GetMempoolReceived returns received transactions not mined yet, conflicted ones are left out
+func (w Wallet) GetMempoolReceived() ([]wallet.Transaction, error) {
+	results, err := w.client.ListTransactionsCount("*", mempoolLookback)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	vin := resultVin.Vin[0]
 
-	resultVout, err := w.getRawTransactionResult(vin.Txid)
-	if err != nil {
-		return "", err
-	}
+	var transactions []wallet.Transaction
+	senders := newSenderCache(w)
+	seen := make(map[outpoint]bool)
+	for _, tx := range results {
+		if tx.Category != "receive" || tx.Confirmations != 0 || seen[outpoint{tx.TxID, tx.Vout}] {
+			continue
+		}
+		seen[outpoint{tx.TxID, tx.Vout}] = true
 
-	logrus.WithFields(logrus.Fields{
-		"event":          models.LogEventGetSenderAddress,
-		"tx_id":          txid,
-		"result_vin":     resultVin,
-		"result_vout":    resultVout,
-		"vin_tx_id":      vin.Txid,
-		"vin_vout_index": vin.Vout,
-	}).Debug("get sender address information")
+		senderAddress, unattributedReason, err := senders.getSenderAddress(tx.TxID)
+		if err != nil {
+			return nil, err
+		}
+
+		transaction := wallet.Transaction{
+			Address:            senderAddress,
+			UnattributedReason: unattributedReason,
+			Amount:             models.AmountFromFloat64(tx.Amount),
+			TransactionID:      tx.TxID,
+			Vout:               tx.Vout,
+			OutputAddress:      tx.Address,
+			BlockCreatedAt:     time.Unix(tx.Time, 0),
+		}
+		transactions = append(transactions, transaction)
+	}
 
-	return resultVout.Vout[int(vin.Vout)].ScriptPubKey.Addresses[0], nil
+	return transactions, nil
 }
 
 func (w Wallet) getRawTransactionResult(txid string) (*btcjson.TxRawResult, error) {
@@ -95,22 +136,102 @@ func (w Wallet) getRawTransactionResult(txid string) (*btcjson.TxRawResult, erro
 	return result, nil
 }
 
-// SendFromAccountToAddress send coin to address, return transaction id
-func (w Wallet) SendFromAccountToAddress(account, address string, amount float64) (string, error) {
-	hash, err := w.client.SendFrom(account, address, amount)
+// rawTransaction is raw transaction result or error of getting it
+type rawTransaction struct {
+	result *btcjson.TxRawResult
+	err    error
+}
+
+// getRawTransactionResults gets raw transactions of txids in batched round-trips, results are in order of txids
+func (w Wallet) getRawTransactionResults(txids []string) ([]rawTransaction, error) {
+	if len(txids) == 0 {
+		return nil, nil
+	}
+
+	calls := make([]batchCall, len(txids))
+	for i, txid := range txids {
+		calls[i] = batchCall{method: "getrawtransaction", params: []interface{}{txid, 1}}
+	}
+
+	results, err := w.batch.do(calls)
 	if err != nil {
-		return "", fmt.Errorf("core wallet send to address error: %#v", err)
+		return nil, err
 	}
 
-	return hash.String(), nil
+	rawTransactions := make([]rawTransaction, len(results))
+	for i, r := range results {
+		if r.err != nil {
+			rawTransactions[i].err = r.err
+			continue
+		}
+
+		result := &btcjson.TxRawResult{}
+		if err := json.Unmarshal(r.result, result); err != nil {
+			rawTransactions[i].err = fmt.Errorf("core wallet get raw transaction unmarshal result error: %#v", err)
+			continue
+		}
+		rawTransactions[i].result = result
+	}
+
+	return rawTransactions, nil
 }
 
-// GetConfirmationsFromTxID returns confirmations given tx id
-func (w Wallet) GetConfirmationsFromTxID(txid string) (int64, error) {
-	result, err := w.getRawTransactionResult(txid)
+// GetTransactionConfirmations gets confirmations of wallet transaction, negative if it is conflicted
+func (w Wallet) GetTransactionConfirmations(txid string) (int64, error) {
+	transaction, err := w.getTransaction(txid)
 	if err != nil {
 		return 0, err
 	}
 
-	return int64(result.Confirmations), nil
+	return transaction.Confirmations, nil
+}
+
+func (w Wallet) getTransaction(txid string) (btcjson.GetTransactionResult, error) {
+	transaction := btcjson.GetTransactionResult{}
+	result, err := w.rawRequest("gettransaction", txid)
+	if code, ok := rpcErrorCode(err); ok && code == btcjson.ErrRPCInvalidAddressOrKey {
+		return transaction, jerrors.ErrNotFound
+	}
+
+	if err != nil {
+		return transaction, fmt.Errorf("core wallet get transaction error: %#v", err)
+	}
+
+	if err := json.Unmarshal(result, &transaction); err != nil {
+		return transaction, fmt.Errorf("core wallet get transaction unmarshal result error: %#v", err)
+	}
+
+	return transaction, nil
+}
+
+// CheckConflicted checks whether wallet transactions are conflicted in batched round-trips,
+// wallet reports negative confirmations for such transaction
+func (w Wallet) CheckConflicted(txids []string) ([]wallet.ConflictCheck, error) {
+	calls := make([]batchCall, len(txids))
+	for i, txid := range txids {
+		calls[i] = batchCall{method: "gettransaction", params: []interface{}{txid}}
+	}
+
+	results, err := w.batch.do(calls)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := make([]wallet.ConflictCheck, len(results))
+	for i, r := range results {
+		checks[i].TransactionID = txids[i]
+		if r.err != nil {
+			checks[i].Err = r.err
+			continue
+		}
+
+		result := btcjson.GetTransactionResult{}
+		if err := json.Unmarshal(r.result, &result); err != nil {
+			checks[i].Err = fmt.Errorf("core wallet get transaction unmarshal result error: %#v", err)
+			continue
+		}
+		checks[i].Conflicted = result.Confirmations < 0
+	}
+
+	return checks, nil
 }
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+func TestGetReceivedSince(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	genesis := f.blocks[0].BlockSha().String()
+	hash := f.addBlock(time.Unix(1468000600, 0))
+	f.addPrevout(txid(101), 1, []string{"sender1"}, []string{"sender2"})
+	f.addPrevout(txid(102), 2, []string{"sender3"})
+	f.addReceive(hash, txid(1), 0.29, btcjson.Vin{Txid: txid(101), Vout: 1})
+	f.addReceive(hash, txid(2), 1, btcjson.Vin{Txid: txid(102), Vout: 0}, btcjson.Vin{Txid: txid(101), Vout: 0})
+	f.addBlock(time.Unix(1468001200, 0))
+	w := f.wallet(f.username, f.password)
+
+	transactions, err := w.GetReceivedSince(genesis, hash)
+	if err != nil {
+		t.Fatalf("get received since expected no error but get %v", err)
+	}
+
+	if len(transactions) != 2 {
+		t.Fatalf("get received since expected 2 transactions but get %v", len(transactions))
+	}
+
+	// wallet lists transactions from the latest, they are returned in chronological order,
+	// multi-input transaction is attributed to the largest input
+	if tx := transactions[0]; tx.TransactionID != txid(2) || tx.Address != "sender3" || tx.Amount != 100000000 {
+		t.Errorf("multi-input transaction is unexpected %#v", tx)
+	}
+
+	if tx := transactions[1]; tx.TransactionID != txid(1) || tx.Address != "sender2" || tx.Amount != models.Amount(29000000) || tx.Confirmations != 2 || tx.Hash != hash {
+		t.Errorf("transaction is unexpected %#v", tx)
+	}
+
+	// listsinceblock, then transactions and their previous transactions in a batch each
+	if f.roundTrips != 3 {
+		t.Errorf("get received since expected 3 round-trips but get %v", f.roundTrips)
+	}
+}
+
+func TestGetReceivedSinceWithMultipleOutputs(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	genesis := f.blocks[0].BlockSha().String()
+	hash := f.addBlock(time.Unix(1468000600, 0))
+	f.addPrevout(txid(101), 2, []string{"sender1"})
+	f.addReceiveOutput(hash, txid(1), 0, "dest1", 0.5, btcjson.Vin{Txid: txid(101), Vout: 0})
+	f.addReceiveOutput(hash, txid(1), 1, "dest2", 1, btcjson.Vin{Txid: txid(101), Vout: 0})
+	f.addReceiveOutput(hash, txid(1), 1, "dest2", 1, btcjson.Vin{Txid: txid(101), Vout: 0})
+	w := f.wallet(f.username, f.password)
+
+	transactions, err := w.GetReceivedSince(genesis, hash)
+	if err != nil {
+		t.Fatalf("get received since expected no error but get %v", err)
+	}
+
+	if len(transactions) != 2 {
+		t.Fatalf("get received since expected 2 outputs but get %v", len(transactions))
+	}
+
+	for _, tx := range transactions {
+		if tx.Address != "sender1" || tx.OutputAddress != fmt.Sprintf("dest%d", tx.Vout+1) {
+			t.Errorf("output is unexpected %#v", tx)
+		}
+	}
+}
+
+func TestGetReceivedSinceWithUnattributedDeposits(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	genesis := f.blocks[0].BlockSha().String()
+	hash := f.addBlock(time.Unix(1468000600, 0))
+	f.addPrevout(txid(101), 1, []string{"multisig1", "multisig2"}, []string{})
+	f.addReceive(hash, txid(1), 1, btcjson.Vin{Txid: txid(103), Vout: 0})
+	f.addReceive(hash, txid(2), 1, btcjson.Vin{Coinbase: "03a08601"})
+	f.addReceive(hash, txid(3), 1, btcjson.Vin{Txid: txid(101), Vout: 0})
+	f.addReceive(hash, txid(4), 1, btcjson.Vin{Txid: txid(101), Vout: 1})
+	f.addReceive(hash, txid(5), 1, btcjson.Vin{Txid: txid(101), Vout: 2})
+	w := f.wallet(f.username, f.password)
+
+	transactions, err := w.GetReceivedSince(genesis, hash)
+	if err != nil {
+		t.Fatalf("get received since expected no error but get %v", err)
+	}
+
+	if len(transactions) != 5 {
+		t.Fatalf("get received since expected 5 transactions but get %v", len(transactions))
+	}
+
+	for _, tx := range transactions {
+		if tx.Address != "" || tx.UnattributedReason == "" {
+			t.Errorf("transaction %v expected to be unattributed but get %#v", tx.TransactionID, tx)
+		}
+	}
+}
+
+func TestGetReceivedSinceWithSingleAddressPolicy(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	genesis := f.blocks[0].BlockSha().String()
+	hash := f.addBlock(time.Unix(1468000600, 0))
+	f.addPrevout(txid(101), 1, []string{"sender1"}, []string{"sender1"}, []string{"sender2"})
+	f.addReceive(hash, txid(1), 1, btcjson.Vin{Txid: txid(101), Vout: 0}, btcjson.Vin{Txid: txid(101), Vout: 1})
+	f.addReceive(hash, txid(2), 1, btcjson.Vin{Txid: txid(101), Vout: 0}, btcjson.Vin{Txid: txid(101), Vout: 2})
+	w := f.walletWithSenderPolicy(f.username, f.password, wallet.SenderPolicySingleAddress)
+
+	transactions, err := w.GetReceivedSince(genesis, hash)
+	if err != nil {
+		t.Fatalf("get received since expected no error but get %v", err)
+	}
+
+	if tx := transactions[0]; tx.Address != "" || tx.UnattributedReason == "" {
+		t.Errorf("transaction from multiple addresses expected to be unattributed but get %#v", tx)
+	}
+
+	if tx := transactions[1]; tx.Address != "sender1" || tx.UnattributedReason != "" {
+		t.Errorf("transaction from single address expected to be attributed but get %#v", tx)
+	}
+}
+
+func TestGetReceivedSinceWithErroredPrevout(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	genesis := f.blocks[0].BlockSha().String()
+	hash := f.addBlock(time.Unix(1468000600, 0))
+	f.addReceive(hash, txid(1), 1, btcjson.Vin{Txid: txid(101), Vout: 0})
+	w := f.wallet(f.username, f.password)
+
+	f.failWith("getrawtransaction", btcjson.ErrRPCMisc, "internal error")
+	if _, err := w.GetReceivedSince(genesis, hash); err == nil {
+		t.Errorf("get received since with errored getrawtransaction expected error but get nil")
+	}
+}
+
+func TestGetReceivedSinceWithRPCError(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	genesis := f.blocks[0].BlockSha().String()
+	hash := f.addBlock(time.Unix(1468000600, 0))
+	w := f.wallet(f.username, f.password)
+
+	if _, err := w.GetReceivedSince("invalid hash", hash); err == nil {
+		t.Errorf("get received since with invalid hash expected error but get nil")
+	}
+
+	f.failWith("listsinceblock", btcjson.ErrRPCWallet, "wallet error")
+	if _, err := w.GetReceivedSince(genesis, hash); err == nil {
+		t.Errorf("get received since with errored listsinceblock expected error but get nil")
+	}
+}
+
+func TestGetMempoolReceived(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	hash := f.addBlock(time.Unix(1468000600, 0))
+	f.addPrevout(txid(101), 1, []string{"sender1"})
+	f.addReceive(hash, txid(1), 1, btcjson.Vin{Txid: txid(101), Vout: 0})
+	receivedAt := time.Unix(1468000900, 0)
+	f.addMempoolReceive(txid(2), 0.5, receivedAt, btcjson.Vin{Txid: txid(101), Vout: 0})
+	f.addMempoolReceive(txid(3), 0.5, receivedAt, btcjson.Vin{Coinbase: "03a08601"})
+	w := f.wallet(f.username, f.password)
+
+	transactions, err := w.GetMempoolReceived()
+	if err != nil {
+		t.Fatalf("get mempool received expected no error but get %v", err)
+	}
+
+	if len(transactions) != 2 {
+		t.Fatalf("get mempool received expected 2 transactions but get %v", len(transactions))
+	}
+
+	if tx := transactions[0]; tx.TransactionID != txid(2) || tx.Address != "sender1" || tx.Amount != 50000000 || !tx.BlockCreatedAt.Equal(receivedAt) {
+		t.Errorf("mempool transaction is unexpected %#v", tx)
+	}
+
+	if tx := transactions[1]; tx.TransactionID != txid(3) || tx.UnattributedReason == "" {
+		t.Errorf("unattributed mempool transaction is unexpected %#v", tx)
+	}
+}
+
+func TestGetTransactionConfirmations(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	w := f.wallet(f.username, f.password)
+
+	txid := sendTo(t, w, "winner", 29000000)
+	if confirmations, err := w.GetTransactionConfirmations(txid); err != nil || confirmations != 0 {
+		t.Errorf("confirmations of unmined transaction expected 0 but get %v, error: %v", confirmations, err)
+	}
+
+	f.mineSent(f.addBlock(time.Unix(1468000600, 0)))
+	f.addBlock(time.Unix(1468001200, 0))
+	if confirmations, err := w.GetTransactionConfirmations(txid); err != nil || confirmations != 2 {
+		t.Errorf("confirmations of mined transaction expected 2 but get %v, error: %v", confirmations, err)
+	}
+
+	if _, err := w.GetTransactionConfirmations(txid + "0"); err != jerrors.ErrNotFound {
+		t.Errorf("confirmations of unknown transaction expected %v but get %v", jerrors.ErrNotFound, err)
+	}
+}
+
+func TestCheckConflicted(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	hash := f.addBlock(time.Unix(1468000600, 0))
+	f.addReceive(hash, txid(1), 1)
+	f.addReceive(hash, txid(2), 1)
+	f.markConflicted(txid(2))
+	w := f.wallet(f.username, f.password)
+
+	checks, err := w.CheckConflicted([]string{txid(1), txid(2), txid(3)})
+	if err != nil || len(checks) != 3 {
+		t.Fatalf("conflict checks expected 3 but get %v, error: %v", len(checks), err)
+	}
+
+	if c := checks[0]; c.TransactionID != txid(1) || c.Err != nil || c.Conflicted {
+		t.Errorf("mined transaction expected not conflicted but get %#v", c)
+	}
+
+	if c := checks[1]; c.Err != nil || !c.Conflicted {
+		t.Errorf("double spent transaction expected conflicted but get %#v", c)
+	}
+
+	if code, ok := rpcErrorCode(checks[2].Err); !ok || code != btcjson.ErrRPCInvalidAddressOrKey {
+		t.Errorf("unknown transaction expected invalid address or key error but get %v", checks[2].Err)
+	}
+
+	if f.roundTrips != 1 {
+		t.Errorf("conflict checks expected 1 round-trip but get %v", f.roundTrips)
+	}
+}
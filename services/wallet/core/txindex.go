@@ -0,0 +1,29 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var errTxIndexDisabled = errors.New("bitcoind must run with -txindex so that senders of deposits can be resolved")
+
+// CheckTxIndex makes sure node indexes every transaction, senders of deposits are resolved from previous outputs
+// not in wallet, and without -txindex looking them up fails the same way as for outputs that do not exist
+func (w Wallet) CheckTxIndex() error {
+	result, err := w.rawRequest("getindexinfo", "txindex")
+	if err != nil {
+		return fmt.Errorf("core wallet get index info error: %#v", err)
+	}
+
+	indexes := map[string]json.RawMessage{}
+	if err := json.Unmarshal(result, &indexes); err != nil {
+		return fmt.Errorf("core wallet get index info unmarshal result error: %#v", err)
+	}
+
+	if _, ok := indexes["txindex"]; !ok {
+		return errTxIndexDisabled
+	}
+
+	return nil
+}
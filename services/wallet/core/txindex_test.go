@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+func TestCheckTxIndex(t *testing.T) {
+	f := newFakeBitcoind()
+	defer f.close()
+	f.addBlock(time.Unix(1468000600, 0))
+	w := f.wallet(f.username, f.password)
+
+	if err := w.CheckTxIndex(); err != nil {
+		t.Errorf("check txindex expected no error but get %v", err)
+	}
+
+	f.txIndex = false
+	if err := w.CheckTxIndex(); err != errTxIndexDisabled {
+		t.Errorf("check txindex without index expected %v but get %v", errTxIndexDisabled, err)
+	}
+
+	f.failWith("getindexinfo", btcjson.ErrRPCMethodNotFound.Code, "Method not found")
+	if err := w.CheckTxIndex(); err == nil || err == errTxIndexDisabled {
+		t.Errorf("check txindex on node without getindexinfo expected rpc error but get %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package esplora
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+type block struct {
+	ID                string `json:"id"`
+	Height            int64  `json:"height"`
+	PreviousBlockHash string `json:"previousblockhash"`
+	Timestamp         int64  `json:"timestamp"`
+	TxCount           int64  `json:"tx_count"`
+}
+
+// GetBlock get block
+func (w *Wallet) GetBlock(bestBlock bool, height int64) (*wallet.Block, error) {
+	h, err := w.GetBestHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	if bestBlock {
+		height = h
+	}
+
+	if height > h {
+		return nil, jerrors.ErrNoNewBlock
+	}
+
+	hash, err := w.get("/block-height/" + strconv.FormatInt(height, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	return w.GetBlockByHash(strings.TrimSpace(string(hash)))
+}
+
+// GetBestHeight returns height of the tip of main chain
+func (w *Wallet) GetBestHeight() (int64, error) {
+	body, err := w.get("/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+}
+
+// GetBlockByHash gets block known to esplora whether it is on main chain or not,
+// returns jerrors.ErrNotFound if esplora does not know the block
+func (w *Wallet) GetBlockByHash(hash string) (*wallet.Block, error) {
+	b, err := w.getBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet.Block{
+		Height:         b.Height,
+		PrevHash:       b.PreviousBlockHash,
+		Hash:           b.ID,
+		BlockCreatedAt: time.Unix(b.Timestamp, 0),
+	}, nil
+}
+
+func (w *Wallet) getBlock(hash string) (block, error) {
+	b := block{}
+	err := w.getJSON("/block/"+hash, &b)
+	return b, err
+}
@@ -0,0 +1,60 @@
+package esplora
+
+import (
+	"testing"
+	"time"
+
+	"github.com/solefaucet/jackpot-server/jerrors"
+)
+
+func TestGetBlock(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	genesis := f.blocks[0].ID
+	createdAt := time.Unix(1468000600, 0)
+	hash := f.addBlock(createdAt)
+	w := f.wallet(nil)
+
+	block, err := w.GetBlock(false, 1)
+	if err != nil {
+		t.Fatalf("get block expected no error but get %v", err)
+	}
+
+	if block.Height != 1 || block.Hash != hash || block.PrevHash != genesis || !block.BlockCreatedAt.Equal(createdAt) {
+		t.Errorf("get block is unexpected %#v", block)
+	}
+
+	if best, _ := w.GetBlock(true, 0); best == nil || best.Hash != hash {
+		t.Errorf("get best block expected %v but get %#v", hash, best)
+	}
+
+	if _, err := w.GetBlock(false, 2); err != jerrors.ErrNoNewBlock {
+		t.Errorf("get block ahead of tip expected %v but get %v", jerrors.ErrNoNewBlock, err)
+	}
+}
+
+func TestGetBestHeight(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	f.addBlock(time.Unix(1468000600, 0))
+	w := f.wallet(nil)
+
+	if height, err := w.GetBestHeight(); err != nil || height != 1 {
+		t.Errorf("best height expected 1 but get %v, error: %v", height, err)
+	}
+}
+
+func TestGetBlockByHash(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	w := f.wallet(nil)
+
+	if _, err := w.GetBlockByHash(hash(1)); err != jerrors.ErrNotFound {
+		t.Errorf("get unknown block expected %v but get %v", jerrors.ErrNotFound, err)
+	}
+
+	f.close()
+	if _, err := w.GetBlockByHash(f.blocks[0].ID); err == nil {
+		t.Errorf("get block from closed server expected error but get nil")
+	}
+}
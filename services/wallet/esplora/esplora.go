@@ -0,0 +1,207 @@
+package esplora
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// Signer sends payouts on behalf of esplora wallet, which watches addresses but holds no keys,
+// fee is estimated by signer as it funds payouts, and balance spendable by payouts is in signer,
+// transactions funded by signer are broadcast through esplora
+type Signer interface {
+	FundTransaction(amounts map[string]models.Amount, subtractFeeFrom []string, confTarget int64) (wallet.FundedTransaction, error)
+	ReleaseTransaction(rawTransaction string) error
+	EstimateFee(address string, amount models.Amount, confTarget int64) (models.Amount, error)
+	GetBalance() (models.Amount, error)
+}
+
+var errNoSigner = errors.New("esplora wallet has no signer to send coins")
+
+// Wallet implements Wallet interface on top of Esplora REST API, e.g. Blockstream Esplora or Electrs
+type Wallet struct {
+	baseURL        string
+	client         *http.Client
+	destAddress    string
+	addresses      []string // watched addresses, dest address first
+	senderPolicy   string
+	signer         Signer
+	mu             sync.Mutex
+	mempoolFirstAt map[string]time.Time  // esplora does not tell when a transaction enters mempool
+	inputs         map[string][]outpoint // inputs of transactions checked, esplora forgets transactions double spent
+}
+
+var _ wallet.Wallet = &Wallet{}
+
+// New create, deposits paid to destAddress and extraAddresses are watched,
+// senderPolicy is one of wallet.SenderPolicyLargestInput and wallet.SenderPolicySingleAddress,
+// payouts fail if signer is nil
+func New(baseURL, destAddress string, extraAddresses []string, senderPolicy string, signer Signer) *Wallet {
+	return &Wallet{
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		client:         &http.Client{Timeout: 30 * time.Second},
+		destAddress:    destAddress,
+		addresses:      append([]string{destAddress}, extraAddresses...),
+		senderPolicy:   senderPolicy,
+		signer:         signer,
+		mempoolFirstAt: make(map[string]time.Time),
+		inputs:         make(map[string][]outpoint),
+	}
+}
+
+// GetDestAddress gets destination address
+func (w *Wallet) GetDestAddress() (string, error) {
+	return w.destAddress, nil
+}
+
+// FundTransaction delegates funding and signing transaction to signer
+func (w *Wallet) FundTransaction(amounts map[string]models.Amount, subtractFeeFrom []string, confTarget int64) (wallet.FundedTransaction, error) {
+	if w.signer == nil {
+		return wallet.FundedTransaction{}, errNoSigner
+	}
+	return w.signer.FundTransaction(amounts, subtractFeeFrom, confTarget)
+}
+
+// ReleaseTransaction delegates releasing inputs of funded transaction dropped without being broadcast to signer
+func (w *Wallet) ReleaseTransaction(rawTransaction string) error {
+	if w.signer == nil {
+		return errNoSigner
+	}
+	return w.signer.ReleaseTransaction(rawTransaction)
+}
+
+// BroadcastTransaction broadcasts signed transaction, transaction already on chain counts as broadcast,
+// esplora relays the error of node which rejects it
+func (w *Wallet) BroadcastTransaction(rawTransaction string) error {
+	resp, err := w.client.Post(w.baseURL+"/tx", "text/plain", strings.NewReader(rawTransaction))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	code, message, relayed := relayedRPCError(body)
+	switch {
+	case relayed && code == rpcVerifyAlreadyInChain:
+		return nil
+	case relayed && permanentBroadcastErrorCodes[code] && !transientRejectReasons[message]:
+		return wallet.PermanentError{Err: fmt.Errorf("esplora broadcast transaction rejected: %s", body)}
+	}
+
+	return fmt.Errorf("esplora broadcast transaction responds status %v: %s", resp.StatusCode, body)
+}
+
+// bitcoind error codes esplora relays when node rejects broadcast transaction
+const (
+	rpcDeserialization      = -22
+	rpcVerify               = -25
+	rpcVerifyRejected       = -26
+	rpcVerifyAlreadyInChain = -27
+)
+
+// permanentBroadcastErrorCodes are codes of broadcast errors rebroadcasting the same transaction never fixes,
+// e.g. its inputs are spent by another one or it is rejected by policy of node
+var permanentBroadcastErrorCodes = map[int]bool{
+	rpcDeserialization: true,
+	rpcVerify:          true,
+	rpcVerifyRejected:  true,
+}
+
+// transientRejectReasons are reasons of rejection that go away as mempool of node drains
+var transientRejectReasons = map[string]bool{
+	"mempool min fee not met": true,
+	"mempool full":            true,
+}
+
+// relayedRPCError extracts bitcoind error esplora relays in body, e.g.
+// sendrawtransaction RPC error: {"code":-26,"message":"mempool full"}
+func relayedRPCError(body []byte) (code int, message string, ok bool) {
+	i := bytes.IndexByte(body, '{')
+	if i < 0 {
+		return 0, "", false
+	}
+
+	rpcErr := struct {
+		Code    *int   `json:"code"`
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(body[i:], &rpcErr) != nil || rpcErr.Code == nil {
+		return 0, "", false
+	}
+
+	// bitcoind appends details to some reasons, e.g. mempool min fee not met, 100 < 200
+	message = rpcErr.Message
+	if j := strings.Index(message, ","); j >= 0 {
+		message = message[:j]
+	}
+	return *rpcErr.Code, message, true
+}
+
+// EstimateFee delegates estimating network fee of payout to signer
+func (w *Wallet) EstimateFee(address string, amount models.Amount, confTarget int64) (models.Amount, error) {
+	if w.signer == nil {
+		return 0, errNoSigner
+	}
+	return w.signer.EstimateFee(address, amount, confTarget)
+}
+
+// GetBalance delegates getting balance spendable by payouts to signer
+func (w *Wallet) GetBalance() (models.Amount, error) {
+	if w.signer == nil {
+		return 0, errNoSigner
+	}
+	return w.signer.GetBalance()
+}
+
+// get requests path of esplora api, jerrors.ErrNotFound is returned if esplora responds 404
+func (w *Wallet) get(path string) ([]byte, error) {
+	resp, err := w.client.Get(w.baseURL + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, jerrors.ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("esplora get %v responds status %v: %s", path, resp.StatusCode, body)
+	}
+
+	return body, nil
+}
+
+func (w *Wallet) getJSON(path string, v interface{}) error {
+	body, err := w.get(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("esplora get %v unmarshal response error: %#v", path, err)
+	}
+	return nil
+}
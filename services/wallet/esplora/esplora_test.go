@@ -0,0 +1,334 @@
+package esplora
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// fakePageSize is small so that paging of block transactions is exercised
+const fakePageSize = 2
+
+// fakeEsplora is a local stand-in of esplora REST API with canned chain data
+type fakeEsplora struct {
+	mu     sync.Mutex
+	server *httptest.Server
+	blocks []block
+	txs    []transaction // in chronological order, mempool ones have unconfirmed status
+	pushed []string      // raw transactions broadcast
+}
+
+func newFakeEsplora() *fakeEsplora {
+	f := &fakeEsplora{}
+	f.addBlock(time.Unix(1468000000, 0))
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+func (f *fakeEsplora) close() {
+	f.server.Close()
+}
+
+// wallet returns esplora wallet watching dest and extra addresses
+func (f *fakeEsplora) wallet(signer Signer, extraAddresses ...string) *Wallet {
+	return New(f.server.URL+"/", "dest", extraAddresses, wallet.SenderPolicyLargestInput, signer)
+}
+
+func (f *fakeEsplora) addBlock(createdAt time.Time) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b := block{ID: hash(len(f.blocks) + 1000), Height: int64(len(f.blocks)), Timestamp: createdAt.Unix()}
+	if len(f.blocks) > 0 {
+		b.PreviousBlockHash = f.blocks[len(f.blocks)-1].ID
+	}
+	f.blocks = append(f.blocks, b)
+	return b.ID
+}
+
+// addTransaction adds transaction mined in block of blockHash, or to mempool if blockHash is empty
+func (f *fakeEsplora) addTransaction(blockHash string, tx transaction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, b := range f.blocks {
+		if b.ID == blockHash {
+			tx.Status = status{Confirmed: true, BlockHeight: b.Height, BlockHash: b.ID, BlockTime: b.Timestamp}
+		}
+	}
+	f.txs = append(f.txs, tx)
+}
+
+// removeTransaction drops transaction of txid as esplora does once it is double spent by one on main chain
+func (f *fakeEsplora) removeTransaction(txid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	txs := []transaction{}
+	for _, tx := range f.txs {
+		if tx.TxID != txid {
+			txs = append(txs, tx)
+		}
+	}
+	f.txs = txs
+}
+
+// hash returns a valid hash made of n
+func hash(n int) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+// pay returns output of value paid to address
+func pay(address string, value int64) output {
+	return output{ScriptPubKeyType: "p2pkh", ScriptPubKeyAddress: address, Value: value}
+}
+
+// spend returns input spending prevout
+func spend(prevout output) vin {
+	return vin{TxID: hash(1), Prevout: &prevout}
+}
+
+func (f *fakeEsplora) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method == "POST" && r.URL.Path == "/tx" {
+		body, _ := ioutil.ReadAll(r.Body)
+		status, text := f.push(string(body))
+		w.WriteHeader(status)
+		fmt.Fprint(w, text)
+		return
+	}
+
+	result, found := f.handle(strings.Split(strings.Trim(r.URL.Path, "/"), "/"))
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Not found")
+		return
+	}
+
+	if text, ok := result.(string); ok {
+		fmt.Fprint(w, text)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// push broadcasts raw transaction funded by fake signer, esplora relays errors of node with status 400
+func (f *fakeEsplora) push(rawTransaction string) (int, string) {
+	if !strings.HasPrefix(rawTransaction, "raw") {
+		return http.StatusBadRequest, `sendrawtransaction RPC error: {"code":-22,"message":"TX decode failed"}`
+	}
+
+	if strings.HasPrefix(rawTransaction, "rawlowfee") {
+		return http.StatusBadRequest, `sendrawtransaction RPC error: {"code":-26,"message":"mempool min fee not met, 100 < 200"}`
+	}
+
+	if strings.HasPrefix(rawTransaction, "rawnonstandard") {
+		return http.StatusBadRequest, `sendrawtransaction RPC error: {"code":-26,"message":"scriptpubkey"}`
+	}
+
+	for _, pushed := range f.pushed {
+		if pushed == rawTransaction {
+			return http.StatusBadRequest, `sendrawtransaction RPC error: {"code":-27,"message":"Transaction already in block chain"}`
+		}
+	}
+
+	f.pushed = append(f.pushed, rawTransaction)
+	return http.StatusOK, strings.TrimPrefix(rawTransaction, "raw")
+}
+
+func (f *fakeEsplora) handle(path []string) (interface{}, bool) {
+	switch {
+	case len(path) == 3 && path[0] == "blocks" && path[1] == "tip" && path[2] == "height":
+		return strconv.Itoa(len(f.blocks) - 1), true
+
+	case len(path) == 2 && path[0] == "block-height":
+		height, err := strconv.Atoi(path[1])
+		if err != nil || height < 0 || height >= len(f.blocks) {
+			return nil, false
+		}
+		return f.blocks[height].ID, true
+
+	case len(path) == 2 && path[0] == "block":
+		for _, b := range f.blocks {
+			if b.ID == path[1] {
+				b.TxCount = int64(len(f.blockTransactions(b.ID)))
+				return b, true
+			}
+		}
+		return nil, false
+
+	case len(path) == 4 && path[0] == "block" && path[2] == "txs":
+		txs := f.blockTransactions(path[1])
+		start, err := strconv.Atoi(path[3])
+		if err != nil || start < 0 || start >= len(txs) {
+			return nil, false
+		}
+		if end := start + fakePageSize; end < len(txs) {
+			txs = txs[:end]
+		}
+		return txs[start:], true
+
+	case len(path) == 4 && path[0] == "address" && path[2] == "txs" && path[3] == "mempool":
+		txs := []transaction{}
+		for i := len(f.txs) - 1; i >= 0; i-- {
+			if tx := f.txs[i]; !tx.Status.Confirmed && paysTo(tx, path[1]) {
+				txs = append(txs, tx)
+			}
+		}
+		return txs, true
+
+	case len(path) == 2 && path[0] == "tx":
+		for _, tx := range f.txs {
+			if tx.TxID == path[1] {
+				return tx, true
+			}
+		}
+		return nil, false
+
+	case len(path) == 4 && path[0] == "tx" && path[2] == "outspend":
+		for _, tx := range f.txs {
+			for _, in := range tx.Vin {
+				if in.TxID == path[1] && strconv.Itoa(int(in.Vout)) == path[3] {
+					return outspend{Spent: true, TxID: tx.TxID}, true
+				}
+			}
+		}
+		return outspend{}, true
+
+	case len(path) == 3 && path[0] == "tx" && path[2] == "status":
+		for _, tx := range f.txs {
+			if tx.TxID == path[1] {
+				return tx.Status, true
+			}
+		}
+		return nil, false
+	}
+
+	return nil, false
+}
+
+// blockTransactions returns transactions mined in block of blockHash in block order
+func (f *fakeEsplora) blockTransactions(blockHash string) []transaction {
+	txs := []transaction{}
+	for _, tx := range f.txs {
+		if tx.Status.Confirmed && tx.Status.BlockHash == blockHash {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+func paysTo(tx transaction, address string) bool {
+	for _, out := range tx.Vout {
+		if out.ScriptPubKeyAddress == address {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeSigner funds transactions at fixed fee and records released ones
+type fakeSigner struct {
+	funded   int
+	released []string
+}
+
+func (s *fakeSigner) FundTransaction(amounts map[string]models.Amount, subtractFeeFrom []string, confTarget int64) (wallet.FundedTransaction, error) {
+	s.funded++
+	tx := wallet.FundedTransaction{TransactionID: hash(s.funded + 3000), Fee: 22600}
+	tx.RawTransaction = "raw" + tx.TransactionID
+	for address, amount := range amounts {
+		tx.Outputs = append(tx.Outputs, wallet.SentOutput{TransactionID: tx.TransactionID, Vout: uint32(len(tx.Outputs)), Address: address, Amount: amount})
+	}
+	return tx, nil
+}
+
+func (s *fakeSigner) ReleaseTransaction(rawTransaction string) error {
+	s.released = append(s.released, rawTransaction)
+	return nil
+}
+
+func (s *fakeSigner) GetBalance() (models.Amount, error) {
+	return 100000000, nil
+}
+
+func (s *fakeSigner) EstimateFee(address string, amount models.Amount, confTarget int64) (models.Amount, error) {
+	return 22600, nil
+}
+
+func TestSend(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	signer := &fakeSigner{}
+	w := f.wallet(signer)
+
+	if fee, err := w.EstimateFee("winner", 29000000, 6); err != nil || fee != 22600 {
+		t.Errorf("estimate fee expected 22600 but get %v, error: %v", fee, err)
+	}
+
+	if _, err := f.wallet(nil).EstimateFee("winner", 29000000, 6); err != errNoSigner {
+		t.Errorf("estimate fee without signer expected %v but get %v", errNoSigner, err)
+	}
+
+	if balance, err := w.GetBalance(); err != nil || balance != 100000000 {
+		t.Errorf("balance expected 100000000 but get %v, error: %v", balance, err)
+	}
+
+	if _, err := f.wallet(nil).GetBalance(); err != errNoSigner {
+		t.Errorf("balance without signer expected %v but get %v", errNoSigner, err)
+	}
+
+	tx, err := w.FundTransaction(map[string]models.Amount{"winner": 29000000}, nil, 6)
+	if err != nil || tx.Fee != 22600 {
+		t.Fatalf("fund transaction expected fee 22600 but get %#v, error: %v", tx, err)
+	}
+
+	if _, err := f.wallet(nil).FundTransaction(map[string]models.Amount{"winner": 29000000}, nil, 6); err != errNoSigner {
+		t.Errorf("fund transaction without signer expected %v but get %v", errNoSigner, err)
+	}
+
+	if err := w.ReleaseTransaction(tx.RawTransaction); err != nil || len(signer.released) != 1 {
+		t.Errorf("release transaction expected delegated to signer but get %v, error: %v", signer.released, err)
+	}
+
+	if err := f.wallet(nil).ReleaseTransaction(tx.RawTransaction); err != errNoSigner {
+		t.Errorf("release transaction without signer expected %v but get %v", errNoSigner, err)
+	}
+
+	// transaction funded by signer is broadcast through esplora, broadcasting it again is harmless
+	if err := w.BroadcastTransaction(tx.RawTransaction); err != nil || len(f.pushed) != 1 {
+		t.Errorf("broadcast transaction expected pushed once but get %v, error: %v", f.pushed, err)
+	}
+
+	if err := w.BroadcastTransaction(tx.RawTransaction); err != nil || len(f.pushed) != 1 {
+		t.Errorf("broadcast transaction again expected no error but get %v", err)
+	}
+
+	if err := w.BroadcastTransaction("invalid"); !wallet.IsPermanent(err) {
+		t.Errorf("broadcast invalid transaction expected permanent error but get %v", err)
+	}
+
+	if err := w.BroadcastTransaction("rawnonstandard"); !wallet.IsPermanent(err) {
+		t.Errorf("broadcast transaction rejected by policy expected permanent error but get %v", err)
+	}
+
+	if err := w.BroadcastTransaction("rawlowfee"); err == nil || wallet.IsPermanent(err) {
+		t.Errorf("broadcast transaction below mempool min fee expected transient error but get %v", err)
+	}
+
+	if address, _ := w.GetDestAddress(); address != "dest" {
+		t.Errorf("dest address expected dest but get %v", address)
+	}
+}
@@ -0,0 +1,306 @@
+package esplora
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+type transaction struct {
+	TxID   string   `json:"txid"`
+	Vin    []vin    `json:"vin"`
+	Vout   []output `json:"vout"`
+	Fee    int64    `json:"fee"` // in satoshi
+	Status status   `json:"status"`
+}
+
+type vin struct {
+	TxID       string  `json:"txid"`
+	Vout       uint32  `json:"vout"`
+	Prevout    *output `json:"prevout"`
+	IsCoinbase bool    `json:"is_coinbase"`
+}
+
+type outpoint struct {
+	TxID string
+	Vout uint32
+}
+
+type outspend struct {
+	Spent bool   `json:"spent"`
+	TxID  string `json:"txid"`
+}
+
+type output struct {
+	ScriptPubKeyType    string `json:"scriptpubkey_type"`
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"`
+	Value               int64  `json:"value"` // in satoshi
+}
+
+type status struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+	BlockTime   int64  `json:"block_time"`
+}
+
+// GetReceivedSince returns outputs paid to watched addresses in block of curHash,
+// transactions of the block are paged through once and filtered by outputs, however many addresses are watched
+func (w *Wallet) GetReceivedSince(prevHash, curHash string) ([]wallet.Transaction, error) {
+	b, err := w.getBlock(curHash)
+	if err != nil {
+		return nil, err
+	}
+
+	tip, err := w.GetBestHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	txs, err := w.getBlockTransactions(curHash, b.TxCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []wallet.Transaction
+	for _, tx := range txs {
+		for _, t := range w.receivedOutputs(tx) {
+			t.Hash = curHash
+			t.Confirmations = tip - b.Height + 1
+			t.BlockCreatedAt = time.Unix(b.Timestamp, 0)
+			transactions = append(transactions, t)
+		}
+	}
+
+	return transactions, nil
+}
+
+// getBlockTransactions gets every transaction of block in block order,
+// esplora returns them in pages starting from index of the first one
+func (w *Wallet) getBlockTransactions(hash string, txCount int64) ([]transaction, error) {
+	txs := make([]transaction, 0, txCount)
+	for int64(len(txs)) < txCount {
+		page := []transaction{}
+		if err := w.getJSON("/block/"+hash+"/txs/"+strconv.Itoa(len(txs)), &page); err != nil {
+			return nil, err
+		}
+
+		if len(page) == 0 {
+			return nil, fmt.Errorf("esplora block %v lists %v of %v transactions", hash, len(txs), txCount)
+		}
+		txs = append(txs, page...)
+	}
+
+	return txs, nil
+}
+
+// GetMempoolReceived returns outputs paid to watched addresses not mined yet,
+// they are timed when the wallet first sees them
+func (w *Wallet) GetMempoolReceived() ([]wallet.Transaction, error) {
+	var txs []transaction
+	seen := make(map[string]bool)
+	for _, address := range w.addresses {
+		addressTxs := []transaction{}
+		if err := w.getJSON("/address/"+address+"/txs/mempool", &addressTxs); err != nil {
+			return nil, err
+		}
+
+		for _, tx := range addressTxs {
+			if !seen[tx.TxID] && !tx.Status.Confirmed {
+				seen[tx.TxID] = true
+				txs = append(txs, tx)
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	firstAt := make(map[string]time.Time, len(txs))
+	var transactions []wallet.Transaction
+	for _, tx := range txs {
+		firstAt[tx.TxID] = now
+		if t, ok := w.mempoolFirstAt[tx.TxID]; ok {
+			firstAt[tx.TxID] = t
+		}
+
+		for _, t := range w.receivedOutputs(tx) {
+			t.BlockCreatedAt = firstAt[tx.TxID]
+			transactions = append(transactions, t)
+		}
+	}
+	w.mempoolFirstAt = firstAt
+
+	return transactions, nil
+}
+
+// CheckConflicted tells whether transactions are conflicted, i.e. unknown to esplora, which forgets transactions
+// double spent by one on main chain, and with an input spent by another transaction.
+// Inputs are remembered from earlier checks, so transaction unknown since the wallet started fails to check
+func (w *Wallet) CheckConflicted(txids []string) ([]wallet.ConflictCheck, error) {
+	w.mu.Lock()
+	known := w.inputs
+	w.mu.Unlock()
+
+	inputs := make(map[string][]outpoint, len(txids))
+	checks := make([]wallet.ConflictCheck, len(txids))
+	for i, txid := range txids {
+		checks[i].TransactionID = txid
+		tx := transaction{}
+		err := w.getJSON("/tx/"+txid, &tx)
+		switch {
+		case err == nil:
+			inputs[txid] = outpointsOf(tx.Vin)
+		case err == jerrors.ErrNotFound && known[txid] == nil:
+			checks[i].Err = fmt.Errorf("esplora does not know transaction %v whose inputs are unknown", txid)
+		case err == jerrors.ErrNotFound:
+			inputs[txid] = known[txid]
+			checks[i].Conflicted, checks[i].Err = w.isDoubleSpent(txid, known[txid])
+		default:
+			inputs[txid] = known[txid]
+			checks[i].Err = err
+		}
+	}
+
+	w.mu.Lock()
+	w.inputs = inputs
+	w.mu.Unlock()
+
+	return checks, nil
+}
+
+// isDoubleSpent tells whether any of inputs of transaction txid is spent by another transaction
+func (w *Wallet) isDoubleSpent(txid string, inputs []outpoint) (bool, error) {
+	for _, in := range inputs {
+		spend := outspend{}
+		if err := w.getJSON(fmt.Sprintf("/tx/%v/outspend/%v", in.TxID, in.Vout), &spend); err != nil {
+			return false, err
+		}
+
+		if spend.Spent && spend.TxID != txid {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GetTransactionFee gets network fee paid by transaction
+func (w *Wallet) GetTransactionFee(txid string) (models.Amount, error) {
+	tx := transaction{}
+	if err := w.getJSON("/tx/"+txid, &tx); err != nil {
+		return 0, err
+	}
+
+	return models.Amount(tx.Fee), nil
+}
+
+// GetTransactionConfirmations gets confirmations of transaction, zero if it is in mempool
+func (w *Wallet) GetTransactionConfirmations(txid string) (int64, error) {
+	s := status{}
+	if err := w.getJSON("/tx/"+txid+"/status", &s); err != nil {
+		return 0, err
+	}
+
+	if !s.Confirmed {
+		return 0, nil
+	}
+
+	tip, err := w.GetBestHeight()
+	if err != nil {
+		return 0, err
+	}
+
+	return tip - s.BlockHeight + 1, nil
+}
+
+func outpointsOf(vins []vin) []outpoint {
+	outpoints := make([]outpoint, 0, len(vins))
+	for _, in := range vins {
+		if !in.IsCoinbase {
+			outpoints = append(outpoints, outpoint{TxID: in.TxID, Vout: in.Vout})
+		}
+	}
+	return outpoints
+}
+
+// receivedOutputs returns every output of tx paying to watched addresses, sender is resolved from prevouts
+func (w *Wallet) receivedOutputs(tx transaction) []wallet.Transaction {
+	var transactions []wallet.Transaction
+	for i, out := range tx.Vout {
+		if w.isWatched(out.ScriptPubKeyAddress) {
+			transactions = append(transactions, wallet.Transaction{
+				Amount:        models.Amount(out.Value),
+				TransactionID: tx.TxID,
+				Vout:          uint32(i),
+				OutputAddress: out.ScriptPubKeyAddress,
+			})
+		}
+	}
+
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	address, unattributedReason := w.senderAddress(tx.Vin)
+	for i := range transactions {
+		transactions[i].Address = address
+		transactions[i].UnattributedReason = unattributedReason
+	}
+	return transactions
+}
+
+func (w *Wallet) isWatched(address string) bool {
+	for _, v := range w.addresses {
+		if address == v {
+			return true
+		}
+	}
+	return false
+}
+
+// senderAddress resolves sender with sender policy of wallet from prevouts esplora embeds in inputs,
+// deposits that cannot be attributed to one address are returned with a non-empty reason
+func (w *Wallet) senderAddress(vins []vin) (address, unattributedReason string) {
+	if len(vins) == 0 {
+		return "", "transaction has no inputs"
+	}
+
+	for _, in := range vins {
+		if in.IsCoinbase {
+			return "", "coinbase input"
+		}
+
+		if in.Prevout == nil {
+			return "", "previous output not found"
+		}
+
+		if in.Prevout.ScriptPubKeyAddress == "" {
+			return "", fmt.Sprintf("previous output script %q has no address", in.Prevout.ScriptPubKeyType)
+		}
+	}
+
+	switch w.senderPolicy {
+	case wallet.SenderPolicySingleAddress:
+		for _, in := range vins {
+			if in.Prevout.ScriptPubKeyAddress != vins[0].Prevout.ScriptPubKeyAddress {
+				return "", "inputs are from multiple addresses"
+			}
+		}
+		return vins[0].Prevout.ScriptPubKeyAddress, ""
+
+	default:
+		largest := vins[0]
+		for _, in := range vins[1:] {
+			if in.Prevout.Value > largest.Prevout.Value {
+				largest = in
+			}
+		}
+		return largest.Prevout.ScriptPubKeyAddress, ""
+	}
+}
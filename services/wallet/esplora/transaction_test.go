@@ -0,0 +1,178 @@
+package esplora
+
+import (
+	"testing"
+	"time"
+
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+)
+
+func TestGetReceivedSince(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	genesis := f.blocks[0].ID
+	hash1 := f.addBlock(time.Unix(1468000600, 0))
+	f.addTransaction(hash1, transaction{TxID: hash(1), Vin: []vin{spend(pay("sender1", 100))}, Vout: []output{pay("dest", 29000000)}})
+	f.addTransaction(hash1, transaction{
+		TxID: hash(2),
+		Vin:  []vin{spend(pay("sender2", 100)), spend(pay("sender3", 200))},
+		Vout: []output{pay("change", 50), pay("dest", 100000000), pay("extra", 10)},
+	})
+	f.addTransaction(hash1, transaction{TxID: hash(5), Vin: []vin{spend(pay("sender1", 100))}, Vout: []output{pay("other", 1)}})
+	hash2 := f.addBlock(time.Unix(1468001200, 0))
+	f.addTransaction(hash2, transaction{TxID: hash(3), Vin: []vin{spend(pay("sender1", 100))}, Vout: []output{pay("dest", 1)}})
+	f.addTransaction(hash2, transaction{TxID: hash(4), Vin: []vin{spend(pay("sender1", 100))}, Vout: []output{pay("dest", 1)}})
+	w := f.wallet(nil, "extra")
+
+	// transactions of the block span several pages, those paying to no watched address are left out
+	transactions, err := w.GetReceivedSince(genesis, hash1)
+	if err != nil {
+		t.Fatalf("get received since expected no error but get %v", err)
+	}
+
+	if len(transactions) != 3 {
+		t.Fatalf("get received since expected 3 outputs but get %v", len(transactions))
+	}
+
+	if tx := transactions[0]; tx.TransactionID != hash(1) || tx.Address != "sender1" || tx.Amount != models.Amount(29000000) || tx.Confirmations != 2 || tx.Hash != hash1 || tx.OutputAddress != "dest" {
+		t.Errorf("transaction is unexpected %#v", tx)
+	}
+
+	// multi-input transaction is attributed to the largest input, outputs to every watched address are received
+	if tx := transactions[1]; tx.TransactionID != hash(2) || tx.Vout != 1 || tx.Address != "sender3" || tx.Amount != 100000000 {
+		t.Errorf("multi-input transaction is unexpected %#v", tx)
+	}
+
+	if tx := transactions[2]; tx.TransactionID != hash(2) || tx.Vout != 2 || tx.OutputAddress != "extra" {
+		t.Errorf("output paid to extra address is unexpected %#v", tx)
+	}
+}
+
+func TestGetReceivedSinceWithUnattributedSender(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	genesis := f.blocks[0].ID
+	hash1 := f.addBlock(time.Unix(1468000600, 0))
+	f.addTransaction(hash1, transaction{TxID: hash(1), Vin: []vin{{IsCoinbase: true}}, Vout: []output{pay("dest", 1)}})
+	f.addTransaction(hash1, transaction{TxID: hash(2), Vin: []vin{spend(output{ScriptPubKeyType: "multisig", Value: 100})}, Vout: []output{pay("dest", 1)}})
+	w := f.wallet(nil)
+
+	transactions, err := w.GetReceivedSince(genesis, hash1)
+	if err != nil || len(transactions) != 2 {
+		t.Fatalf("get received since expected 2 outputs but get %v, error: %v", len(transactions), err)
+	}
+
+	if tx := transactions[0]; tx.Address != "" || tx.UnattributedReason != "coinbase input" {
+		t.Errorf("coinbase transaction is unexpected %#v", tx)
+	}
+
+	if tx := transactions[1]; tx.Address != "" || tx.UnattributedReason != `previous output script "multisig" has no address` {
+		t.Errorf("multisig transaction is unexpected %#v", tx)
+	}
+}
+
+func TestGetMempoolReceived(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	f.addTransaction("", transaction{TxID: hash(1), Vin: []vin{spend(pay("sender1", 100))}, Vout: []output{pay("dest", 29000000)}})
+	w := f.wallet(nil)
+
+	transactions, err := w.GetMempoolReceived()
+	if err != nil || len(transactions) != 1 {
+		t.Fatalf("mempool received expected 1 output but get %v, error: %v", len(transactions), err)
+	}
+
+	if tx := transactions[0]; tx.TransactionID != hash(1) || tx.Address != "sender1" || tx.Amount != 29000000 || tx.Hash != "" {
+		t.Errorf("mempool transaction is unexpected %#v", tx)
+	}
+
+	// transaction keeps the time it is first seen
+	again, _ := w.GetMempoolReceived()
+	if !again[0].BlockCreatedAt.Equal(transactions[0].BlockCreatedAt) {
+		t.Errorf("mempool transaction received at expected %v but get %v", transactions[0].BlockCreatedAt, again[0].BlockCreatedAt)
+	}
+}
+
+func TestCheckConflicted(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	hash1 := f.addBlock(time.Unix(1468000600, 0))
+	f.addTransaction(hash1, transaction{TxID: hash(1), Vin: []vin{{TxID: hash(9), Vout: 0}}, Vout: []output{pay("dest", 1)}})
+	f.addTransaction(hash1, transaction{TxID: hash(2), Vin: []vin{{TxID: hash(9), Vout: 1}}, Vout: []output{pay("dest", 1)}})
+	w := f.wallet(nil)
+
+	checks, err := w.CheckConflicted([]string{hash(1), hash(2), hash(3)})
+	if err != nil || len(checks) != 3 {
+		t.Fatalf("conflict checks expected 3 but get %v, error: %v", len(checks), err)
+	}
+
+	if c := checks[0]; c.TransactionID != hash(1) || c.Err != nil || c.Conflicted {
+		t.Errorf("mined transaction expected not conflicted but get %#v", c)
+	}
+
+	if c := checks[2]; c.Err == nil || c.Conflicted {
+		t.Errorf("transaction unknown to esplora with unknown inputs expected error but get %#v", c)
+	}
+
+	// transaction 1 is double spent by transaction 4, transaction 2 is dropped with its inputs unspent
+	f.removeTransaction(hash(1))
+	f.removeTransaction(hash(2))
+	f.addTransaction("", transaction{TxID: hash(4), Vin: []vin{{TxID: hash(9), Vout: 0}}, Vout: []output{pay("other", 1)}})
+
+	checks, err = w.CheckConflicted([]string{hash(1), hash(2)})
+	if err != nil || len(checks) != 2 {
+		t.Fatalf("conflict checks expected 2 but get %v, error: %v", len(checks), err)
+	}
+
+	if c := checks[0]; c.Err != nil || !c.Conflicted {
+		t.Errorf("transaction double spent expected conflicted but get %#v", c)
+	}
+
+	if c := checks[1]; c.Err != nil || c.Conflicted {
+		t.Errorf("transaction dropped with inputs unspent expected not conflicted but get %#v", c)
+	}
+
+	f.close()
+	if checks, _ := w.CheckConflicted([]string{hash(1)}); checks[0].Err == nil {
+		t.Errorf("conflict check against closed server expected error but get nil")
+	}
+}
+
+func TestGetTransactionFee(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	hash1 := f.addBlock(time.Unix(1468000600, 0))
+	f.addTransaction(hash1, transaction{TxID: hash(1), Vout: []output{pay("winner", 29000000)}, Fee: 22600})
+	w := f.wallet(nil)
+
+	if fee, err := w.GetTransactionFee(hash(1)); err != nil || fee != 22600 {
+		t.Errorf("transaction fee expected 22600 but get %v, error: %v", fee, err)
+	}
+
+	if _, err := w.GetTransactionFee(hash(2)); err != jerrors.ErrNotFound {
+		t.Errorf("fee of unknown transaction expected %v but get %v", jerrors.ErrNotFound, err)
+	}
+}
+
+func TestGetTransactionConfirmations(t *testing.T) {
+	f := newFakeEsplora()
+	defer f.close()
+	hash1 := f.addBlock(time.Unix(1468000600, 0))
+	f.addTransaction(hash1, transaction{TxID: hash(1), Vout: []output{pay("winner", 29000000)}})
+	f.addTransaction("", transaction{TxID: hash(2), Vout: []output{pay("winner", 29000000)}})
+	f.addBlock(time.Unix(1468001200, 0))
+	w := f.wallet(nil)
+
+	if confirmations, err := w.GetTransactionConfirmations(hash(1)); err != nil || confirmations != 2 {
+		t.Errorf("confirmations of mined transaction expected 2 but get %v, error: %v", confirmations, err)
+	}
+
+	if confirmations, err := w.GetTransactionConfirmations(hash(2)); err != nil || confirmations != 0 {
+		t.Errorf("confirmations of mempool transaction expected 0 but get %v, error: %v", confirmations, err)
+	}
+
+	if _, err := w.GetTransactionConfirmations(hash(3)); err != jerrors.ErrNotFound {
+		t.Errorf("confirmations of unknown transaction expected %v but get %v", jerrors.ErrNotFound, err)
+	}
+}
@@ -0,0 +1,524 @@
+package simulated
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// Wallet implements Wallet interface on top of a scriptable in-memory chain,
+// it is meant for tests and running the service locally without a node
+type Wallet struct {
+	mu          sync.Mutex
+	destAddress string
+	blocks      []block
+	staleBlocks []block // disconnected by Reorganize, node still knows them by hash
+	mempool     []mempoolDeposit
+	sends       []Send
+	sendErr     error
+	fee         models.Amount
+	funds       models.Amount
+	nonce       int64
+	conflicted  map[string]bool
+
+	funded       map[string]wallet.FundedTransaction // by raw transaction, recorded as sends once broadcast
+	broadcastErr error
+
+	notifications chan wallet.BlockNotification
+}
+
+var (
+	_ wallet.Wallet   = &Wallet{}
+	_ wallet.Notifier = &Wallet{}
+)
+
+// Deposit is a transaction to destination address mined in a simulated block
+type Deposit struct {
+	Address       string
+	Amount        models.Amount
+	TransactionID string
+}
+
+// DefaultFee is network fee of every simulated send unless changed with SetFee
+const DefaultFee models.Amount = 10000
+
+// Send is a recorded output of transaction sent by BroadcastTransaction,
+// it is mined in the block following the send
+type Send struct {
+	Address       string
+	Amount        models.Amount
+	Fee           models.Amount
+	TransactionID string
+	Vout          uint32
+	Height        int64
+}
+
+type block struct {
+	wallet.Block
+	deposits []Deposit
+}
+
+type mempoolDeposit struct {
+	Deposit
+	receivedAt time.Time
+}
+
+// New creates a simulated chain with a genesis block created at genesisCreatedAt
+func New(destAddress string, genesisCreatedAt time.Time) *Wallet {
+	w := &Wallet{
+		destAddress:   destAddress,
+		fee:           DefaultFee,
+		conflicted:    map[string]bool{},
+		funded:        map[string]wallet.FundedTransaction{},
+		notifications: make(chan wallet.BlockNotification, 64),
+	}
+	w.blocks = []block{{Block: wallet.Block{Hash: w.newHash(), BlockCreatedAt: genesisCreatedAt}}}
+	return w
+}
+
+// AddBlock mines a block with deposits on top of the chain
+func (w *Wallet) AddBlock(createdAt time.Time, deposits ...Deposit) wallet.Block {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tip := w.blocks[len(w.blocks)-1]
+	b := block{
+		Block: wallet.Block{
+			Height:         tip.Height + 1,
+			PrevHash:       tip.Hash,
+			Hash:           w.newHash(),
+			BlockCreatedAt: createdAt,
+		},
+		deposits: make([]Deposit, len(deposits)),
+	}
+	for i, deposit := range deposits {
+		if deposit.TransactionID == "" {
+			deposit.TransactionID = w.newHash()
+		}
+		b.deposits[i] = deposit
+	}
+
+	w.blocks = append(w.blocks, b)
+	w.notify(wallet.BlockNotification{Height: b.Height, Hash: b.Hash, Connected: true})
+	return b.Block
+}
+
+// Reorganize disconnects the top depth blocks so that a competing branch can be added,
+// deposits of disconnected blocks are returned to be mined again if needed
+func (w *Wallet) Reorganize(depth int) []Deposit {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if depth >= len(w.blocks) {
+		depth = len(w.blocks) - 1
+	}
+
+	var deposits []Deposit
+	for i := len(w.blocks) - 1; i >= len(w.blocks)-depth; i-- {
+		b := w.blocks[i]
+		deposits = append(b.deposits, deposits...)
+		w.notify(wallet.BlockNotification{Height: b.Height, Hash: b.Hash, Connected: false})
+	}
+	w.staleBlocks = append(w.staleBlocks, w.blocks[len(w.blocks)-depth:]...)
+	w.blocks = w.blocks[:len(w.blocks)-depth]
+	return deposits
+}
+
+// DoubleSpend makes deposit conflicted, e.g. deposit of orphaned block replaced by another spending the same inputs,
+// it is no longer conflicted once mined on main chain
+func (w *Wallet) DoubleSpend(txid string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.conflicted[txid] = true
+}
+
+// AddMempoolDeposit broadcasts a deposit that stays unconfirmed until MineMempool is called
+func (w *Wallet) AddMempoolDeposit(deposit Deposit) Deposit {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if deposit.TransactionID == "" {
+		deposit.TransactionID = w.newHash()
+	}
+	w.mempool = append(w.mempool, mempoolDeposit{Deposit: deposit, receivedAt: time.Now()})
+	return deposit
+}
+
+// MineMempool mines every mempool deposit in a new block
+func (w *Wallet) MineMempool(createdAt time.Time) wallet.Block {
+	w.mu.Lock()
+	deposits := make([]Deposit, len(w.mempool))
+	for i, deposit := range w.mempool {
+		deposits[i] = deposit.Deposit
+	}
+	w.mempool = nil
+	w.mu.Unlock()
+
+	return w.AddBlock(createdAt, deposits...)
+}
+
+// Mine adds an empty block every interval until stop is closed
+func (w *Wallet) Mine(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			w.AddBlock(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// BlockNotifications returns channel of blocks connected and disconnected,
+// notifications are dropped when nobody consumes them
+func (w *Wallet) BlockNotifications() <-chan wallet.BlockNotification {
+	return w.notifications
+}
+
+// Sends returns all recorded payouts
+func (w *Wallet) Sends() []Send {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]Send(nil), w.sends...)
+}
+
+// SetSendError makes following fundings fail with err, nil restores funding
+func (w *Wallet) SetSendError(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.sendErr = err
+}
+
+// SetBroadcastError makes following broadcasts fail with err, nil restores broadcasting
+func (w *Wallet) SetBroadcastError(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.broadcastErr = err
+}
+
+// SetFee sets network fee of following sends and estimates
+func (w *Wallet) SetFee(fee models.Amount) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fee = fee
+}
+
+// Fund adds amount to balance on top of mined deposits, e.g. house money topped up outside of the chain
+func (w *Wallet) Fund(amount models.Amount) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.funds += amount
+}
+
+// GetBlock get block
+func (w *Wallet) GetBlock(bestBlock bool, height int64) (*wallet.Block, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tip := int64(len(w.blocks) - 1)
+	if bestBlock {
+		height = tip
+	}
+
+	if height > tip {
+		return nil, jerrors.ErrNoNewBlock
+	}
+
+	if height < 0 {
+		return nil, fmt.Errorf("simulated wallet block height %v out of range", height)
+	}
+
+	b := w.blocks[height].Block
+	return &b, nil
+}
+
+// GetBestHeight returns height of the tip
+func (w *Wallet) GetBestHeight() (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return int64(len(w.blocks) - 1), nil
+}
+
+// GetBlockByHash gets block on the chain, disconnected blocks are forgotten
+func (w *Wallet) GetBlockByHash(hash string) (*wallet.Block, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, blocks := range [][]block{w.blocks, w.staleBlocks} {
+		for _, b := range blocks {
+			if b.Hash == hash {
+				block := b.Block
+				return &block, nil
+			}
+		}
+	}
+
+	return nil, jerrors.ErrNotFound
+}
+
+// GetReceivedSince returns transactions in block curHash
+func (w *Wallet) GetReceivedSince(prevHash, curHash string) ([]wallet.Transaction, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, b := range w.blocks {
+		if b.Hash != curHash {
+			continue
+		}
+
+		transactions := make([]wallet.Transaction, len(b.deposits))
+		for i, deposit := range b.deposits {
+			transactions[i] = wallet.Transaction{
+				Address:        deposit.Address,
+				Amount:         deposit.Amount,
+				TransactionID:  deposit.TransactionID,
+				OutputAddress:  w.destAddress,
+				Hash:           b.Hash,
+				Confirmations:  w.confirmations(b.Height),
+				BlockCreatedAt: b.BlockCreatedAt,
+			}
+		}
+		return transactions, nil
+	}
+
+	return nil, fmt.Errorf("simulated wallet block %v not found", curHash)
+}
+
+// GetMempoolReceived returns deposits not mined yet
+func (w *Wallet) GetMempoolReceived() ([]wallet.Transaction, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	transactions := make([]wallet.Transaction, len(w.mempool))
+	for i, deposit := range w.mempool {
+		transactions[i] = wallet.Transaction{
+			Address:        deposit.Address,
+			Amount:         deposit.Amount,
+			TransactionID:  deposit.TransactionID,
+			OutputAddress:  w.destAddress,
+			BlockCreatedAt: deposit.receivedAt,
+		}
+	}
+	return transactions, nil
+}
+
+// FundTransaction funds transaction paying amounts with simulated fee, which is taken evenly out of outputs
+// to subtractFeeFrom with the remainder from the first of them, outputs are in order of addresses
+func (w *Wallet) FundTransaction(amounts map[string]models.Amount, subtractFeeFrom []string, confTarget int64) (wallet.FundedTransaction, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.sendErr != nil {
+		return wallet.FundedTransaction{}, w.sendErr
+	}
+
+	addresses := make([]string, 0, len(amounts))
+	for address := range amounts {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	subtract := make(map[string]bool, len(subtractFeeFrom))
+	for _, address := range subtractFeeFrom {
+		subtract[address] = true
+	}
+
+	txid := w.newHash()
+	tx := wallet.FundedTransaction{TransactionID: txid, RawTransaction: "simulated raw transaction " + txid, Fee: w.fee}
+	remainder := models.Amount(0)
+	if len(subtractFeeFrom) > 0 {
+		remainder = w.fee % models.Amount(len(subtractFeeFrom))
+	}
+	for i, address := range addresses {
+		amount := amounts[address]
+		if subtract[address] {
+			amount -= w.fee/models.Amount(len(subtractFeeFrom)) + remainder
+			remainder = 0
+		}
+
+		if amount <= 0 {
+			return wallet.FundedTransaction{}, fmt.Errorf("simulated wallet amount to %v is too small to pay the fee", address)
+		}
+		tx.Outputs = append(tx.Outputs, wallet.SentOutput{TransactionID: txid, Vout: uint32(i), Address: address, Amount: amount})
+	}
+
+	w.funded[tx.RawTransaction] = tx
+	return tx, nil
+}
+
+// BroadcastTransaction records every output of funded transaction as a send, broadcasting it again is harmless
+func (w *Wallet) BroadcastTransaction(rawTransaction string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.broadcastErr != nil {
+		return w.broadcastErr
+	}
+
+	tx, ok := w.funded[rawTransaction]
+	if !ok {
+		return wallet.PermanentError{Err: fmt.Errorf("simulated wallet raw transaction %q is not funded", rawTransaction)}
+	}
+
+	for _, send := range w.sends {
+		if send.TransactionID == tx.TransactionID {
+			return nil
+		}
+	}
+
+	for _, output := range tx.Outputs {
+		w.sends = append(w.sends, Send{
+			Address:       output.Address,
+			Amount:        output.Amount,
+			Fee:           tx.Fee,
+			TransactionID: tx.TransactionID,
+			Vout:          output.Vout,
+			Height:        int64(len(w.blocks)),
+		})
+	}
+	return nil
+}
+
+// ReleaseTransaction forgets funded transaction that is not broadcast, broadcasting it afterwards fails
+func (w *Wallet) ReleaseTransaction(rawTransaction string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tx, ok := w.funded[rawTransaction]
+	if !ok {
+		return nil
+	}
+
+	for _, send := range w.sends {
+		if send.TransactionID == tx.TransactionID {
+			return nil
+		}
+	}
+
+	delete(w.funded, rawTransaction)
+	return nil
+}
+
+// EstimateFee returns network fee simulated sends pay
+func (w *Wallet) EstimateFee(address string, amount models.Amount, confTarget int64) (models.Amount, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.fee, nil
+}
+
+// GetTransactionFee gets network fee paid by recorded send
+func (w *Wallet) GetTransactionFee(txid string) (models.Amount, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, send := range w.sends {
+		if send.TransactionID == txid {
+			return send.Fee, nil
+		}
+	}
+
+	return 0, jerrors.ErrNotFound
+}
+
+// GetTransactionConfirmations gets confirmations of recorded send
+func (w *Wallet) GetTransactionConfirmations(txid string) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, send := range w.sends {
+		if send.TransactionID == txid {
+			// chain reorganized below the send leaves it unmined
+			if confirmations := w.confirmations(send.Height); confirmations > 0 {
+				return confirmations, nil
+			}
+			return 0, nil
+		}
+	}
+
+	return 0, jerrors.ErrNotFound
+}
+
+// GetBalance gets funds plus deposits mined on main chain minus recorded sends and their fees,
+// fee of a send to many addresses is paid once
+func (w *Wallet) GetBalance() (models.Amount, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	balance := w.funds
+	for _, b := range w.blocks {
+		for _, deposit := range b.deposits {
+			balance += deposit.Amount
+		}
+	}
+
+	feePaid := map[string]bool{}
+	for _, send := range w.sends {
+		balance -= send.Amount
+		if !feePaid[send.TransactionID] {
+			balance -= send.Fee
+			feePaid[send.TransactionID] = true
+		}
+	}
+
+	return balance, nil
+}
+
+// GetDestAddress gets destination address
+func (w *Wallet) GetDestAddress() (string, error) {
+	return w.destAddress, nil
+}
+
+// CheckConflicted tells whether transactions are double spent and not on main chain
+func (w *Wallet) CheckConflicted(txids []string) ([]wallet.ConflictCheck, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	mined := make(map[string]bool)
+	for _, b := range w.blocks {
+		for _, deposit := range b.deposits {
+			mined[deposit.TransactionID] = true
+		}
+	}
+
+	checks := make([]wallet.ConflictCheck, len(txids))
+	for i, txid := range txids {
+		checks[i] = wallet.ConflictCheck{TransactionID: txid, Conflicted: w.conflicted[txid] && !mined[txid]}
+	}
+
+	return checks, nil
+}
+
+func (w *Wallet) notify(notification wallet.BlockNotification) {
+	select {
+	case w.notifications <- notification:
+	default:
+	}
+}
+
+func (w *Wallet) confirmations(height int64) int64 {
+	return int64(len(w.blocks)) - height
+}
+
+func (w *Wallet) newHash() string {
+	w.nonce++
+	sum := sha256.Sum256([]byte(fmt.Sprintf("simulated %v %v", w.nonce, time.Now().UnixNano())))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,291 @@
+package simulated
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+	"github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+func TestGetBlock(t *testing.T) {
+	now := time.Now()
+	w := New("dest", now)
+	b1 := w.AddBlock(now.Add(time.Minute))
+	b2 := w.AddBlock(now.Add(2 * time.Minute))
+
+	if b2.PrevHash != b1.Hash || b2.Height != 2 {
+		t.Errorf("block 2 expected to extend block 1 but get %#v", b2)
+	}
+
+	best, err := w.GetBlock(true, 0)
+	if err != nil || best.Hash != b2.Hash {
+		t.Errorf("best block expected %v but get %#v, error: %v", b2.Hash, best, err)
+	}
+
+	if block, err := w.GetBlockByHash(b1.Hash); err != nil || block.Height != 1 {
+		t.Errorf("block by hash expected height 1 but get %#v, error: %v", block, err)
+	}
+
+	if _, err := w.GetBlockByHash("unknown"); err != jerrors.ErrNotFound {
+		t.Errorf("get unknown block expected %v but get %v", jerrors.ErrNotFound, err)
+	}
+
+	if height, _ := w.GetBestHeight(); height != 2 {
+		t.Errorf("best height expected 2 but get %v", height)
+	}
+
+	if _, err := w.GetBlock(false, 3); err != jerrors.ErrNoNewBlock {
+		t.Errorf("get block ahead of tip expected %v but get %v", jerrors.ErrNoNewBlock, err)
+	}
+}
+
+func TestGetReceivedSince(t *testing.T) {
+	now := time.Now()
+	w := New("dest", now)
+	b1 := w.AddBlock(now, Deposit{Address: "a1", Amount: 100}, Deposit{Address: "a2", Amount: 200, TransactionID: "tx2"})
+	w.AddBlock(now)
+
+	transactions, err := w.GetReceivedSince(b1.PrevHash, b1.Hash)
+	if err != nil || len(transactions) != 2 {
+		t.Fatalf("received transactions expected 2 but get %v, error: %v", len(transactions), err)
+	}
+
+	if tx := transactions[1]; tx.Address != "a2" || tx.Amount != 200 || tx.TransactionID != "tx2" || tx.Confirmations != 2 {
+		t.Errorf("received transaction is unexpected %#v", tx)
+	}
+}
+
+func TestCheckConflicted(t *testing.T) {
+	now := time.Now()
+	w := New("dest", now)
+	w.AddBlock(now, Deposit{Address: "a1", Amount: 100, TransactionID: "tx1"})
+	w.DoubleSpend("tx1")
+
+	if checks, _ := w.CheckConflicted([]string{"tx1"}); checks[0].Conflicted {
+		t.Errorf("deposit on main chain expected not conflicted")
+	}
+
+	w.Reorganize(1)
+	checks, _ := w.CheckConflicted([]string{"tx1", "tx2"})
+	if !checks[0].Conflicted {
+		t.Errorf("double spent deposit of orphaned block expected conflicted")
+	}
+
+	if checks[1].Conflicted {
+		t.Errorf("unknown deposit expected not conflicted")
+	}
+}
+
+func TestReorganize(t *testing.T) {
+	now := time.Now()
+	w := New("dest", now)
+	w.AddBlock(now)
+	orphaned := w.AddBlock(now, Deposit{Address: "a1", Amount: 100})
+
+	deposits := w.Reorganize(1)
+	if len(deposits) != 1 || deposits[0].Address != "a1" {
+		t.Errorf("disconnected deposits expected a1 but get %#v", deposits)
+	}
+
+	replaced := w.AddBlock(now)
+	if replaced.Height != orphaned.Height || replaced.Hash == orphaned.Hash {
+		t.Errorf("replaced block expected a different hash at height %v but get %#v", orphaned.Height, replaced)
+	}
+
+	if _, err := w.GetReceivedSince(orphaned.PrevHash, orphaned.Hash); err == nil {
+		t.Errorf("get received transactions of orphaned block expected error but get nil")
+	}
+
+	// node still knows blocks of stale fork by hash
+	if block, err := w.GetBlockByHash(orphaned.Hash); err != nil || block.Hash != orphaned.Hash {
+		t.Errorf("get orphaned block by hash expected %v but get %#v, error: %v", orphaned.Hash, block, err)
+	}
+}
+
+func TestMempool(t *testing.T) {
+	now := time.Now()
+	w := New("dest", now)
+	deposit := w.AddMempoolDeposit(Deposit{Address: "a1", Amount: 100})
+
+	if transactions, _ := w.GetMempoolReceived(); len(transactions) != 1 || transactions[0].TransactionID != deposit.TransactionID {
+		t.Errorf("mempool expected deposit %v but get %#v", deposit.TransactionID, transactions)
+	}
+
+	b := w.MineMempool(now)
+	if transactions, _ := w.GetMempoolReceived(); len(transactions) != 0 {
+		t.Errorf("mempool expected to be empty after mining but get %#v", transactions)
+	}
+
+	if transactions, _ := w.GetReceivedSince(b.PrevHash, b.Hash); len(transactions) != 1 || transactions[0].TransactionID != deposit.TransactionID {
+		t.Errorf("mined block expected deposit %v but get %#v", deposit.TransactionID, transactions)
+	}
+}
+
+func TestBlockNotifications(t *testing.T) {
+	now := time.Now()
+	w := New("dest", now)
+	b1 := w.AddBlock(now)
+	w.Reorganize(1)
+
+	expected := []wallet.BlockNotification{
+		{Height: 1, Hash: b1.Hash, Connected: true},
+		{Height: 1, Hash: b1.Hash, Connected: false},
+	}
+	for _, e := range expected {
+		if actual := <-w.BlockNotifications(); actual != e {
+			t.Errorf("block notification expected %#v but get %#v", e, actual)
+		}
+	}
+}
+
+func TestSendError(t *testing.T) {
+	w := New("dest", time.Now())
+
+	w.SetSendError(errors.New("insufficient funds"))
+	if _, err := w.FundTransaction(map[string]models.Amount{"winner": 100}, nil, 6); err == nil {
+		t.Errorf("fund transaction expected error but get nil")
+	}
+
+	w.SetSendError(nil)
+	txid := send(w, "winner", 100)
+	if sends := w.Sends(); len(sends) != 1 || sends[0].TransactionID != txid || sends[0].Address != "winner" || sends[0].Amount != 100 {
+		t.Errorf("recorded sends are unexpected %#v", sends)
+	}
+}
+
+func TestFundTransaction(t *testing.T) {
+	w := New("dest", time.Now())
+	w.SetFee(10001)
+
+	tx, err := w.FundTransaction(map[string]models.Amount{"winner2": 200000, "winner1": 100000}, []string{"winner1", "winner2"}, 6)
+	if err != nil {
+		t.Fatalf("fund transaction expected no error but get %v", err)
+	}
+
+	// fee is split evenly, the first output subtracting fee pays the remainder
+	expected := []wallet.SentOutput{
+		{TransactionID: tx.TransactionID, Vout: 0, Address: "winner1", Amount: 94999},
+		{TransactionID: tx.TransactionID, Vout: 1, Address: "winner2", Amount: 195000},
+	}
+	if tx.Fee != 10001 || !reflect.DeepEqual(tx.Outputs, expected) {
+		t.Errorf("funded transaction is unexpected %#v", tx)
+	}
+
+	if len(w.Sends()) != 0 {
+		t.Errorf("funded transaction expected not sent before broadcast")
+	}
+
+	w.SetBroadcastError(errors.New("node is not connected"))
+	if err := w.BroadcastTransaction(tx.RawTransaction); err == nil {
+		t.Errorf("broadcast expected error but get nil")
+	}
+
+	// broadcasting again is harmless
+	w.SetBroadcastError(nil)
+	w.BroadcastTransaction(tx.RawTransaction)
+	if err := w.BroadcastTransaction(tx.RawTransaction); err != nil {
+		t.Errorf("broadcast again expected no error but get %v", err)
+	}
+
+	if fee, _ := w.GetTransactionFee(tx.TransactionID); fee != 10001 || len(w.Sends()) != 2 {
+		t.Errorf("broadcast transaction expected sent once with fee 10001 but get %#v", w.Sends())
+	}
+
+	if err := w.BroadcastTransaction("unknown"); !wallet.IsPermanent(err) {
+		t.Errorf("broadcast unknown transaction expected permanent error but get %v", err)
+	}
+
+	// released transaction can not be broadcast, broadcast one is kept
+	released, _ := w.FundTransaction(map[string]models.Amount{"winner3": 100000}, nil, 6)
+	w.ReleaseTransaction(released.RawTransaction)
+	if err := w.BroadcastTransaction(released.RawTransaction); !wallet.IsPermanent(err) {
+		t.Errorf("broadcast released transaction expected permanent error but get %v", err)
+	}
+
+	w.ReleaseTransaction(tx.RawTransaction)
+	if err := w.BroadcastTransaction(tx.RawTransaction); err != nil {
+		t.Errorf("release broadcast transaction expected kept but get %v", err)
+	}
+
+	if _, err := w.FundTransaction(map[string]models.Amount{"winner1": 10001}, []string{"winner1"}, 6); err == nil {
+		t.Errorf("fund transaction with amount too small to pay fee expected error but get nil")
+	}
+}
+
+func TestFee(t *testing.T) {
+	w := New("dest", time.Now())
+
+	if fee, _ := w.EstimateFee("winner", 100000, 6); fee != DefaultFee {
+		t.Errorf("estimate fee expected %v but get %v", DefaultFee, fee)
+	}
+
+	w.SetFee(2500)
+	if fee, _ := w.EstimateFee("winner", 100000, 6); fee != 2500 {
+		t.Errorf("estimate fee expected 2500 but get %v", fee)
+	}
+
+	txid := send(w, "winner", 100000)
+	if fee, err := w.GetTransactionFee(txid); err != nil || fee != 2500 {
+		t.Errorf("transaction fee expected 2500 but get %v, error: %v", fee, err)
+	}
+
+	if _, err := w.GetTransactionFee("unknown"); err != jerrors.ErrNotFound {
+		t.Errorf("unknown transaction fee expected %v but get %v", jerrors.ErrNotFound, err)
+	}
+}
+
+func TestGetTransactionConfirmations(t *testing.T) {
+	w := New("dest", time.Now())
+	txid := send(w, "winner", 100000)
+
+	if confirmations, err := w.GetTransactionConfirmations(txid); err != nil || confirmations != 0 {
+		t.Errorf("confirmations of unmined send expected 0 but get %v, error: %v", confirmations, err)
+	}
+
+	w.AddBlock(time.Now())
+	w.AddBlock(time.Now())
+	if confirmations, err := w.GetTransactionConfirmations(txid); err != nil || confirmations != 2 {
+		t.Errorf("confirmations of mined send expected 2 but get %v, error: %v", confirmations, err)
+	}
+
+	if _, err := w.GetTransactionConfirmations("unknown"); err != jerrors.ErrNotFound {
+		t.Errorf("confirmations of unknown send expected %v but get %v", jerrors.ErrNotFound, err)
+	}
+}
+
+func TestGetBalance(t *testing.T) {
+	w := New("dest", time.Now())
+	w.SetFee(1000)
+	w.Fund(500000)
+	w.AddBlock(time.Now(), Deposit{Address: "dest", Amount: 200000})
+	w.AddMempoolDeposit(Deposit{Address: "dest", Amount: 300000})
+
+	if balance, err := w.GetBalance(); err != nil || balance != 700000 {
+		t.Errorf("balance expected 700000 but get %v, error: %v", balance, err)
+	}
+
+	send(w, "winner", 100000)
+	funded, _ := w.FundTransaction(map[string]models.Amount{"a": 10000, "b": 20000}, nil, 6)
+	w.BroadcastTransaction(funded.RawTransaction)
+
+	// fee of send to many is paid once
+	if balance, _ := w.GetBalance(); balance != 568000 {
+		t.Errorf("balance after sends expected 568000 but get %v", balance)
+	}
+
+	w.Reorganize(1)
+	if balance, _ := w.GetBalance(); balance != 368000 {
+		t.Errorf("balance after deposit reorganized out expected 368000 but get %v", balance)
+	}
+}
+
+// send funds and broadcasts transaction paying amount to address with fee taken on top, as refunds are sent
+func send(w *Wallet, address string, amount models.Amount) string {
+	tx, _ := w.FundTransaction(map[string]models.Amount{address: amount}, nil, 6)
+	w.BroadcastTransaction(tx.RawTransaction)
+	return tx.TransactionID
+}
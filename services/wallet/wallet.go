@@ -1,14 +1,27 @@
 package wallet
 
-import "time"
+import (
+	"time"
+
+	"github.com/solefaucet/jackpot-server/models"
+)
 
 // Wallet defines interface that one should implement for blockchain manipulation
 type Wallet interface {
 	GetBlock(bestBlock bool, height int64) (*Block, error)
+	GetBestHeight() (int64, error)
+	GetBlockByHash(hash string) (*Block, error)
 	GetReceivedSince(prevHash, curHash string) ([]Transaction, error)
-	SendFromAccountToAddress(account, address string, amount float64) (string, error)
+	GetMempoolReceived() ([]Transaction, error)
+	FundTransaction(amounts map[string]models.Amount, subtractFeeFrom []string, confTarget int64) (FundedTransaction, error)
+	BroadcastTransaction(rawTransaction string) error
+	ReleaseTransaction(rawTransaction string) error
+	GetBalance() (models.Amount, error) // of the whole wallet, which every transaction is funded from
+	EstimateFee(address string, amount models.Amount, confTarget int64) (models.Amount, error)
+	GetTransactionFee(txid string) (models.Amount, error)
+	GetTransactionConfirmations(txid string) (int64, error) // jerrors.ErrNotFound if wallet does not know the transaction
 	GetDestAddress() (string, error)
-	GetConfirmationsFromTxID(txID string) (int64, error)
+	CheckConflicted(txIDs []string) ([]ConflictCheck, error)
 }
 
 // Block _
@@ -19,12 +32,85 @@ type Block struct {
 	BlockCreatedAt time.Time
 }
 
-// Transaction _
+// sender policies decide which address a deposit with several inputs is attributed to
+const (
+	SenderPolicyLargestInput  = "largest_input"
+	SenderPolicySingleAddress = "single_address"
+)
+
+// Transaction _, every output paying to wallet is a transaction identified by (TransactionID, Vout)
 type Transaction struct {
-	Address        string
-	Amount         float64
+	Address            string
+	UnattributedReason string // why sender address cannot be resolved, empty if Address is resolved
+	Amount             models.Amount
+	TransactionID      string
+	Vout               uint32
+	OutputAddress      string // wallet address the output pays to
+	Hash               string
+	Confirmations      int64
+	BlockCreatedAt     time.Time
+}
+
+// SentOutput is an output of transaction sent from wallet, one transaction pays several addresses if sent to many
+type SentOutput struct {
+	TransactionID string
+	Vout          uint32
+	Address       string
+	Amount        models.Amount
+}
+
+// FundedTransaction is a transaction funded at estimated fee rate and signed by wallet but not broadcast yet,
+// it is broadcast as it is so that network fee recorded is the one paid, Outputs include change
+type FundedTransaction struct {
 	TransactionID  string
-	Hash           string
-	Confirmations  int64
-	BlockCreatedAt time.Time
+	RawTransaction string // hex
+	Fee            models.Amount
+	Outputs        []SentOutput
+}
+
+// OutputTo returns output of transaction paying address
+func (t FundedTransaction) OutputTo(address string) (SentOutput, bool) {
+	for _, output := range t.Outputs {
+		if output.Address == address {
+			return output, true
+		}
+	}
+	return SentOutput{}, false
+}
+
+// ConflictCheck tells whether transaction conflicts with one on main chain, e.g. double spent,
+// Err is set if it fails to check the transaction
+type ConflictCheck struct {
+	TransactionID string
+	Conflicted    bool
+	Err           error
+}
+
+// PermanentError is error of send that retrying can never fix, e.g. invalid address,
+// other send errors are deemed transient, e.g. insufficient funds or locked wallet
+type PermanentError struct {
+	Err error
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// IsPermanent tells whether err is PermanentError
+func IsPermanent(err error) bool {
+	_, ok := err.(PermanentError)
+	return ok
+}
+
+// Notifier is implemented by wallets able to push chain tip changes as they happen,
+// blocks are still polled without a notifier
+type Notifier interface {
+	BlockNotifications() <-chan BlockNotification
+}
+
+// BlockNotification tells a block is connected to or disconnected from main chain
+type BlockNotification struct {
+	Height    int64
+	Hash      string
+	Connected bool
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/solefaucet/jackpot-server/jerrors"
+	"github.com/solefaucet/jackpot-server/models"
+	w "github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+func (c *coin) sweepFeesJob() {
+	for {
+		c.sweepFees()
+		time.Sleep(c.config.Wallet.SweepInterval)
+	}
+}
+
+// sweepFees sends house fees of ended games to cold address once they reach sweep threshold,
+// a sweep and then its transaction are saved before it is broadcast so that it is never sent twice
+func (c *coin) sweepFees() {
+	entry := c.withFields(logrus.Fields{
+		"event": models.LogEventSweepFees,
+	})
+
+	sweep, err := c.storage.GetPendingSweep()
+	if err == jerrors.ErrNotFound {
+		sweep, err = c.newSweep(entry)
+	}
+
+	if err == jerrors.ErrNotFound {
+		return
+	}
+
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to get sweep")
+		return
+	}
+
+	c.sendSweep(entry, sweep)
+}
+
+// newSweep saves sweep of unswept fees if they reach threshold, it returns jerrors.ErrNotFound if there is nothing to sweep,
+// sweep never takes balance owed to players, and network fee is taken out of the fees swept when it is funded
+func (c *coin) newSweep(entry *logrus.Entry) (models.Sweep, error) {
+	fees, err := c.storage.GetUnsweptFees()
+	if err != nil {
+		return models.Sweep{}, err
+	}
+
+	if fees < c.config.Jackpot.SweepThreshold {
+		entry.WithField("unswept_fees", fees).Debug("unswept fees are below sweep threshold")
+		return models.Sweep{}, jerrors.ErrNotFound
+	}
+
+	reserve, err := c.reserve()
+	if err != nil {
+		return models.Sweep{}, err
+	}
+
+	amount := fees
+	if surplus := reserve.Balance - reserve.Total(); surplus < amount {
+		entry.WithFields(logrus.Fields{
+			"unswept_fees": fees,
+			"surplus":      surplus,
+		}).Warn("balance beyond liabilities is short of unswept fees, sweep surplus only")
+		amount = surplus
+	}
+
+	if amount <= 0 {
+		return models.Sweep{}, jerrors.ErrNotFound
+	}
+
+	sweep := models.Sweep{
+		IdempotencyKey: sweepIdempotencyKey(time.Now()),
+		Address:        c.config.Jackpot.ColdAddress,
+		Amount:         amount,
+	}
+	if err := c.storage.SaveSweep(sweep); err != nil {
+		return models.Sweep{}, err
+	}
+
+	return c.storage.GetPendingSweep()
+}
+
+// sendSweep broadcasts transaction recorded for sweep, funding and recording one first if there is none,
+// sweep that fails is retried next round unless wallet tells retrying can never fix it
+func (c *coin) sendSweep(entry *logrus.Entry, sweep models.Sweep) {
+	e := entry.WithFields(logrus.Fields{
+		"idempotency_key": sweep.IdempotencyKey,
+		"address":         sweep.Address,
+		"amount":          sweep.Amount,
+	})
+
+	sweep, err := c.broadcastSweep(sweep)
+	if err != nil && w.IsPermanent(err) && sweep.TransactionID != "" && c.knowsTransaction(e, sweep.TransactionID) {
+		e.WithField("error", err.Error()).Warn("wallet knows transaction of rejected sweep, mark it sent")
+		err = nil
+	}
+
+	if err != nil && w.IsPermanent(err) {
+		if err := c.storage.UpdateSweepToFailedStatus(sweep.ID, err.Error()); err != nil {
+			e.WithField("storage_error", err.Error()).Error("fail to update sweep status to failed")
+			return
+		}
+		e.WithField("error", err.Error()).Error("fail to send sweep, give it up")
+
+		// sweep failed is never broadcast again, its inputs are left to following transactions
+		if sweep.RawTransaction != "" {
+			if err := c.wallet.ReleaseTransaction(sweep.RawTransaction); err != nil {
+				e.WithField("error", err.Error()).Warn("fail to release inputs of failed sweep")
+			}
+		}
+		return
+	}
+
+	if err != nil {
+		e.WithField("error", err.Error()).Error("fail to send sweep")
+		return
+	}
+
+	e = e.WithFields(logrus.Fields{
+		"tx_id":       sweep.TransactionID,
+		"amount":      sweep.Amount,
+		"network_fee": sweep.NetworkFee,
+	})
+
+	if err := c.storage.UpdateSweepToSentStatus(sweep.ID, sweep.TransactionID, sweep.NetworkFee); err != nil {
+		e.WithField("error", err.Error()).Error("fail to update sweep status to sent")
+		return
+	}
+
+	e.Info("fees swept")
+}
+
+// broadcastSweep returns sweep with transaction it is sent in, network fee of funded transaction is taken out of amount
+func (c *coin) broadcastSweep(sweep models.Sweep) (models.Sweep, error) {
+	if sweep.RawTransaction == "" {
+		amounts := map[string]models.Amount{sweep.Address: sweep.Amount}
+		funded, err := c.wallet.FundTransaction(amounts, []string{sweep.Address}, c.config.Wallet.FeeConfTarget)
+		if err != nil {
+			return sweep, err
+		}
+
+		output, ok := funded.OutputTo(sweep.Address)
+		if !ok {
+			c.wallet.ReleaseTransaction(funded.RawTransaction)
+			return sweep, fmt.Errorf("funded transaction %v has no output to %v", funded.TransactionID, sweep.Address)
+		}
+
+		recorded := sweep
+		recorded.TransactionID, recorded.Amount, recorded.NetworkFee, recorded.RawTransaction = funded.TransactionID, output.Amount, funded.Fee, funded.RawTransaction
+		if err := c.storage.UpdateSweepTransaction(recorded); err != nil {
+			c.wallet.ReleaseTransaction(funded.RawTransaction)
+			return sweep, err
+		}
+		sweep = recorded
+	}
+
+	return sweep, c.wallet.BroadcastTransaction(sweep.RawTransaction)
+}
+
+// knowsTransaction tells if wallet knows transaction that is not conflicted, e.g. rebroadcast of transaction
+// mined already is rejected for missing inputs, it is false if wallet can not tell
+func (c *coin) knowsTransaction(entry *logrus.Entry, transactionID string) bool {
+	// conflicted transaction has negative confirmations, it can never be mined
+	confirmations, err := c.wallet.GetTransactionConfirmations(transactionID)
+	if err != nil && err != jerrors.ErrNotFound {
+		entry.WithField("check_error", err.Error()).Warn("fail to check transaction in wallet")
+	}
+
+	return err == nil && confirmations >= 0
+}
+
+// sweepIdempotencyKey must never be the key of a previous sweep, as idempotency keys of sweeps are unique
+func sweepIdempotencyKey(createdAt time.Time) string {
+	return "jackpot sweep of " + createdAt.UTC().Format(time.RFC3339Nano)
+}
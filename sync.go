@@ -0,0 +1,112 @@
+package main
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/solefaucet/jackpot-server/models"
+	w "github.com/solefaucet/jackpot-server/services/wallet"
+)
+
+// prefetchedBlock is a block fetched ahead of being saved during catch-up
+type prefetchedBlock struct {
+	block                *w.Block
+	receivedTransactions []w.Transaction
+	err                  error
+}
+
+// catchUpIfFarBehind catches up from height to the tip of chain if it is more than threshold blocks ahead,
+// it returns the next height to fetch
+func (c *coin) catchUpIfFarBehind(height int64) int64 {
+	bestHeight, err := c.wallet.GetBestHeight()
+	if err != nil || bestHeight-height < c.config.Wallet.CatchUpThreshold {
+		return height
+	}
+
+	return c.catchUp(height, bestHeight)
+}
+
+// catchUp prefetches blocks from height to bestHeight with bounded concurrent workers,
+// blocks are saved strictly in height order, it stops at the first failure or chain reorganization
+// and returns the next height to fetch so that fetchBlocks takes over from there
+func (c *coin) catchUp(height, bestHeight int64) int64 {
+	entry := c.withFields(logrus.Fields{
+		"event":       models.LogEventCatchUp,
+		"from_height": height,
+		"best_height": bestHeight,
+		"workers":     c.config.Wallet.SyncWorkers,
+	})
+	entry.Info("far behind the chain tip, start catching up")
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// results are queued in height order, queue capacity bounds blocks being fetched
+	queue := make(chan chan prefetchedBlock, c.config.Wallet.SyncWorkers)
+	go c.prefetchBlocks(height, bestHeight, queue, done)
+
+	for result := range queue {
+		prefetched := <-result
+		if prefetched.err != nil {
+			entry.WithFields(logrus.Fields{
+				"block_height": height,
+				"error":        prefetched.err.Error(),
+			}).Error("fail to prefetch block from blockchain")
+			return height
+		}
+
+		forkHeight, reorganized, err := c.handleChainReorganization(prefetched.block)
+		if err != nil {
+			entry.WithFields(logrus.Fields{
+				"block_height": height,
+				"error":        err.Error(),
+			}).Error("fail to handle chain reorganization")
+			return height
+		}
+
+		if reorganized {
+			return forkHeight + 1
+		}
+
+		if err := c.saveBlockAndTransactions(prefetched.block, prefetched.receivedTransactions); err != nil {
+			return height
+		}
+
+		height = prefetched.block.Height + 1
+	}
+
+	entry.WithField("block_height", height).Info("catch up finished")
+	return height
+}
+
+// prefetchBlocks fetches every block from height to bestHeight concurrently,
+// it stops queueing once done is closed
+func (c *coin) prefetchBlocks(height, bestHeight int64, queue chan<- chan prefetchedBlock, done <-chan struct{}) {
+	defer close(queue)
+
+	for ; height <= bestHeight; height++ {
+		// buffered so that fetching goroutine never blocks after catch-up stops
+		result := make(chan prefetchedBlock, 1)
+		select {
+		case queue <- result:
+		case <-done:
+			return
+		}
+
+		go func(height int64) {
+			result <- c.prefetchBlock(height)
+		}(height)
+	}
+}
+
+func (c *coin) prefetchBlock(height int64) prefetchedBlock {
+	block, err := c.wallet.GetBlock(false, height)
+	if err != nil {
+		return prefetchedBlock{err: err}
+	}
+
+	receivedTransactions, err := c.wallet.GetReceivedSince(block.PrevHash, block.Hash)
+	if err != nil {
+		return prefetchedBlock{err: err}
+	}
+
+	return prefetchedBlock{block: block, receivedTransactions: receivedTransactions}
+}
@@ -40,7 +40,7 @@ func FindWinner(transactions []models.Transaction, hash string) string {
 func totalAmountOfTransactions(transactions []models.Transaction) int64 {
 	var totalAmount int64
 	for _, tx := range transactions {
-		totalAmount += int64(tx.Amount * 1e8)
+		totalAmount += int64(tx.Amount)
 	}
 	return totalAmount
 }
@@ -48,7 +48,7 @@ func totalAmountOfTransactions(transactions []models.Transaction) int64 {
 func transactionMap(transactions []models.Transaction) map[string]int64 {
 	m := map[string]int64{}
 	for _, tx := range transactions {
-		m[tx.Address] = m[tx.Address] + int64(tx.Amount*1e8)
+		m[tx.Address] = m[tx.Address] + int64(tx.Amount)
 	}
 	return m
 }
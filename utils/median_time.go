@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"sort"
+	"time"
+)
+
+// MedianTimeSpan is the number of blocks whose timestamps make up median time past
+const MedianTimeSpan = 11
+
+// MedianTime returns median of times, zero time if times is empty
+func MedianTime(times []time.Time) time.Time {
+	if len(times) == 0 {
+		return time.Time{}
+	}
+
+	sorted := append([]time.Time(nil), times...)
+	sort.Sort(timeSlice(sorted))
+	return sorted[len(sorted)/2]
+}
+
+type timeSlice []time.Time
+
+func (s timeSlice) Len() int           { return len(s) }
+func (s timeSlice) Less(i, j int) bool { return s[i].Before(s[j]) }
+func (s timeSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMedianTime(t *testing.T) {
+	now := time.Now()
+	times := []time.Time{now.Add(3 * time.Minute), now, now.Add(-time.Hour), now.Add(time.Minute), now.Add(2 * time.Hour)}
+
+	if actual, expected := MedianTime(times), now.Add(time.Minute); !actual.Equal(expected) {
+		t.Errorf("median time expected %v but get %v", expected, actual)
+	}
+
+	if !times[0].Equal(now.Add(3 * time.Minute)) {
+		t.Errorf("median time should not reorder times")
+	}
+
+	if actual := MedianTime(nil); !actual.IsZero() {
+		t.Errorf("median time of nothing expected zero time but get %v", actual)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -10,72 +11,147 @@ import (
 	"github.com/solefaucet/jackpot-server/utils"
 )
 
-var (
-	blockHeightChan        = make(chan int64, 2)
-	previousBlockCreatedAt = time.Time{}
-)
-
 func initWork() {
-	go fetchBlocksJob()
-	go updateConfirmationsJob()
-	go drawGamesJob()
+	for _, c := range coins {
+		c.initWork()
+	}
+}
 
+// initWork starts background jobs of coin
+func (c *coin) initWork() {
+	c.blockHeightChan <- c.initialHeight()
+
+	go c.fetchBlocksJob()
+	go c.updateConfirmationsJob()
+	go c.drawGamesJob()
+	go c.watchMempoolJob()
+	go c.sendRefundsJob()
+	go c.sendPayoutsJob()
+	go c.monitorBalanceJob()
+	if c.config.Jackpot.ColdAddress != "" {
+		go c.sweepFeesJob()
+	}
+}
+
+// initialHeight returns height to fetch first, -1 means the best block
+func (c *coin) initialHeight() int64 {
 	// get latest block from db
-	block, err := storage.GetLatestBlock()
+	block, err := c.storage.GetLatestBlock()
 
 	if err == jerrors.ErrNotFound {
-		blockHeightChan <- -1
-		return
+		return c.startHeight()
 	}
 
 	if err != nil {
 		logger.Panicf("fail to get latest block from database: %#v\n", err)
-		return
 	}
 
-	previousBlockCreatedAt = block.BlockCreatedAt
-	blockHeightChan <- block.Height + 1
+	// stored tip unknown to node means node is on another network or restored from scratch,
+	// running against it would orphan every stored block
+	if _, err := c.wallet.GetBlockByHash(block.Hash); err != nil {
+		logger.Panicf("fail to find stored tip %v at height %v on node's chain: %#v\n", block.Hash, block.Height, err)
+	}
+
+	// node also knows blocks of stale forks, tip orphaned while service was down is rolled back
+	// the same way as chain reorganization found while running
+	forkHeight, err := c.findForkHeight(block.Height)
+	if err != nil {
+		logger.Panicf("fail to check stored tip %v at height %v is on main chain: %#v\n", block.Hash, block.Height, err)
+	}
+
+	if forkHeight < block.Height {
+		c.withFields(logrus.Fields{
+			"event":       models.LogEventChainReorganization,
+			"stored_hash": block.Hash,
+			"fork_height": forkHeight,
+		}).Warn("stored tip is orphaned, orphaning blocks above fork height")
+
+		if err := c.storage.OrphanBlocksAfter(forkHeight); err != nil {
+			logger.Panicf("fail to orphan blocks after fork height %v: %#v\n", forkHeight, err)
+		}
+	}
+
+	return forkHeight + 1
+}
+
+// startHeight returns height to start from on empty database,
+// start hash takes precedence over start height and both are validated against node
+func (c *coin) startHeight() int64 {
+	bestHeight, err := c.wallet.GetBestHeight()
+	if err != nil {
+		logger.Panicf("fail to get best height from blockchain: %#v\n", err)
+	}
+
+	if c.config.Wallet.StartHash == "" {
+		if c.config.Wallet.StartHeight > bestHeight {
+			logger.Panicf("start height %v is ahead of best height %v\n", c.config.Wallet.StartHeight, bestHeight)
+		}
+		return c.config.Wallet.StartHeight
+	}
+
+	block, err := c.wallet.GetBlockByHash(c.config.Wallet.StartHash)
+	if err != nil {
+		logger.Panicf("fail to get start block %v from blockchain: %#v\n", c.config.Wallet.StartHash, err)
+	}
+
+	onMainChain, err := c.isOnMainChain(block.Height, block.Hash)
+	if err != nil || !onMainChain {
+		logger.Panicf("start block %v is not on main chain, error: %#v\n", block.Hash, err)
+	}
+
+	if c.config.Wallet.StartHeight >= 0 && c.config.Wallet.StartHeight != block.Height {
+		logger.Panicf("start height %v does not match height %v of start block %v\n", c.config.Wallet.StartHeight, block.Height, block.Hash)
+	}
+
+	return block.Height
 }
 
-func fetchBlocksJob() {
+func (c *coin) fetchBlocksJob() {
 	for {
-		height := <-blockHeightChan
-		fetchBlocks(height)
+		height := <-c.blockHeightChan
+		if height >= 0 {
+			height = c.catchUpIfFarBehind(height)
+		}
+		c.fetchBlocks(height)
 	}
 }
 
-func updateConfirmationsJob() {
+func (c *coin) updateConfirmationsJob() {
 	for {
-		updateConfirmations()
-		time.Sleep(time.Minute)
+		c.updateConfirmations()
+		wakeUp(c.drawGamesWakeup)
+		sleepUntilWokenUp(c.confirmationsWakeup, time.Minute)
 	}
 }
 
-func drawGamesJob() {
+func (c *coin) drawGamesJob() {
 	for {
-		drawGames()
-		time.Sleep(time.Minute)
+		c.drawGames()
+		sleepUntilWokenUp(c.drawGamesWakeup, time.Minute)
 	}
 }
 
-func fetchBlocks(height int64) {
+func (c *coin) fetchBlocks(height int64) {
 	var err error
 	defer func() {
-		if err != nil {
+		switch {
+		case err == jerrors.ErrNoNewBlock:
+			height = c.waitForBlock(height)
+		case err != nil:
 			time.Sleep(5 * time.Second)
 		}
 
-		blockHeightChan <- height
+		c.blockHeightChan <- height
 	}()
 
-	entry := logrus.WithFields(logrus.Fields{
+	entry := c.withFields(logrus.Fields{
 		"event":        models.LogEventSaveBlockAndTransactions,
 		"block_height": height,
 	})
 
 	// get new block from blockchain
 	bestBlock := height < 0
-	block, err := wallet.GetBlock(bestBlock, height)
+	block, err := c.wallet.GetBlock(bestBlock, height)
 	if err == jerrors.ErrNoNewBlock {
 		entry.Info("no new block ahead")
 		return
@@ -86,61 +162,365 @@ func fetchBlocks(height int64) {
 		return
 	}
 
-	gameOf := block.BlockCreatedAt.Truncate(config.Jackpot.Duration)
-	entry.WithFields(logrus.Fields{
-		"previous_hash": block.PrevHash,
-		"height":        block.Height,
-		"game_of":       gameOf,
-	})
+	// make sure the new block extends the chain we have stored
+	if !bestBlock {
+		forkHeight, reorganized, e := c.handleChainReorganization(block)
+		if err = e; err != nil {
+			entry.WithField("error", err.Error()).Error("fail to handle chain reorganization")
+			return
+		}
+
+		if reorganized {
+			height = forkHeight + 1
+			return
+		}
+	}
 
 	// get receive transactions
-	transactions, err := wallet.GetReceivedSince(block.PrevHash, block.Hash)
+	receivedTransactions, err := c.wallet.GetReceivedSince(block.PrevHash, block.Hash)
 	if err != nil {
 		entry.WithField("error", err.Error()).Error("fail to list transactions from blockchain")
 		return
 	}
 
-	// check if it's time to find out the winner
-	previousGameOf := previousBlockCreatedAt.Truncate(config.Jackpot.Duration)
-	var updatedGame *models.Game
-	if previousGameOf.Add(config.Jackpot.Duration).Equal(gameOf) {
-		updatedGame = &models.Game{
-			Hash:   block.Hash,
-			Height: block.Height,
-			GameOf: previousGameOf,
-		}
+	if err := c.saveBlockAndTransactions(block, receivedTransactions); err != nil {
+		return
+	}
+
+	height = block.Height + 1
+}
+
+// saveBlockAndTransactions saves block with transactions received in it,
+// every pending game before game of the block is closed and drawn on this block
+func (c *coin) saveBlockAndTransactions(block *w.Block, receivedTransactions []w.Transaction) error {
+	entry := c.withFields(logrus.Fields{
+		"event":         models.LogEventSaveBlockAndTransactions,
+		"block_height":  block.Height,
+		"previous_hash": block.PrevHash,
+		"hash":          block.Hash,
+	})
+
+	modelBlock := walletBlockToModelBlock(block)
+	medianTime, err := c.medianTimePast(modelBlock)
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to compute median time past of block")
+		return err
 	}
+	modelBlock.MedianTime = medianTime
 
-	// save block, transactions
-	if err := storage.SaveBlockAndTransactions(
-		gameOf,
-		walletBlockToModelBlock(block),
-		walletTxsToModelTxs(gameOf, transactions),
-		updatedGame,
+	game, err := c.gameOfBlock(modelBlock)
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to assign block to game")
+		return err
+	}
+	gameOf := game.GameOf
+	entry = entry.WithFields(logrus.Fields{"median_time": medianTime, "game_of": gameOf})
+
+	transactions, excludedTransactions := c.walletTxsToModelTxs(gameOf, block.Height, receivedTransactions)
+	if len(excludedTransactions) > 0 {
+		entry.WithField("excluded_transactions", len(excludedTransactions)).Warn("some transactions are excluded from game")
+	}
+
+	transactions, refunds, err := c.applyBetLimits(gameOf, block, transactions)
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to apply bet limits")
+		return err
+	}
+
+	if err := c.storage.SaveBlockAndTransactions(
+		game,
+		modelBlock,
+		transactions,
+		excludedTransactions,
+		refunds,
 	); err != nil {
 		entry.WithField("error", err.Error()).Error("fail to save block and transactions to db")
-		return
+		return err
 	}
 
-	entry.Info("save block and transactions successfully")
-	height = block.Height + 1
-	previousBlockCreatedAt = block.BlockCreatedAt
+	entry.WithField("refunds", len(refunds)).Info("save block and transactions successfully")
+	wakeUp(c.confirmationsWakeup)
+	wakeUp(c.refundsWakeup)
+	return nil
+}
+
+// medianTimePast returns median of block time of the block and the stored blocks right below it,
+// unlike block time reported by miners it never goes backwards along the chain
+func (c *coin) medianTimePast(block models.Block) (time.Time, error) {
+	createdAts, err := c.storage.GetBlockCreatedAtsBefore(block.Height, utils.MedianTimeSpan-1)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return utils.MedianTime(append(createdAts, block.BlockCreatedAt)), nil
+}
+
+// gameOfBlock assigns block to game, games span either duration or fixed number of blocks
+func (c *coin) gameOfBlock(block models.Block) (models.Game, error) {
+	if c.config.Jackpot.RoundBlocks > 0 {
+		return c.gameOfBlockByHeight(block)
+	}
+
+	gameOf, err := c.gameOfBlockByTime(block.MedianTime)
+	return models.Game{GameOf: gameOf, StartHeight: block.Height, EndHeight: block.Height}, err
+}
+
+// gameOfBlockByTime assigns block to game by its median time past,
+// deposits never go to a game that has stopped accepting them, the game right after the latest closed one is used instead
+func (c *coin) gameOfBlockByTime(medianTime time.Time) (time.Time, error) {
+	gameOf := medianTime.Truncate(c.config.Jackpot.Duration)
+
+	latestClosedGame, err := c.storage.GetLatestClosedGame()
+	if err == jerrors.ErrNotFound {
+		return gameOf, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !gameOf.After(latestClosedGame.GameOf) {
+		gameOf = latestClosedGame.GameOf.Add(c.config.Jackpot.Duration)
+	}
+
+	return gameOf, nil
+}
+
+// currentGameOf returns game deposits in mempool are expected to join, i.e. game of the latest block by its median time past,
+// jerrors.ErrNotFound is returned if there is no block yet
+func (c *coin) currentGameOf() (time.Time, error) {
+	block, err := c.storage.GetLatestBlock()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return c.gameOfBlockByTime(block.MedianTime)
+}
+
+// gameOfBlockByHeight assigns block to game spanning RoundBlocks blocks from a multiple of RoundBlocks,
+// the game is drawn on the first block after its end height,
+// game of time is median time past of the first block saved in the game, which still identifies the game,
+// like by time, blocks of a round that has stopped accepting deposits go to the round right after the latest closed one
+func (c *coin) gameOfBlockByHeight(block models.Block) (models.Game, error) {
+	startHeight := block.Height - block.Height%c.config.Jackpot.RoundBlocks
+
+	latestClosedGame, err := c.storage.GetLatestClosedGame()
+	if err != nil && err != jerrors.ErrNotFound {
+		return models.Game{}, err
+	}
+
+	if err == nil && startHeight <= latestClosedGame.StartHeight {
+		startHeight = latestClosedGame.EndHeight + 1
+	}
+
+	game := models.Game{
+		StartHeight: startHeight,
+		EndHeight:   startHeight + c.config.Jackpot.RoundBlocks - 1,
+	}
+
+	existingGame, err := c.storage.GetGameByStartHeight(startHeight)
+	if err == nil {
+		game.GameOf = existingGame.GameOf
+		return game, nil
+	}
+
+	if err != jerrors.ErrNotFound {
+		return game, err
+	}
+
+	// game of must be unique and later than every previous game
+	game.GameOf = block.MedianTime.Truncate(time.Second)
+	latestGames, err := c.storage.GetGames(1, 0)
+	if err != nil {
+		return game, err
+	}
+
+	if len(latestGames) > 0 && !game.GameOf.After(latestGames[0].GameOf) {
+		game.GameOf = latestGames[0].GameOf.Add(time.Second)
+	}
+
+	return game, nil
+}
+
+// waitForBlock waits for a block notification or poll interval, whichever comes first,
+// it returns the next height to fetch which goes back if stored blocks are disconnected
+func (c *coin) waitForBlock(height int64) int64 {
+	select {
+	case notification := <-c.blockNotifications:
+		if notification.Connected {
+			return height
+		}
+
+		if err := c.handleBlockDisconnected(notification); err != nil {
+			c.withFields(logrus.Fields{
+				"event":        models.LogEventChainReorganization,
+				"block_height": notification.Height,
+				"hash":         notification.Hash,
+				"error":        err.Error(),
+			}).Error("fail to handle disconnected block")
+			return height
+		}
+
+		if notification.Height < height {
+			return notification.Height
+		}
+		return height
+	case <-time.After(c.config.Wallet.PollInterval):
+		return height
+	}
 }
 
-func walletTxsToModelTxs(gameOf time.Time, txs []w.Transaction) []models.Transaction {
-	transactions := make([]models.Transaction, len(txs))
-	for i, v := range txs {
-		transactions[i] = models.Transaction{
+// handleBlockDisconnected orphans the disconnected block and every stored block above it,
+// blocks not stored or already orphaned are ignored
+func (c *coin) handleBlockDisconnected(notification w.BlockNotification) error {
+	storedBlock, err := c.storage.GetBlockByHeight(notification.Height)
+	if err == jerrors.ErrNotFound {
+		return nil
+	}
+
+	if err != nil || storedBlock.Hash != notification.Hash {
+		return err
+	}
+
+	c.withFields(logrus.Fields{
+		"event":        models.LogEventChainReorganization,
+		"block_height": notification.Height,
+		"hash":         notification.Hash,
+	}).Warn("stored block disconnected from main chain, orphaning blocks from its height")
+
+	return c.storage.OrphanBlocksAfter(notification.Height - 1)
+}
+
+// wakeUp never blocks, a pending wake up is enough for a job to run again
+func wakeUp(c chan<- struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+func sleepUntilWokenUp(c <-chan struct{}, timeout time.Duration) {
+	select {
+	case <-c:
+	case <-time.After(timeout):
+	}
+}
+
+// findForkHeight walks stored blocks down from height to the highest one on main chain,
+// stored blocks above best height of node are on a stale fork longer than main chain
+func (c *coin) findForkHeight(height int64) (int64, error) {
+	bestHeight, err := c.wallet.GetBestHeight()
+	if err != nil {
+		return 0, err
+	}
+
+	for ; ; height-- {
+		storedBlock, err := c.storage.GetBlockByHeight(height)
+		if err == jerrors.ErrNotFound {
+			return height, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if height > bestHeight {
+			continue
+		}
+
+		onMainChain, err := c.isOnMainChain(height, storedBlock.Hash)
+		if err != nil {
+			return 0, err
+		}
+
+		if onMainChain {
+			return height, nil
+		}
+	}
+}
+
+// handleChainReorganization compares block's previous hash with the stored block at height-1,
+// if they differ, it walks back to the fork point and orphans every stored block above it
+func (c *coin) handleChainReorganization(block *w.Block) (forkHeight int64, reorganized bool, err error) {
+	previousBlock, err := c.storage.GetBlockByHeight(block.Height - 1)
+	if err == jerrors.ErrNotFound {
+		return 0, false, nil
+	}
+
+	if err != nil || previousBlock.Hash == block.PrevHash {
+		return 0, false, err
+	}
+
+	forkHeight, err = c.findForkHeight(block.Height - 1)
+	if err != nil {
+		return 0, false, err
+	}
+
+	c.withFields(logrus.Fields{
+		"event":         models.LogEventChainReorganization,
+		"block_height":  block.Height,
+		"previous_hash": block.PrevHash,
+		"stored_hash":   previousBlock.Hash,
+		"fork_height":   forkHeight,
+	}).Warn("chain reorganization detected, orphaning blocks above fork height")
+
+	if err := c.storage.OrphanBlocksAfter(forkHeight); err != nil {
+		return 0, false, err
+	}
+
+	return forkHeight, true, nil
+}
+
+// walletTxsToModelTxs converts wallet transactions to game transactions,
+// those that cannot take part in game are returned as excluded transactions
+func (c *coin) walletTxsToModelTxs(gameOf time.Time, height int64, txs []w.Transaction) ([]models.Transaction, []models.ExcludedTransaction) {
+	transactions := []models.Transaction{}
+	excludedTransactions := []models.ExcludedTransaction{}
+	for _, v := range txs {
+		// e.g. change or funds sent to other addresses of the same wallet
+		if !c.config.isJackpotAddress(v.OutputAddress) {
+			excludedTransactions = append(excludedTransactions, models.ExcludedTransaction{
+				Address:        v.Address,
+				Amount:         v.Amount,
+				TransactionID:  v.TransactionID,
+				Vout:           v.Vout,
+				OutputAddress:  v.OutputAddress,
+				Hash:           v.Hash,
+				Reason:         models.ExcludedReasonOffAddress,
+				Detail:         fmt.Sprintf("paid to %v", v.OutputAddress),
+				BlockCreatedAt: v.BlockCreatedAt,
+			})
+			continue
+		}
+
+		if v.UnattributedReason != "" {
+			excludedTransactions = append(excludedTransactions, models.ExcludedTransaction{
+				Amount:         v.Amount,
+				TransactionID:  v.TransactionID,
+				Vout:           v.Vout,
+				OutputAddress:  v.OutputAddress,
+				Hash:           v.Hash,
+				Reason:         models.ExcludedReasonUnattributed,
+				Detail:         v.UnattributedReason,
+				BlockCreatedAt: v.BlockCreatedAt,
+			})
+			continue
+		}
+
+		transactions = append(transactions, models.Transaction{
 			Address:        v.Address,
 			Amount:         v.Amount,
 			TransactionID:  v.TransactionID,
+			Vout:           v.Vout,
+			OutputAddress:  v.OutputAddress,
 			Hash:           v.Hash,
+			Height:         height,
 			Confirmations:  v.Confirmations,
 			GameOf:         gameOf,
 			BlockCreatedAt: v.BlockCreatedAt,
-		}
+		})
 	}
-	return transactions
+	return transactions, excludedTransactions
 }
 
 func walletBlockToModelBlock(blockchainBlock *w.Block) models.Block {
@@ -151,53 +531,110 @@ func walletBlockToModelBlock(blockchainBlock *w.Block) models.Block {
 	}
 }
 
-func updateConfirmations() {
-	minConfirmations := config.Wallet.MinConfirms
+// updateConfirmations derives confirmations from height of stored chain tip once per tip change,
+// wallet is only asked whether transactions still below min confirmations are conflicted
+func (c *coin) updateConfirmations() {
+	minConfirmations := c.config.Wallet.MinConfirms
 
-	entry := logrus.WithFields(logrus.Fields{
+	entry := c.withFields(logrus.Fields{
 		"event":             models.LogEventUpdateConfirmations,
 		"min_confirmations": minConfirmations,
 	})
 
-	transactions, err := storage.GetUnconfirmedTransactions(minConfirmations)
+	tip, err := c.storage.GetLatestBlock()
+	if err == jerrors.ErrNotFound || err == nil && tip.Hash == c.confirmedTipHash {
+		return
+	}
+
+	if err != nil {
+		entry.WithField("error", err.Error()).Error("fail to get latest block")
+		return
+	}
+	entry = entry.WithField("block_height", tip.Height)
+
+	// transactions to cross check are taken before update so that none reaches min confirmations unchecked
+	transactions, err := c.storage.GetUnconfirmedTransactions(minConfirmations)
 	if err != nil {
 		entry.WithField("error", err.Error()).Error("fail to get unconfirmed transactions")
 		return
 	}
 
-	for _, transaction := range transactions {
-		confirmations, err := wallet.GetConfirmationsFromTxID(transaction.TransactionID)
-		if err != nil {
-			entry.WithFields(logrus.Fields{
-				"error": err.Error(),
-				"tx_id": transaction.TransactionID,
-			}).Error("fail to get confirmations by tx id")
-			return
+	// conflicted transactions keep their confirmations so that their games are not drawn,
+	// they are orphaned once the chain reorganization behind them is ingested
+	unclearedIDs := c.unclearedTransactionIDs(transactions)
+	if err := c.storage.UpdateConfirmationsByTipHeight(tip.Height, minConfirmations, unclearedIDs...); err != nil {
+		entry.WithField("error", err.Error()).Error("fail to update confirmations by tip height")
+		return
+	}
+
+	// uncleared transactions are checked again in next round even if tip does not change
+	if len(unclearedIDs) == 0 {
+		c.confirmedTipHash = tip.Hash
+	}
+}
+
+// unclearedTransactionIDs returns ids of transactions conflicted or failing to check against wallet
+func (c *coin) unclearedTransactionIDs(transactions []models.Transaction) []int64 {
+	ids := []int64{}
+	if len(transactions) == 0 {
+		return ids
+	}
+
+	txids := make([]string, len(transactions))
+	for i, transaction := range transactions {
+		txids[i] = transaction.TransactionID
+	}
+
+	checks, err := c.wallet.CheckConflicted(txids)
+	if err != nil {
+		c.withFields(logrus.Fields{
+			"event": models.LogEventUpdateConfirmations,
+			"error": err.Error(),
+		}).Error("fail to check whether transactions are conflicted")
+		for _, transaction := range transactions {
+			ids = append(ids, transaction.ID)
 		}
+		return ids
+	}
 
-		if err := storage.UpdateTransactionConfirmationByID(transaction.ID, confirmations); err != nil {
-			entry.WithFields(logrus.Fields{
-				"error": err.Error(),
-				"tx_id": transaction.TransactionID,
-			}).Error("fail to update transaction confirmation")
-			return
+	for i, check := range checks {
+		entry := c.withFields(logrus.Fields{
+			"event": models.LogEventUpdateConfirmations,
+			"tx_id": transactions[i].TransactionID,
+			"hash":  transactions[i].Hash,
+		})
+
+		if check.Err != nil {
+			entry.WithField("error", check.Err.Error()).Error("fail to check whether transaction is conflicted")
+			ids = append(ids, transactions[i].ID)
+			continue
+		}
+
+		if check.Conflicted {
+			entry.Warn("transaction is conflicted, waiting for chain reorganization")
+			ids = append(ids, transactions[i].ID)
 		}
 	}
+
+	return ids
 }
 
-func drawGames() {
-	entry := logrus.WithFields(logrus.Fields{
+func (c *coin) drawGames() {
+	entry := c.withFields(logrus.Fields{
 		"event": models.LogEventDrawGames,
 	})
 
-	games, err := storage.GetDrawingNeededGames()
+	// payouts of games drawn are sent right away
+	defer wakeUp(c.payoutsWakeup)
+
+	games, err := c.storage.GetDrawingNeededGames()
 	if err != nil {
 		entry.WithField("error", err.Error()).Error("fail to get drawing needed games")
 		return
 	}
 
 	for _, game := range games {
-		transactions, err := storage.GetTransactionsByGameOfs(game.GameOf)
+		transactions, err := c.storage.GetTransactionsByGameOfs(game.GameOf)
 		if err != nil {
 			entry.WithFields(logrus.Fields{
 				"error":   err.Error(),
@@ -206,75 +643,61 @@ func drawGames() {
 			return
 		}
 
-		if !allTransactionsConfirmed(transactions) {
+		if !c.allTransactionsConfirmed(transactions) {
 			continue
 		}
 
-		winnerAddress, transactionID, winAmount, fee, err := findWinnerAndSendCoins(game.GameOf, game.Hash)
+		// never pay out on a block that has been orphaned but not rolled back yet
+		onMainChain, err := c.isOnMainChain(game.Height, game.Hash)
 		if err != nil {
 			entry.WithFields(logrus.Fields{
+				"error":   err.Error(),
 				"game_of": game.GameOf,
 				"hash":    game.Hash,
-				"error":   err.Error(),
-			}).Error("fail to find winner and send coins")
+			}).Error("fail to check if draw hash is on main chain")
 			return
 		}
 
-		g := models.Game{
-			Address:       winnerAddress,
-			WinAmount:     winAmount,
-			Fee:           fee,
-			TransactionID: transactionID,
-			GameOf:        game.GameOf,
-		}
-		if err := storage.UpdateGameToEndedStatus(g); err != nil {
+		if !onMainChain {
 			entry.WithFields(logrus.Fields{
-				"winner_address": winnerAddress,
-				"win_amount":     winAmount,
-				"fee":            fee,
-				"tx_id":          transactionID,
-				"game_of":        game.GameOf,
-			}).Panic("fail to update game status to ended")
-			return
+				"game_of": game.GameOf,
+				"hash":    game.Hash,
+				"height":  game.Height,
+			}).Warn("draw hash is not on main chain, skip drawing")
+			continue
 		}
-	}
-}
 
-func allTransactionsConfirmed(transactions []models.Transaction) bool {
-	for _, transaction := range transactions {
-		if config.Wallet.MinConfirms > transaction.Confirmations {
-			return false
+		if err := c.drawGame(game, transactions); err != nil {
+			entry.WithFields(logrus.Fields{
+				"game_of": game.GameOf,
+				"hash":    game.Hash,
+				"error":   err.Error(),
+			}).Error("fail to draw game")
+			continue
 		}
 	}
-	return true
 }
 
-func findWinnerAndSendCoins(gameOf time.Time, hash string) (winnerAddress, transactionID string, winAmount, fee float64, err error) {
-	transactions, err := storage.GetTransactionsByGameOfs(gameOf)
+func (c *coin) isOnMainChain(height int64, hash string) (bool, error) {
+	block, err := c.wallet.GetBlock(false, height)
 	if err != nil {
-		return
+		return false, err
 	}
 
-	// no transactions, no winner
-	if len(transactions) == 0 {
-		return
-	}
-
-	totalAmount := totalAmountOfTransactions(transactions)
-	fee = totalAmount * config.Jackpot.TransactionFee
-	winAmount = totalAmount - fee
+	return block.Hash == hash, nil
+}
 
-	winnerAddress = utils.FindWinner(transactions, hash)
-	transactionID, err = wallet.SendFromAccountToAddress(config.Wallet.SentFromAccount, winnerAddress, winAmount)
-	if err != nil {
-		return
+func (c *coin) allTransactionsConfirmed(transactions []models.Transaction) bool {
+	for _, transaction := range transactions {
+		if c.config.Wallet.MinConfirms > transaction.Confirmations {
+			return false
+		}
 	}
-
-	return
+	return true
 }
 
-func totalAmountOfTransactions(transactions []models.Transaction) float64 {
-	totalAmount := 0.0
+func totalAmountOfTransactions(transactions []models.Transaction) models.Amount {
+	totalAmount := models.Amount(0)
 	for _, tx := range transactions {
 		totalAmount += tx.Amount
 	}